@@ -4,6 +4,9 @@ import (
 	"CodeStream/src"
 	"CodeStream/src/api"
 	"CodeStream/src/resources"
+	"context"
+	"flag"
+	"fmt"
 	"net/http"
 	"os"
 	"time"
@@ -13,8 +16,25 @@ import (
 
 func main() {
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "schema":
+			if err := api.WriteProtocolSchema(os.Stdout); err != nil {
+				panic(err)
+			}
+			return
+		case "import-problem":
+			importProblems(os.Args[2:])
+			return
+		}
+	}
+
 	src.Config.SetupEnv()
 	resources.SetupRedis()
+	resources.StartInstanceHeartbeat()
+	resources.SetupDatabase()
+	resources.SetupArchive()
+	resources.SetupProblemBank()
 
 	if err := os.MkdirAll(src.Config.CodeWorkDir, 0755); err != nil {
 		panic(err)
@@ -22,11 +42,25 @@ func main() {
 
 	gin.SetMode(src.Config.ApplicationMode)
 	ginEngine := gin.Default()
-	ginEngine.LoadHTMLFiles("templates/index.html", "templates/ground.html")
+	ginEngine.LoadHTMLFiles("templates/index.html", "templates/ground.html", "templates/report.html")
 	ginEngine.GET("/", api.HomeMenu)
 	ginEngine.GET("/session/:sessionID", api.StartSession)
 	ginEngine.POST("/session", api.CreateSession)
+	ginEngine.GET("/session/:sessionID/timeline", api.SessionTimeline)
+	ginEngine.GET("/session/:sessionID/code", api.SessionCode)
+	ginEngine.GET("/session/:sessionID/report", api.SessionReport)
 	ginEngine.GET("/ws", api.LiveStreamCoding)
+	ginEngine.GET("/ws/playback", api.PlaybackSession)
+	ginEngine.GET("/protocol/schema.json", api.ProtocolSchema)
+	ginEngine.GET("/metrics", api.RequireAdmin, api.HubMetrics)
+	ginEngine.GET("/archive", api.RequireAdmin, api.ListArchive)
+	ginEngine.GET("/archive/:sessionID", api.RequireAdmin, api.GetArchivedInterview)
+	ginEngine.GET("/problems", api.RequireAdmin, api.ListProblems)
+	ginEngine.POST("/problems", api.RequireAdmin, api.CreateProblem)
+	ginEngine.POST("/problems/import", api.RequireAdmin, api.ImportProblem)
+	ginEngine.GET("/problems/:problemID", api.RequireAdmin, api.GetProblem)
+	ginEngine.PUT("/problems/:problemID", api.RequireAdmin, api.UpdateProblem)
+	ginEngine.DELETE("/problems/:problemID", api.RequireAdmin, api.DeleteProblem)
 
 	s := &http.Server{
 		Addr:           ":8000",
@@ -39,3 +73,44 @@ func main() {
 	_ = s.ListenAndServe()
 
 }
+
+// importProblems adds Polygon packages or ICPC problem archives, as
+// directories or zip files, to the problem bank.
+func importProblems(args []string) {
+	flags := flag.NewFlagSet("import-problem", flag.ExitOnError)
+	difficulty := flags.String("difficulty", "medium", "difficulty of the imported problems")
+	_ = flags.Parse(args)
+	if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: import-problem [-difficulty easy|medium|hard] <package>...")
+		os.Exit(2)
+	}
+
+	src.Config.SetupEnv()
+	resources.SetupDatabase()
+	resources.SetupProblemBank()
+
+	failed := false
+	for _, name := range flags.Args() {
+		fsys, closer, err := resources.OpenProblemPackage(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			failed = true
+			continue
+		}
+		result, err := resources.ImportProblemPackage(context.Background(), fsys, resources.ImportOptions{Difficulty: *difficulty})
+		_ = closer.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("%s: imported %s package as %s (%q, %d tests)\n",
+			name, result.Format, result.Problem.ID, result.Problem.Title, len(result.Problem.TestCases))
+		for _, warning := range result.Warnings {
+			fmt.Printf("  warning: %s\n", warning)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"CodeStream/src/resources"
+	"log"
+	"sync"
+)
+
+// idempotentMessages are harmless to apply twice, so they are not recorded.
+var idempotentMessages = map[string]bool{
+	"cursor_select": true,
+	"refresh":       true,
+	"focus_lost":    true,
+	"focus_gained":  true,
+	"follow":        true,
+}
+
+// pendingRequests are the messages a client sent that are recorded but not
+// answered yet. Messages are recorded in Redis by participant rather than by
+// connection, so one resent after a reconnect is caught too.
+type pendingRequests struct {
+	mu  sync.Mutex
+	ids map[string]bool
+}
+
+func newPendingRequests() *pendingRequests {
+	return &pendingRequests{ids: make(map[string]bool)}
+}
+
+func (p *pendingRequests) add(requestID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ids[requestID] = true
+}
+
+// take reports whether requestID was pending and stops tracking it.
+func (p *pendingRequests) take(requestID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pending := p.ids[requestID]
+	delete(p.ids, requestID)
+	return pending
+}
+
+// participant names whose messages are recorded together: the participant ID
+// the browser keeps across reconnects, or the username without one.
+func (c *Client) participant() string {
+	if c.Identity.ID != "" {
+		return "id:" + c.Identity.ID
+	}
+	return "user:" + c.Username
+}
+
+// claimRequest records msg as received. It reports false if msg was received
+// before, sending the reply again if it was answered already; a resend of a
+// message still being handled is dropped, since its reply is on the way.
+func (c *Client) claimRequest(msg Message) bool {
+	if idempotentMessages[msg.Type] {
+		return true
+	}
+	claimed, reply, err := resources.ClaimRequest(c.Hub.Interview.Cache, c.Hub.SessionID, c.participant(), msg.ID)
+	if err != nil {
+		log.Printf("Error recording message %s of session %s: %v", msg.ID, c.Hub.SessionID, err)
+		return true
+	}
+	if !claimed {
+		if len(reply) > 0 {
+			c.send(reply)
+		}
+		return false
+	}
+	c.pending.add(msg.ID)
+	return true
+}
+
+// acknowledge confirms that the message requestID was committed at version.
+// Clients on protocol 1 are not acknowledged.
+func (c *Client) acknowledge(requestID string, version int64) {
+	if c.ProtocolVersion < 2 {
+		return
+	}
+	reply := encodeMessage("ack", AckData{RequestID: requestID, Version: version})
+	if c.pending.take(requestID) {
+		if err := resources.RecordReply(c.Hub.Interview.Cache, c.Hub.SessionID, c.participant(), requestID, reply); err != nil {
+			log.Printf("Error recording ack of %s in session %s: %v", requestID, c.Hub.SessionID, err)
+		}
+	}
+	c.send(reply)
+}
+
+// reject reports that the message requestID was not applied, as a typed nack
+// on protocol 2 and as an error message on protocol 1. The message may be
+// resent.
+func (c *Client) reject(requestID string, code string, message string) {
+	if c.pending.take(requestID) {
+		if err := resources.ForgetRequest(c.Hub.Interview.Cache, c.Hub.SessionID, c.participant(), requestID); err != nil {
+			log.Printf("Error forgetting message %s of session %s: %v", requestID, c.Hub.SessionID, err)
+		}
+	}
+	if c.ProtocolVersion < 2 {
+		c.sendError(requestID, code, message)
+		return
+	}
+	c.sendMessage("nack", NackData{
+		RequestID: requestID,
+		Code:      code,
+		Message:   message,
+		Version:   c.Hub.currentVersion(),
+	})
+}
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"CodeStream/src"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdmin lets a request through only when it carries ADMIN_TOKEN as a
+// bearer token. With no ADMIN_TOKEN configured admin endpoints are disabled.
+func RequireAdmin(c *gin.Context) {
+	if !isAdmin(c) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	c.Next()
+}
+
+func isAdmin(c *gin.Context) bool {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return src.Config.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(src.Config.AdminToken)) == 1
+}
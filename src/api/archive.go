@@ -0,0 +1,50 @@
+package api
+
+import (
+	"CodeStream/src/resources"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func requireArchive(c *gin.Context) bool {
+	if resources.Archive == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Archive is not configured"})
+		return false
+	}
+	return true
+}
+
+// ListArchive lists archived interviews, most recently ended first, paged
+// with the limit and offset query parameters.
+func ListArchive(c *gin.Context) {
+	if !requireArchive(c) {
+		return
+	}
+
+	limit, offset := resources.ArchivePage(c.Query("limit"), c.Query("offset"))
+	interviews, err := resources.Archive.List(c.Request.Context(), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"interviews": interviews, "limit": limit, "offset": offset})
+}
+
+func GetArchivedInterview(c *gin.Context) {
+	if !requireArchive(c) {
+		return
+	}
+
+	interview, err := resources.Archive.Get(c.Request.Context(), c.Param("sessionID"))
+	if errors.Is(err, resources.ErrArchiveNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, interview)
+}
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"CodeStream/src"
+	"CodeStream/src/resources"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// maxPendingPatches bounds the patches a hub holds between flushes. Patches
+// beyond it are nacked so clients back off instead of growing the queue.
+const maxPendingPatches = 512
+
+var errHubOverloaded = errors.New("session is busy, retry the patch")
+
+// pendingPatch is a patch waiting for the next flush. Patches marked resync
+// are followed by a session_init to their sender once committed, because
+// clients skip their own patches in batches. Undo and redo patches carry
+// their source and the version they invert. Patches marked paste are recorded
+// as pastes once committed.
+type pendingPatch struct {
+	client    *Client
+	requestID string
+	data      CodePatchData
+	source    string
+	reverts   int64
+	resync    bool
+	paste     bool
+}
+
+// hubBatch collects the patches and cursor moves received during one batch
+// window. Patches are committed together in one Redis transaction; only the
+// latest cursor of each participant survives.
+type hubBatch struct {
+	mu        sync.Mutex
+	flushMu   sync.Mutex
+	patches   []pendingPatch
+	cursors   map[string]CursorSelectEvent
+	scheduled bool
+}
+
+func (h *Hub) queuePatch(pending pendingPatch) error {
+	h.batch.mu.Lock()
+	defer h.batch.mu.Unlock()
+
+	if len(h.batch.patches) >= maxPendingPatches {
+		h.metrics.OverloadRejections.Add(1)
+		return errHubOverloaded
+	}
+	h.batch.patches = append(h.batch.patches, pending)
+	h.scheduleFlushLocked()
+	return nil
+}
+
+func (h *Hub) queueCursor(event CursorSelectEvent) {
+	h.batch.mu.Lock()
+	defer h.batch.mu.Unlock()
+
+	h.metrics.CursorMoves.Add(1)
+	if h.batch.cursors == nil {
+		h.batch.cursors = make(map[string]CursorSelectEvent)
+	}
+	h.batch.cursors[event.Username] = event
+	h.scheduleFlushLocked()
+}
+
+func (h *Hub) scheduleFlushLocked() {
+	if h.batch.scheduled {
+		return
+	}
+	h.batch.scheduled = true
+	time.AfterFunc(src.Config.PatchBatchWindow, h.flush)
+}
+
+// flush commits the queued patches, answers their senders and broadcasts the
+// committed patches and latest cursors as a single batch.
+func (h *Hub) flush() {
+	h.batch.flushMu.Lock()
+	defer h.batch.flushMu.Unlock()
+
+	h.batch.mu.Lock()
+	patches := h.batch.patches
+	cursors := h.batch.cursors
+	h.batch.patches = nil
+	h.batch.cursors = nil
+	h.batch.scheduled = false
+	h.batch.mu.Unlock()
+
+	if len(patches) == 0 && len(cursors) == 0 {
+		return
+	}
+	started := time.Now()
+
+	batch := BatchData{}
+	accepted := make([]pendingPatch, 0, len(patches))
+	resync := make(map[*Client]bool)
+
+	if len(patches) > 0 {
+		codePatches := make([]resources.CodePatch, len(patches))
+		for i, pending := range patches {
+			codePatches[i] = pending.data.toCodePatch()
+			codePatches[i].Author = pending.client.Username
+			codePatches[i].Source = pending.source
+			codePatches[i].Reverts = pending.reverts
+		}
+
+		h.interviewMu.Lock()
+		results, err := h.Interview.AddCodePatches(codePatches)
+		h.interviewMu.Unlock()
+		h.metrics.RedisCommits.Add(1)
+
+		for i, pending := range patches {
+			switch {
+			case err != nil:
+				log.Printf("Error committing patches for session %s: %v", h.SessionID, err)
+				h.metrics.RejectedPatches.Add(1)
+				pending.client.reject(pending.requestID, errCodePatch, fmt.Sprintf("failed to add code patch: %v", err))
+			case errors.Is(results[i].Err, resources.ErrVersionMismatch):
+				h.metrics.RejectedPatches.Add(1)
+				resync[pending.client] = true
+				if pending.client.ProtocolVersion >= 2 {
+					pending.client.reject(pending.requestID, errVersionMismatch, results[i].Err.Error())
+				}
+			case errors.Is(results[i].Err, resources.ErrUndoStale):
+				h.metrics.RejectedPatches.Add(1)
+				pending.client.reject(pending.requestID, errUndo, results[i].Err.Error())
+			case results[i].Err != nil:
+				h.metrics.RejectedPatches.Add(1)
+				pending.client.reject(pending.requestID, errCodePatch, results[i].Err.Error())
+			default:
+				h.metrics.CommittedPatches.Add(1)
+				pending.data.Version = results[i].Version
+				accepted = append(accepted, pending)
+				if pending.resync {
+					resync[pending.client] = true
+				}
+				batch.Patches = append(batch.Patches, CodePatchEvent{
+					Username: pending.client.Username,
+					Version:  results[i].Version,
+					Op:       pending.data.Op,
+					StartPos: pending.data.StartPos,
+					EndPos:   pending.data.EndPos,
+					Content:  pending.data.Content,
+				})
+			}
+		}
+	}
+
+	for _, cursor := range cursors {
+		batch.Cursors = append(batch.Cursors, cursor)
+	}
+
+	if len(batch.Patches) > 0 || len(batch.Cursors) > 0 {
+		h.broadcastBatch(batch)
+	}
+	for _, pending := range accepted {
+		pending.client.acknowledge(pending.requestID, pending.data.Version)
+		if pending.paste {
+			h.recordPaste(pending.client, pending.data)
+		}
+	}
+	for client := range resync {
+		client.sendCurrentState()
+	}
+
+	h.metrics.recordFlush(len(patches), len(batch.Cursors), time.Since(started))
+}
+
+func (h *Hub) broadcastBatch(batch BatchData) {
+	payload := encodeMessage("batch", batch)
+	if payload == nil {
+		return
+	}
+	h.deliverBatchLocal(batch, payload)
+
+	if err := resources.PublishSessionEvent(h.Interview.Cache, h.SessionID, "", payload); err != nil {
+		log.Printf("Error publishing batch for session %s: %v", h.SessionID, err)
+	}
+}
+
+// deliverBatchLocal sends the batch as one frame to clients on protocol 3 and
+// above, and as separate code_patch and cursor_select messages, minus their
+// own, to older clients.
+func (h *Hub) deliverBatchLocal(batch BatchData, payload []byte) {
+	batchFrame := newOutFrame(payload)
+
+	patchFrames := make([]*outFrame, len(batch.Patches))
+	cursorFrames := make([]*outFrame, len(batch.Cursors))
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, client := range h.Clients {
+		if client.ProtocolVersion >= 3 {
+			h.enqueue(client, batchFrame)
+			continue
+		}
+		for i, patch := range batch.Patches {
+			if patch.Username == client.Username {
+				continue
+			}
+			if patchFrames[i] == nil {
+				patchFrames[i] = newOutFrame(encodeMessage("code_patch", patch))
+			}
+			h.enqueue(client, patchFrames[i])
+		}
+		for i, cursor := range batch.Cursors {
+			if cursor.Username == client.Username {
+				continue
+			}
+			if cursorFrames[i] == nil {
+				cursorFrames[i] = newOutFrame(encodeMessage("cursor_select", cursor))
+			}
+			h.enqueue(client, cursorFrames[i])
+		}
+	}
+}
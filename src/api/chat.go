@@ -0,0 +1,76 @@
+package api
+
+import (
+	"CodeStream/src/resources"
+	"errors"
+	"log"
+	"time"
+)
+
+// A client may send chatBurst chat messages at once, then one every
+// chatInterval.
+const (
+	chatBurst    = 5
+	chatInterval = time.Second
+)
+
+// chatLimiter is a token bucket limiting how fast a client chats. It is only
+// touched by the client's read loop.
+type chatLimiter struct {
+	tokens float64
+	last   time.Time
+}
+
+func (l *chatLimiter) allow(now time.Time) bool {
+	if l.last.IsZero() {
+		l.tokens = chatBurst
+	} else {
+		l.tokens = min(chatBurst, l.tokens+float64(now.Sub(l.last))/float64(chatInterval))
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// processChatMessage stores a chat message and sends it to everyone, or to
+// the interviewers only when it is private.
+func (c *Client) processChatMessage(requestID string, req ChatMessageData) {
+	if req.Private && !c.hasInterviewerRole() {
+		c.reject(requestID, errForbidden, "only interviewers can send private messages")
+		return
+	}
+	if !c.chat.allow(time.Now()) {
+		c.reject(requestID, errRateLimited, "Rate limit exceeded")
+		return
+	}
+
+	message, err := c.Hub.Interview.AddChatMessage(c.Username, req.Text, req.Private)
+	if err != nil {
+		if !errors.Is(err, resources.ErrInvalidChatMessage) {
+			log.Printf("Error saving chat message for session %s: %v", c.Hub.SessionID, err)
+		}
+		c.reject(requestID, errChat, err.Error())
+		return
+	}
+
+	msg := encodeMessage("chat_message", message)
+	if message.Private {
+		c.Hub.broadcastInterviewers(msg)
+	} else {
+		c.Hub.broadcastAll(msg)
+	}
+	c.acknowledge(requestID, c.Hub.currentVersion())
+}
+
+// chatHistory returns the chat the client may see.
+func (c *Client) chatHistory() []resources.ChatMessage {
+	messages, err := c.Hub.Interview.ChatHistory(c.hasInterviewerRole())
+	if err != nil {
+		log.Printf("Error loading chat of session %s: %v", c.Hub.SessionID, err)
+		return nil
+	}
+	return messages
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChatLimiterAllow(t *testing.T) {
+	start := time.Unix(1000, 0)
+	tests := []struct {
+		name  string
+		calls []time.Duration
+		want  []bool
+	}{
+		{
+			name:  "first message",
+			calls: []time.Duration{0},
+			want:  []bool{true},
+		},
+		{
+			name:  "burst",
+			calls: []time.Duration{0, 0, 0, 0, 0, 0},
+			want:  []bool{true, true, true, true, true, false},
+		},
+		{
+			name:  "refills one per interval",
+			calls: []time.Duration{0, 0, 0, 0, 0, chatInterval / 2, chatInterval, chatInterval},
+			want:  []bool{true, true, true, true, true, false, true, false},
+		},
+		{
+			name:  "refill stops at the burst",
+			calls: []time.Duration{0, 0, 0, 0, 0, time.Minute, time.Minute, time.Minute, time.Minute, time.Minute, time.Minute},
+			want:  []bool{true, true, true, true, true, true, true, true, true, true, false},
+		},
+		{
+			name:  "steady pace",
+			calls: []time.Duration{0, chatInterval, 2 * chatInterval, 3 * chatInterval, 4 * chatInterval, 5 * chatInterval, 6 * chatInterval},
+			want:  []bool{true, true, true, true, true, true, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var limiter chatLimiter
+			for i, offset := range tt.calls {
+				if got := limiter.allow(start.Add(offset)); got != tt.want[i] {
+					t.Errorf("call %d at +%v: allow() = %v, want %v", i, offset, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
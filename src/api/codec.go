@@ -0,0 +1,120 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Websocket subprotocols a client can request. Messages have the same shape in
+// both, only the framing differs: JSON in text frames or MessagePack in binary
+// frames. Clients that request no subprotocol get JSON.
+const (
+	subprotocolJSON    = "codestream.json"
+	subprotocolMsgpack = "codestream.msgpack"
+)
+
+// codec converts between the JSON form messages take inside the server (and
+// on Redis) and the form a client receives on the wire.
+type codec interface {
+	FrameType() int
+	Encode(jsonMsg []byte) ([]byte, error)
+	Decode(frame []byte) ([]byte, error)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) FrameType() int {
+	return websocket.TextMessage
+}
+
+func (jsonCodec) Encode(jsonMsg []byte) ([]byte, error) {
+	return jsonMsg, nil
+}
+
+func (jsonCodec) Decode(frame []byte) ([]byte, error) {
+	return frame, nil
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) FrameType() int {
+	return websocket.BinaryMessage
+}
+
+func (msgpackCodec) Encode(jsonMsg []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(jsonMsg))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return msgpack.Marshal(compactNumbers(value))
+}
+
+func (msgpackCodec) Decode(frame []byte) ([]byte, error) {
+	var value interface{}
+	if err := msgpack.Unmarshal(frame, &value); err != nil {
+		return nil, fmt.Errorf("invalid msgpack frame: %w", err)
+	}
+	return json.Marshal(value)
+}
+
+// compactNumbers turns json.Number values into int64 where possible so
+// MessagePack can use its short integer encodings.
+func compactNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = compactNumbers(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = compactNumbers(item)
+		}
+		return v
+	}
+	return value
+}
+
+func codecForSubprotocol(subprotocol string) codec {
+	if subprotocol == subprotocolMsgpack {
+		return msgpackCodec{}
+	}
+	return jsonCodec{}
+}
+
+// outFrame is a message queued for delivery. A frame broadcast to many
+// clients is transcoded to MessagePack at most once.
+type outFrame struct {
+	json []byte
+
+	msgpackOnce sync.Once
+	msgpack     []byte
+	msgpackErr  error
+}
+
+func newOutFrame(msg []byte) *outFrame {
+	return &outFrame{json: msg}
+}
+
+func (f *outFrame) encode(c codec) ([]byte, error) {
+	if _, ok := c.(msgpackCodec); !ok {
+		return c.Encode(f.json)
+	}
+	f.msgpackOnce.Do(func() {
+		f.msgpack, f.msgpackErr = c.Encode(f.json)
+	})
+	return f.msgpack, f.msgpackErr
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"io"
+	"testing"
+)
+
+// Gorilla compresses each message on its own with this level and strips the
+// same trailing flush marker, so the sizes below match what goes on the wire.
+const benchCompressionLevel = 1
+
+var benchKeystroke = CodePatchData{
+	Version:  143,
+	Op:       "add",
+	StartPos: 1024,
+	Content:  "a",
+}
+
+func benchInboundFrame() []byte {
+	data, _ := json.Marshal(benchKeystroke)
+	msg, _ := json.Marshal(Message{Type: "code_patch", ID: "User42-1729512345678-97", Data: data})
+	return msg
+}
+
+func benchOutboundFrame() []byte {
+	return encodeMessage("code_patch", CodePatchEvent{
+		Username: "User42",
+		Version:  benchKeystroke.Version,
+		Op:       benchKeystroke.Op,
+		StartPos: benchKeystroke.StartPos,
+		Content:  benchKeystroke.Content,
+	})
+}
+
+func deflateFrame(frame []byte) []byte {
+	var buf bytes.Buffer
+	fw, _ := flate.NewWriter(&buf, benchCompressionLevel)
+	_, _ = fw.Write(frame)
+	_ = fw.Flush()
+	return bytes.TrimSuffix(buf.Bytes(), []byte{0x00, 0x00, 0xff, 0xff})
+}
+
+func inflateFrame(frame []byte) ([]byte, error) {
+	return io.ReadAll(flate.NewReader(io.MultiReader(
+		bytes.NewReader(frame),
+		bytes.NewReader([]byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}),
+	)))
+}
+
+var codecBenchCases = []struct {
+	name     string
+	codec    codec
+	compress bool
+}{
+	{name: "json", codec: jsonCodec{}},
+	{name: "json+deflate", codec: jsonCodec{}, compress: true},
+	{name: "msgpack", codec: msgpackCodec{}},
+	{name: "msgpack+deflate", codec: msgpackCodec{}, compress: true},
+}
+
+// BenchmarkCodec compares the framings a client can negotiate for a single
+// keystroke: the bytes of the client's code_patch and of the broadcast every
+// other participant receives, and the CPU the server spends decoding the one
+// and encoding the other.
+//
+//	go test ./src/api -run '^$' -bench Codec -benchmem
+func BenchmarkCodec(b *testing.B) {
+	for _, bc := range codecBenchCases {
+		inFrame, err := bc.codec.Encode(benchInboundFrame())
+		if err != nil {
+			b.Fatalf("%s: %v", bc.name, err)
+		}
+		outFrame, err := bc.codec.Encode(benchOutboundFrame())
+		if err != nil {
+			b.Fatalf("%s: %v", bc.name, err)
+		}
+		if bc.compress {
+			inFrame = deflateFrame(inFrame)
+			outFrame = deflateFrame(outFrame)
+		}
+
+		b.Run(bc.name+"/decode", func(b *testing.B) {
+			b.ReportAllocs()
+			b.ReportMetric(float64(len(inFrame)), "bytes/frame")
+			for i := 0; i < b.N; i++ {
+				frame := inFrame
+				if bc.compress {
+					if frame, err = inflateFrame(inFrame); err != nil {
+						b.Fatal(err)
+					}
+				}
+				data, err := bc.codec.Decode(frame)
+				if err != nil {
+					b.Fatal(err)
+				}
+				var msg Message
+				var req CodePatchData
+				if err := json.Unmarshal(data, &msg); err != nil {
+					b.Fatal(err)
+				}
+				if err := decodeMessageData(msg, &req); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(bc.name+"/encode", func(b *testing.B) {
+			b.ReportAllocs()
+			b.ReportMetric(float64(len(outFrame)), "bytes/frame")
+			for i := 0; i < b.N; i++ {
+				frame, err := newOutFrame(benchOutboundFrame()).encode(bc.codec)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if bc.compress {
+					deflateFrame(frame)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"CodeStream/src/resources"
+	"errors"
+	"log"
+)
+
+// processCommentCreate starts a comment thread on a range of the code.
+func (c *Client) processCommentCreate(requestID string, req CommentCreateData) {
+	if c.rejectMuted(requestID) {
+		return
+	}
+	thread, err := c.Hub.Interview.CreateCommentThread(req.Version, req.StartPos, req.EndPos, c.Username, req.Text)
+	c.finishComment(requestID, thread, err)
+}
+
+// processCommentReply adds a comment to a thread.
+func (c *Client) processCommentReply(requestID string, req CommentReplyData) {
+	if c.rejectMuted(requestID) {
+		return
+	}
+	thread, err := c.Hub.Interview.ReplyToThread(req.ThreadID, c.Username, req.Text)
+	c.finishComment(requestID, thread, err)
+}
+
+// processCommentResolve resolves or reopens a thread.
+func (c *Client) processCommentResolve(requestID string, req CommentResolveData) {
+	if c.rejectMuted(requestID) {
+		return
+	}
+	thread, err := c.Hub.Interview.ResolveThread(req.ThreadID, c.Username, req.Resolved)
+	c.finishComment(requestID, thread, err)
+}
+
+// finishComment sends the changed thread to everyone. Comments stay open
+// after the session ends, so the archive is updated with them.
+func (c *Client) finishComment(requestID string, thread resources.CommentThread, err error) {
+	if err != nil {
+		if !errors.Is(err, resources.ErrInvalidComment) && !errors.Is(err, resources.ErrThreadNotFound) &&
+			!errors.Is(err, resources.ErrTooManyComments) {
+			log.Printf("Error updating comments of session %s: %v", c.Hub.SessionID, err)
+		}
+		c.reject(requestID, errComment, err.Error())
+		return
+	}
+	c.Hub.broadcastAll(encodeMessage("comment_thread", thread))
+	c.acknowledge(requestID, c.Hub.currentVersion())
+	c.Hub.rearchive()
+}
+
+// commentThreads returns the session's comment threads for session_init.
+func (h *Hub) commentThreads() []resources.CommentThread {
+	threads, err := h.Interview.CommentThreads()
+	if err != nil {
+		log.Printf("Error loading comments of session %s: %v", h.SessionID, err)
+		return nil
+	}
+	return threads
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"CodeStream/src/resources"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+)
+
+var errNotDriver = errors.New("someone else is driving the editor")
+
+// hubDrive caches the session's drive lock. Every change is broadcast as
+// drive, and other instances reload it from Redis.
+type hubDrive struct {
+	lock resources.DriveLock
+}
+
+func (h *Hub) driveLock() resources.DriveLock {
+	h.interviewMu.Lock()
+	defer h.interviewMu.Unlock()
+	return h.drive.lock
+}
+
+func (h *Hub) applyDrive(lock resources.DriveLock) {
+	h.interviewMu.Lock()
+	defer h.interviewMu.Unlock()
+	h.drive.lock = lock
+}
+
+func (h *Hub) loadDrive() error {
+	lock, err := h.Interview.DriveLock()
+	if err != nil {
+		return err
+	}
+	h.applyDrive(lock)
+	return nil
+}
+
+// checkDriver refuses changes to the code from anyone but the driver while
+// the editor is locked.
+func (c *Client) checkDriver() error {
+	if lock := c.Hub.driveLock(); !lock.CanEdit(c.Username) {
+		return fmt.Errorf("%w: %s", errNotDriver, lock.Driver)
+	}
+	return nil
+}
+
+// processDrive changes who may edit the code. Interviewers lock and unlock
+// the editor, grant the wheel to a participant and revoke it, taking it back
+// themselves; anyone can ask to drive.
+func (c *Client) processDrive(requestID string, action string, req DriveData) {
+	h := c.Hub
+	if action != "drive_request" && !c.isInterviewer() {
+		c.reject(requestID, errForbidden, "only interviewers can hand over the editor")
+		return
+	}
+	if action == "drive_request" && c.rejectMuted(requestID) {
+		return
+	}
+	if action == "drive_grant" && !h.present(req.Username) {
+		c.reject(requestID, errDrive, fmt.Sprintf("%s is not in the session", req.Username))
+		return
+	}
+
+	lock, err := h.Interview.UpdateDriveLock(c.Username, func(lock *resources.DriveLock) error {
+		switch action {
+		case "drive_lock":
+			if !req.Locked {
+				lock.Unlock()
+			} else if !lock.Locked {
+				lock.Lock(c.Username)
+			}
+		case "drive_request":
+			return lock.Request(c.Username)
+		case "drive_grant":
+			if !lock.Locked {
+				lock.Lock(req.Username)
+			}
+			lock.Grant(req.Username)
+		case "drive_revoke":
+			if !lock.Locked {
+				return fmt.Errorf("%w: the editor is not locked", resources.ErrInvalidDrive)
+			}
+			lock.Grant(c.Username)
+		}
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, resources.ErrInvalidDrive) {
+			log.Printf("Error updating drive lock of session %s: %v", h.SessionID, err)
+		}
+		c.reject(requestID, errDrive, err.Error())
+		return
+	}
+
+	h.applyDrive(lock)
+	h.broadcastAll(encodeMessage("drive", lock))
+	c.acknowledge(requestID, h.currentVersion())
+}
+
+// releaseDrive takes a participant who left off the drive lock, so that
+// whoever joins next under the same username is not handed the wheel.
+func (h *Hub) releaseDrive(client *Client) {
+	if lock := h.driveLock(); lock.Driver != client.Username && !slices.Contains(lock.Requests, client.Username) {
+		return
+	}
+
+	lock, err := h.Interview.UpdateDriveLock(client.Username, func(lock *resources.DriveLock) error {
+		if !lock.Release(client.Username) {
+			return fmt.Errorf("%w: %s holds no part of the lock", resources.ErrInvalidDrive, client.Username)
+		}
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, resources.ErrInvalidDrive) {
+			log.Printf("Error releasing drive lock of session %s: %v", h.SessionID, err)
+		}
+		return
+	}
+
+	h.applyDrive(lock)
+	h.broadcastAll(encodeMessage("drive", lock))
+}
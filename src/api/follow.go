@@ -0,0 +1,136 @@
+package api
+
+import (
+	"CodeStream/src/resources"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+// followInterval is the shortest time between two follow_update messages
+// about the same participant.
+const followInterval = 200 * time.Millisecond
+
+// hubFollow relays the selection and viewport of followed participants to
+// their followers.
+type hubFollow struct {
+	mu        sync.Mutex
+	followers map[*Client]string
+	latest    map[string]CursorSelectEvent
+	dirty     map[string]bool
+	scheduled bool
+}
+
+// processFollow makes the client follow req.Username, or stop following
+// when it is empty. The latest known position of the followed participant is
+// sent right away.
+func (c *Client) processFollow(requestID string, req FollowData) {
+	h := c.Hub
+	if req.Username == c.Username {
+		c.reject(requestID, errFollow, "cannot follow yourself")
+		return
+	}
+
+	h.follow.mu.Lock()
+	if h.follow.followers == nil {
+		h.follow.followers = make(map[*Client]string)
+	}
+	if req.Username == "" {
+		delete(h.follow.followers, c)
+	} else {
+		h.follow.followers[c] = req.Username
+	}
+	latest, known := h.follow.latest[req.Username]
+	h.follow.mu.Unlock()
+
+	c.acknowledge(requestID, h.currentVersion())
+	if req.Username != "" && known {
+		c.sendMessage("follow_update", latest)
+	}
+}
+
+// queueFollow records a participant's latest selection and viewport and
+// schedules relaying it to their followers.
+func (h *Hub) queueFollow(event CursorSelectEvent) {
+	h.follow.mu.Lock()
+	defer h.follow.mu.Unlock()
+
+	h.rememberFollowed(event)
+	if h.follow.dirty == nil {
+		h.follow.dirty = make(map[string]bool)
+	}
+	h.follow.dirty[event.Username] = true
+	if !h.follow.scheduled {
+		h.follow.scheduled = true
+		time.AfterFunc(followInterval, h.flushFollow)
+	}
+}
+
+func (h *Hub) rememberFollowed(event CursorSelectEvent) {
+	if h.follow.latest == nil {
+		h.follow.latest = make(map[string]CursorSelectEvent)
+	}
+	h.follow.latest[event.Username] = event
+}
+
+// flushFollow sends the participants' positions that changed since the last
+// flush to their followers here and on other instances.
+func (h *Hub) flushFollow() {
+	h.follow.mu.Lock()
+	updates := make([]CursorSelectEvent, 0, len(h.follow.dirty))
+	for username := range h.follow.dirty {
+		updates = append(updates, h.follow.latest[username])
+	}
+	h.follow.dirty = nil
+	h.follow.scheduled = false
+	h.follow.mu.Unlock()
+
+	for _, update := range updates {
+		msg := encodeMessage("follow_update", update)
+		h.deliverFollowers(update.Username, msg)
+		if err := resources.PublishFollowEvent(h.Interview.Cache, h.SessionID, update.Username, msg); err != nil {
+			log.Printf("Error publishing event for session %s: %v", h.SessionID, err)
+		}
+	}
+}
+
+// deliverFollowers sends msg to the local clients following leader.
+func (h *Hub) deliverFollowers(leader string, msg []byte) {
+	h.follow.mu.Lock()
+	followers := make([]*Client, 0)
+	for client, followed := range h.follow.followers {
+		if followed == leader {
+			followers = append(followers, client)
+		}
+	}
+	h.follow.mu.Unlock()
+
+	frame := newOutFrame(msg)
+	for _, client := range followers {
+		h.enqueue(client, frame)
+	}
+}
+
+// deliverRemoteFollow delivers a follow_update published by another
+// instance, keeping it as the participant's latest position.
+func (h *Hub) deliverRemoteFollow(leader string, msg []byte) {
+	var payload Message
+	var event CursorSelectEvent
+	if json.Unmarshal(msg, &payload) == nil && decodeMessageData(payload, &event) == nil {
+		h.follow.mu.Lock()
+		h.rememberFollowed(event)
+		h.follow.mu.Unlock()
+	}
+	h.deliverFollowers(leader, msg)
+}
+
+// forgetFollow drops a client that left, as a follower and as someone to
+// follow.
+func (h *Hub) forgetFollow(client *Client) {
+	h.follow.mu.Lock()
+	defer h.follow.mu.Unlock()
+	delete(h.follow.followers, client)
+	delete(h.follow.latest, client.Username)
+	delete(h.follow.dirty, client.Username)
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"CodeStream/src/resources"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionCode returns the session's code at the version query parameter, or
+// the current code without one.
+func SessionCode(c *gin.Context) {
+	sessionID := c.Param("sessionID")
+
+	interview, err := resources.GetInterviewSession(resources.NewCacheContext(), sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session does not exist"})
+		return
+	}
+
+	version := interview.Version
+	if versionParam := c.Query("version"); versionParam != "" {
+		version, err = strconv.ParseInt(versionParam, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "version must be an integer"})
+			return
+		}
+	}
+
+	code, err := interview.CodeAt(version)
+	if errors.Is(err, resources.ErrVersionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"version":    version,
+		"code":       code,
+	})
+}
+
+// documentReplacePatch returns the patch turning current into target.
+func documentReplacePatch(current string, target string) (CodePatchData, bool) {
+	if current == target {
+		return CodePatchData{}, false
+	}
+
+	currentLen := len([]rune(current))
+	switch {
+	case currentLen == 0:
+		return CodePatchData{Op: "add", StartPos: 0, Content: target}, true
+	case target == "":
+		return CodePatchData{Op: "remove", StartPos: 0, EndPos: currentLen}, true
+	default:
+		return CodePatchData{Op: "replace", StartPos: 0, EndPos: currentLen, Content: target}, true
+	}
+}
+
+// processRestoreVersion commits the code of an earlier version as a new
+// version. The restoring client is resynced once the change is committed.
+func (c *Client) processRestoreVersion(requestID string, req RestoreVersionData) error {
+	currentVersion := c.Hub.currentVersion()
+
+	target, err := c.Hub.Interview.CodeAt(req.Version)
+	if err != nil {
+		return err
+	}
+	current, err := c.Hub.Interview.CodeAt(currentVersion)
+	if err != nil {
+		return err
+	}
+
+	patch, changed := documentReplacePatch(current, target)
+	if !changed {
+		c.acknowledge(requestID, currentVersion)
+		return nil
+	}
+	patch.Version = currentVersion + 1
+
+	return c.Hub.queuePatch(pendingPatch{
+		client:    c,
+		requestID: requestID,
+		data:      patch,
+		resync:    true,
+	})
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"CodeStream/src"
+	"CodeStream/src/resources"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// isPaste reports whether a patch inserts enough text at once to have been
+// pasted rather than typed.
+func isPaste(req CodePatchData) bool {
+	if req.Op != "add" && req.Op != "replace" {
+		return false
+	}
+	return src.Config.PasteThreshold > 0 && utf8.RuneCountInString(req.Content) >= src.Config.PasteThreshold
+}
+
+// recordPaste records a committed paste on the timeline and shows it to the
+// interviewers.
+func (h *Hub) recordPaste(c *Client, data CodePatchData) {
+	paste := resources.PasteEventData{
+		Version:  data.Version,
+		StartPos: data.StartPos,
+		Chars:    utf8.RuneCountInString(data.Content),
+		Lines:    strings.Count(data.Content, "\n") + 1,
+	}
+	h.recordEvent(resources.TimelinePaste, c.Username, paste)
+	h.broadcastInterviewers(encodeMessage("integrity", IntegrityEventData{
+		Username: c.Username,
+		Type:     resources.TimelinePaste,
+		At:       time.Now().UnixMilli(),
+		Chars:    paste.Chars,
+		Lines:    paste.Lines,
+	}))
+}
+
+// processFocus records a participant leaving or returning to the session's
+// tab. Everyone shows as idle while away, but only candidates' focus changes
+// are integrity signals. Repeated reports of the same state are acknowledged
+// and ignored.
+func (c *Client) processFocus(requestID string, focused bool) {
+	if c.away == !focused {
+		c.acknowledge(requestID, c.Hub.currentVersion())
+		return
+	}
+	c.away = !focused
+	c.setAway(c.away)
+	if c.hasInterviewerRole() {
+		c.acknowledge(requestID, c.Hub.currentVersion())
+		return
+	}
+
+	eventType := resources.TimelineFocusGained
+	if !focused {
+		eventType = resources.TimelineFocusLost
+	}
+	c.Hub.recordEvent(eventType, c.Username, nil)
+	c.Hub.broadcastInterviewers(encodeMessage("integrity", IntegrityEventData{
+		Username: c.Username,
+		Type:     eventType,
+		At:       time.Now().UnixMilli(),
+	}))
+	c.acknowledge(requestID, c.Hub.currentVersion())
+}
+
+// integrityData summarises every participant's integrity signals so far.
+func (h *Hub) integrityData() []resources.IntegritySummary {
+	timeline, err := resources.GetTimeline(h.Interview.Cache, h.SessionID)
+	if err != nil {
+		log.Printf("Error loading timeline of session %s: %v", h.SessionID, err)
+		return nil
+	}
+	return resources.SummarizeIntegrity(timeline, time.Now().UnixMilli())
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"CodeStream/src"
+	"CodeStream/src/resources"
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// processJudge runs the session's code against every test case of its problem
+// and tells all participants the verdict. Judging is given up after
+// JudgeTimeoutSecond, however many test cases are left.
+func (c *Client) processJudge(requestID string) {
+	problem, err := c.Hub.Interview.Problem()
+	if err != nil {
+		log.Printf("Error loading problem for session %s: %v", c.Hub.SessionID, err)
+		c.reject(requestID, errJudge, "Failed to load the problem")
+		return
+	}
+	if problem == nil {
+		c.reject(requestID, errJudge, "the session has no problem to judge against")
+		return
+	}
+	if !c.Hub.Interview.CanRun() {
+		c.reject(requestID, errRateLimited, "Rate limit exceeded")
+		return
+	}
+
+	c.Hub.interviewMu.Lock()
+	currentCode := c.Hub.Interview.CompactCodePatches()
+	version := c.Hub.Interview.Version
+	lang := c.Hub.Interview.Language
+	c.Hub.interviewMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(c.Hub.Interview.Cache.Ctx, time.Duration(src.Config.JudgeTimeoutSecond)*time.Second)
+	defer cancel()
+	result, err := resources.JudgeSolution(ctx, src.Config.CodeWorkDir, problem, lang, currentCode)
+	if err != nil {
+		if !errors.Is(err, resources.ErrJudgeTimeout) {
+			log.Printf("Error judging code in session %s: %v", c.Hub.SessionID, err)
+		}
+		c.reject(requestID, errJudge, err.Error())
+		return
+	}
+
+	c.Hub.broadcastAll(encodeMessage("judge_result", JudgeResultData{
+		Lang:    lang,
+		Version: version,
+		Verdict: result.Verdict,
+		Passed:  result.Passed,
+		Total:   result.Total,
+		Tests:   result.Tests,
+	}))
+	c.Hub.recordEvent(resources.TimelineJudge, c.Username, resources.JudgeEventData{
+		Language: lang,
+		Version:  version,
+		Result:   result,
+	})
+	c.acknowledge(requestID, c.Hub.currentVersion())
+}
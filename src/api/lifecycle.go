@@ -0,0 +1,159 @@
+package api
+
+import (
+	"CodeStream/src/resources"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var errSessionReadOnly = errors.New("the session has ended and is read-only")
+
+// writeMessages change the session and are refused once it has ended.
+var writeMessages = map[string]bool{
+	"code_patch":      true,
+	"code_run":        true,
+	"judge":           true,
+	"switch_question": true,
+	"statement_patch": true,
+	"edit_lang":       true,
+	"restore_version": true,
+	"undo":            true,
+	"redo":            true,
+	"chat_message":    true,
+}
+
+// rearchiveDelay gathers the changes interviewers make to the notes and the
+// scorecard after the session ended into one update of its archive.
+const rearchiveDelay = 5 * time.Second
+
+// hubLifecycle caches the session's state and runs the timer that starts or
+// ends it on schedule. Every instance hosting the session runs the timer;
+// only the first transition succeeds.
+type hubLifecycle struct {
+	state     resources.SessionLifecycle
+	timer     *time.Timer
+	rearchive *time.Timer
+}
+
+func (h *Hub) lifecycle() resources.SessionLifecycle {
+	h.interviewMu.Lock()
+	defer h.interviewMu.Unlock()
+	return h.sessionState.state
+}
+
+// applyLifecycle stores the session's state and schedules its next automatic
+// transition.
+func (h *Hub) applyLifecycle(lifecycle resources.SessionLifecycle) {
+	h.interviewMu.Lock()
+	defer h.interviewMu.Unlock()
+
+	h.sessionState.state = lifecycle
+	if h.sessionState.timer != nil {
+		h.sessionState.timer.Stop()
+		h.sessionState.timer = nil
+	}
+
+	var at int64
+	var next string
+	switch lifecycle.State {
+	case resources.SessionScheduled:
+		at, next = lifecycle.ScheduledAt, resources.SessionLive
+	case resources.SessionLive:
+		at, next = lifecycle.EndsAt(), resources.SessionEnded
+	}
+	if at == 0 {
+		return
+	}
+	h.sessionState.timer = time.AfterFunc(time.Until(time.UnixMilli(at)), func() {
+		err := h.transitionLifecycle(next, "")
+		if err != nil && !errors.Is(err, resources.ErrInvalidTransition) {
+			log.Printf("Error moving session %s to %s: %v", h.SessionID, next, err)
+		}
+	})
+}
+
+func (h *Hub) stopLifecycle() {
+	h.interviewMu.Lock()
+	defer h.interviewMu.Unlock()
+	if h.sessionState.timer != nil {
+		h.sessionState.timer.Stop()
+	}
+}
+
+// transitionLifecycle moves the session to state and tells every participant.
+// Ended sessions are archived right away.
+func (h *Hub) transitionLifecycle(state string, author string) error {
+	lifecycle, err := h.Interview.TransitionLifecycle(state, author)
+	if err != nil {
+		return err
+	}
+	h.applyLifecycle(lifecycle)
+	h.broadcastAll(encodeMessage("session_state", newSessionStateData(lifecycle)))
+
+	if state == resources.SessionEnded {
+		go h.archiveEnded()
+	}
+	return nil
+}
+
+func (h *Hub) archiveEnded() {
+	if err := resources.ArchiveSession(h.Interview.Cache, h.SessionID, resources.ArchiveEnded); err != nil {
+		log.Printf("Error archiving session %s: %v", h.SessionID, err)
+		return
+	}
+
+	lifecycle, err := h.Interview.Lifecycle()
+	if err != nil || lifecycle.State == h.lifecycle().State {
+		return
+	}
+	h.applyLifecycle(lifecycle)
+	h.broadcastAll(encodeMessage("session_state", newSessionStateData(lifecycle)))
+}
+
+// rearchive updates the archive of an ended session shortly after the last of
+// a series of changes.
+func (h *Hub) rearchive() {
+	if !h.lifecycle().ReadOnly() {
+		return
+	}
+	h.interviewMu.Lock()
+	defer h.interviewMu.Unlock()
+	if h.sessionState.rearchive != nil {
+		h.sessionState.rearchive.Stop()
+	}
+	h.sessionState.rearchive = time.AfterFunc(rearchiveDelay, h.archiveEnded)
+}
+
+// checkWritable rejects changes to the code once the session has ended, from
+// muted participants, and from candidates before it has started or once a
+// phase locked the editor.
+func (c *Client) checkWritable() error {
+	lifecycle := c.Hub.lifecycle()
+	if lifecycle.ReadOnly() {
+		return errSessionReadOnly
+	}
+	if c.muted.Load() {
+		return errMuted
+	}
+	if lifecycle.State == resources.SessionScheduled && !c.isInterviewer() {
+		return fmt.Errorf("the session has not started yet")
+	}
+	if c.Hub.editorLocked() && !c.isInterviewer() {
+		return fmt.Errorf("the editor is locked for the rest of the interview")
+	}
+	return nil
+}
+
+func (c *Client) processLifecycle(requestID string, state string) {
+	if !c.isInterviewer() {
+		c.reject(requestID, errForbidden, "only interviewers can start or end the session")
+		return
+	}
+	if err := c.Hub.transitionLifecycle(state, c.Username); err != nil {
+		c.reject(requestID, errSessionLifecycle, err.Error())
+		return
+	}
+	c.acknowledge(requestID, c.Hub.currentVersion())
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"CodeStream/src/resources"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hubMetrics counts how a hub copes with its load. DroppedFrames and
+// OverloadRejections grow when clients or the hub fall behind.
+type hubMetrics struct {
+	CommittedPatches   atomic.Int64
+	RejectedPatches    atomic.Int64
+	OverloadRejections atomic.Int64
+	CursorMoves        atomic.Int64
+	BroadcastCursors   atomic.Int64
+	Flushes            atomic.Int64
+	RedisCommits       atomic.Int64
+	MaxBatchPatches    atomic.Int64
+	LastFlushMicros    atomic.Int64
+	DroppedFrames      atomic.Int64
+}
+
+type HubMetricsSnapshot struct {
+	Clients            int   `json:"clients"`
+	PendingPatches     int   `json:"pending_patches"`
+	CommittedPatches   int64 `json:"committed_patches"`
+	RejectedPatches    int64 `json:"rejected_patches"`
+	OverloadRejections int64 `json:"overload_rejections"`
+	CursorMoves        int64 `json:"cursor_moves"`
+	BroadcastCursors   int64 `json:"broadcast_cursors"`
+	Flushes            int64 `json:"flushes"`
+	RedisCommits       int64 `json:"redis_commits"`
+	MaxBatchPatches    int64 `json:"max_batch_patches"`
+	LastFlushMicros    int64 `json:"last_flush_micros"`
+	DroppedFrames      int64 `json:"dropped_frames"`
+}
+
+func (m *hubMetrics) recordFlush(patches int, cursors int, took time.Duration) {
+	m.Flushes.Add(1)
+	m.BroadcastCursors.Add(int64(cursors))
+	m.LastFlushMicros.Store(took.Microseconds())
+	for {
+		current := m.MaxBatchPatches.Load()
+		if int64(patches) <= current || m.MaxBatchPatches.CompareAndSwap(current, int64(patches)) {
+			return
+		}
+	}
+}
+
+func (h *Hub) metricsSnapshot() HubMetricsSnapshot {
+	h.mu.RLock()
+	clients := len(h.Clients)
+	h.mu.RUnlock()
+
+	h.batch.mu.Lock()
+	pending := len(h.batch.patches)
+	h.batch.mu.Unlock()
+
+	return HubMetricsSnapshot{
+		Clients:            clients,
+		PendingPatches:     pending,
+		CommittedPatches:   h.metrics.CommittedPatches.Load(),
+		RejectedPatches:    h.metrics.RejectedPatches.Load(),
+		OverloadRejections: h.metrics.OverloadRejections.Load(),
+		CursorMoves:        h.metrics.CursorMoves.Load(),
+		BroadcastCursors:   h.metrics.BroadcastCursors.Load(),
+		Flushes:            h.metrics.Flushes.Load(),
+		RedisCommits:       h.metrics.RedisCommits.Load(),
+		MaxBatchPatches:    h.metrics.MaxBatchPatches.Load(),
+		LastFlushMicros:    h.metrics.LastFlushMicros.Load(),
+		DroppedFrames:      h.metrics.DroppedFrames.Load(),
+	}
+}
+
+// HubMetrics reports the batching and back-pressure counters of every session
+// hosted on this instance.
+func HubMetrics(c *gin.Context) {
+	sessionsMu.RLock()
+	hubs := make(map[string]*Hub, len(Sessions))
+	for sessionID, hub := range Sessions {
+		hubs[sessionID] = hub
+	}
+	sessionsMu.RUnlock()
+
+	sessions := make(map[string]HubMetricsSnapshot, len(hubs))
+	for sessionID, hub := range hubs {
+		sessions[sessionID] = hub.metricsSnapshot()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"instance_id": resources.InstanceID,
+		"sessions":    sessions,
+	})
+}
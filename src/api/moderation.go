@@ -0,0 +1,161 @@
+package api
+
+import (
+	"CodeStream/src/resources"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// maxReasonLength is the longest reason given for removing a
+	// participant, in characters. Close frames only have room for its first
+	// maxCloseReasonBytes bytes.
+	maxReasonLength     = 200
+	maxCloseReasonBytes = 123
+)
+
+var errMuted = errors.New("you have been made read-only by an interviewer")
+
+// refreshMuted rechecks which local clients are read-only after a mute
+// changed. Clients holding the interviewer key are never muted, since a
+// candidate may share their address.
+func (h *Hub) refreshMuted() {
+	h.mu.RLock()
+	clients := make([]*Client, 0, len(h.Clients))
+	for _, client := range h.Clients {
+		clients = append(clients, client)
+	}
+	h.mu.RUnlock()
+
+	for _, client := range clients {
+		if client.hasInterviewerRole() {
+			continue
+		}
+		muted, err := resources.IsMuted(h.Interview.Cache, h.SessionID, client.Identity)
+		if err != nil {
+			log.Printf("Error checking mute of %s in session %s: %v", client.Username, h.SessionID, err)
+			continue
+		}
+		client.muted.Store(muted)
+	}
+}
+
+// rejectMuted refuses the message requestID if the client is read-only,
+// reporting whether it did. Muted participants may still read comments and
+// follow along, but not add to them or ask for the editor.
+func (c *Client) rejectMuted(requestID string) bool {
+	if !c.muted.Load() {
+		return false
+	}
+	c.reject(requestID, errReadOnly, errMuted.Error())
+	return true
+}
+
+// mutedUsers returns the read-only participants for session_init.
+func (h *Hub) mutedUsers() []string {
+	usernames, err := resources.ListPresence(h.Interview.Cache, h.SessionID)
+	if err != nil {
+		log.Printf("Error listing presence for session %s: %v", h.SessionID, err)
+		return nil
+	}
+	muted, err := h.Interview.MutedParticipants(usernames)
+	if err != nil {
+		log.Printf("Error loading muted participants of session %s: %v", h.SessionID, err)
+		return nil
+	}
+	return muted
+}
+
+// processModeration kicks, bans or mutes a participant. Only clients holding
+// the interviewer key may, even with roles disabled, since anyone with the
+// session link can join.
+func (c *Client) processModeration(requestID string, action string, req ModerationData) {
+	h := c.Hub
+	if !c.hasInterviewerRole() {
+		c.reject(requestID, errForbidden, "only interviewers can remove or mute participants")
+		return
+	}
+	if req.Username == "" || req.Username == c.Username {
+		c.reject(requestID, errModeration, "choose another participant")
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if utf8.RuneCountInString(req.Reason) > maxReasonLength {
+		c.reject(requestID, errModeration, fmt.Sprintf("reason longer than %d characters", maxReasonLength))
+		return
+	}
+
+	if action != "ban" && !h.present(req.Username) {
+		c.reject(requestID, errModeration, fmt.Sprintf("%s is not in the session", req.Username))
+		return
+	}
+
+	var err error
+	eventType := resources.TimelineKick
+	switch action {
+	case "ban":
+		eventType = resources.TimelineBan
+		err = h.Interview.BanParticipant(req.Username)
+	case "mute":
+		eventType = resources.TimelineMute
+		err = h.Interview.SetMuted(req.Username, req.Muted)
+	}
+	if err != nil {
+		if !errors.Is(err, resources.ErrParticipantNotFound) {
+			log.Printf("Error moderating %s in session %s: %v", req.Username, h.SessionID, err)
+		}
+		c.reject(requestID, errModeration, err.Error())
+		return
+	}
+	h.recordEvent(eventType, c.Username, resources.ModerationEventData{
+		Username: req.Username,
+		Reason:   req.Reason,
+		Muted:    req.Muted,
+	})
+
+	if action == "mute" {
+		h.refreshMuted()
+		h.broadcastAll(encodeMessage("user_muted", UserMutedData{Username: req.Username, Muted: req.Muted}))
+	} else {
+		h.broadcastAll(encodeMessage("user_removed", UserRemovedData{
+			Username: req.Username,
+			Reason:   req.Reason,
+			Banned:   action == "ban",
+		}))
+		h.disconnect(req.Username, req.Reason)
+	}
+	c.acknowledge(requestID, h.currentVersion())
+}
+
+// disconnect closes the connection of a removed participant, if it is on
+// this instance, giving them the reason.
+func (h *Hub) disconnect(username string, reason string) {
+	h.mu.RLock()
+	client, ok := h.Clients[username]
+	h.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	closeMessage := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, closeReason(reason))
+	_ = client.Conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(writeWait))
+	_ = client.Conn.Close()
+}
+
+// closeReason fits the reason a participant was removed for into a close
+// frame, without splitting a character.
+func closeReason(reason string) string {
+	if reason == "" {
+		return "removed by an interviewer"
+	}
+	if len(reason) > maxCloseReasonBytes {
+		return strings.ToValidUTF8(reason[:maxCloseReasonBytes], "")
+	}
+	return reason
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCloseReason(t *testing.T) {
+	tests := []struct {
+		name   string
+		reason string
+		want   string
+	}{
+		{"no reason", "", "removed by an interviewer"},
+		{"short", "please rejoin later", "please rejoin later"},
+		{"at the limit", strings.Repeat("a", maxCloseReasonBytes), strings.Repeat("a", maxCloseReasonBytes)},
+		{"too long", strings.Repeat("a", maxCloseReasonBytes+5), strings.Repeat("a", maxCloseReasonBytes)},
+		{"split character", strings.Repeat("é", maxCloseReasonBytes), strings.Repeat("é", maxCloseReasonBytes/2)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := closeReason(tt.reason)
+			if got != tt.want {
+				t.Errorf("closeReason() = %q, want %q", got, tt.want)
+			}
+			if len(got) > maxCloseReasonBytes || !utf8.ValidString(got) {
+				t.Errorf("closeReason() = %q does not fit a close frame", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"CodeStream/src/resources"
+	"errors"
+	"log"
+)
+
+// privateData returns the notes, the scorecard and the integrity signals for
+// clients holding the interviewer role, and nothing for everyone else.
+func (c *Client) privateData() (*DocumentData, *resources.Scorecard, []resources.IntegritySummary) {
+	if !c.hasInterviewerRole() {
+		return nil, nil, nil
+	}
+	notes := c.Hub.notes.data(c.Hub.SessionID)
+	if notes != nil {
+		notes.Editable = true
+	}
+	scorecard, err := c.Hub.Interview.Scorecard()
+	if err != nil {
+		log.Printf("Error loading scorecard of session %s: %v", c.Hub.SessionID, err)
+		return notes, nil, c.Hub.integrityData()
+	}
+	return notes, &scorecard, c.Hub.integrityData()
+}
+
+// processNotesPatch commits a change to the interviewers' notes. Notes stay
+// editable after the session ends and the archive is updated with them.
+func (c *Client) processNotesPatch(requestID string, req CodePatchData) {
+	if c.processDocumentPatch(requestID, req, &c.Hub.notes, "notes", errNotesPatch, c.Hub.broadcastInterviewers) {
+		c.Hub.rearchive()
+	}
+}
+
+// processScorecardUpdate replaces the scorecard and sends it to every
+// interviewer. An update made to a stale scorecard is refused and its sender
+// gets the current one back.
+func (c *Client) processScorecardUpdate(requestID string, req ScorecardUpdateData) {
+	h := c.Hub
+	scorecard, err := h.Interview.UpdateScorecard(req.toScorecard(), c.Username)
+	if errors.Is(err, resources.ErrScorecardChanged) {
+		c.reject(requestID, errVersionMismatch, err.Error())
+		if current, err := h.Interview.Scorecard(); err == nil {
+			c.sendMessage("scorecard", current)
+		}
+		return
+	}
+	if err != nil {
+		c.reject(requestID, errScorecard, err.Error())
+		return
+	}
+
+	h.broadcastInterviewers(encodeMessage("scorecard", scorecard))
+	c.acknowledge(requestID, scorecard.Version)
+	h.rearchive()
+}
@@ -0,0 +1,250 @@
+package api
+
+import (
+	"CodeStream/src/resources"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultPlaybackSpeed = 1.0
+	maxPlaybackSpeed     = 64.0
+)
+
+// SessionTimeline returns every recorded event of a session, from its archive
+// once it has expired from Redis. The timeline holds integrity and moderation
+// events, so like the report it needs the ADMIN_TOKEN or the session's
+// interviewer key.
+func SessionTimeline(c *gin.Context) {
+	sessionID := c.Param("sessionID")
+	cache := resources.NewCacheContext()
+	if !canReview(c, cache, sessionID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	events, err := resources.LoadTimeline(c.Request.Context(), cache, sessionID)
+	if errors.Is(err, resources.ErrArchiveNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session does not exist"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading timeline of session %s: %v", sessionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"session_id": sessionID, "events": events})
+}
+
+func clampPlaybackSpeed(speed float64) float64 {
+	if speed <= 0 {
+		return defaultPlaybackSpeed
+	}
+	if speed > maxPlaybackSpeed {
+		return maxPlaybackSpeed
+	}
+	return speed
+}
+
+// playback streams a recorded timeline to one websocket, waiting between
+// events as long as the participants did, divided by the speed.
+type playback struct {
+	conn     *websocket.Conn
+	codec    codec
+	events   []resources.TimelineEvent
+	speed    float64
+	maxGap   time.Duration
+	paused   bool
+	next     int
+	controls chan PlaybackControlData
+}
+
+// PlaybackSession replays a session, or its archive, over a websocket. The
+// speed and max_gap_ms query parameters set the initial speed and cap idle
+// periods; the client adjusts playback with playback_control messages. Like
+// the timeline, it needs the ADMIN_TOKEN or the session's interviewer key.
+func PlaybackSession(c *gin.Context) {
+	sessionID := c.Query("session_id")
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "session_id query parameters is required"})
+		return
+	}
+	cache := resources.NewCacheContext()
+	if !canReview(c, cache, sessionID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	events, err := resources.LoadTimeline(c.Request.Context(), cache, sessionID)
+	if errors.Is(err, resources.ErrArchiveNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session does not exist"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading timeline of session %s: %v", sessionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	speed, _ := strconv.ParseFloat(c.Query("speed"), 64)
+	maxGapMs, _ := strconv.Atoi(c.Query("max_gap_ms"))
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade error: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	p := &playback{
+		conn:     conn,
+		codec:    codecForSubprotocol(conn.Subprotocol()),
+		events:   events,
+		speed:    clampPlaybackSpeed(speed),
+		maxGap:   time.Duration(maxGapMs) * time.Millisecond,
+		controls: make(chan PlaybackControlData),
+	}
+
+	closed := make(chan struct{})
+	go p.readControls(closed)
+
+	if err := p.write("playback_init", PlaybackInitData{
+		SessionID: sessionID,
+		Events:    len(events),
+		StartedAt: events[0].At,
+		EndedAt:   events[len(events)-1].At,
+		Speed:     p.speed,
+	}); err != nil {
+		return
+	}
+	p.run(closed)
+}
+
+func (p *playback) readControls(closed chan struct{}) {
+	defer close(closed)
+
+	p.conn.SetReadLimit(maxMessageSize)
+	_ = p.conn.SetReadDeadline(time.Now().Add(pongWait))
+	p.conn.SetPongHandler(func(string) error {
+		_ = p.conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	for {
+		_, frame, err := p.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		data, err := p.codec.Decode(frame)
+		if err != nil {
+			continue
+		}
+		var msg Message
+		var control PlaybackControlData
+		if json.Unmarshal(data, &msg) != nil || msg.Type != "playback_control" || decodeMessageData(msg, &control) != nil {
+			continue
+		}
+		select {
+		case p.controls <- control:
+		case <-closed:
+			return
+		}
+	}
+}
+
+func (p *playback) write(msgType string, data interface{}) error {
+	frame, err := newOutFrame(encodeMessage(msgType, data)).encode(p.codec)
+	if err != nil {
+		return err
+	}
+	_ = p.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return p.conn.WriteMessage(p.codec.FrameType(), frame)
+}
+
+func (p *playback) delayBefore(index int) time.Duration {
+	if index == 0 {
+		return 0
+	}
+	gap := time.Duration(p.events[index].At-p.events[index-1].At) * time.Millisecond
+	if p.maxGap > 0 && gap > p.maxGap {
+		gap = p.maxGap
+	}
+	return time.Duration(float64(gap) / p.speed)
+}
+
+func (p *playback) apply(control PlaybackControlData) error {
+	switch control.Action {
+	case "pause":
+		p.paused = true
+	case "resume":
+		p.paused = false
+	case "speed":
+		p.speed = clampPlaybackSpeed(control.Speed)
+	case "seek":
+		// Clients rebuild the document from scratch, so replay everything
+		// up to the target instantly.
+		target := min(max(control.Index, 0), len(p.events))
+		if err := p.write("playback_reset", struct{}{}); err != nil {
+			return err
+		}
+		for i := 0; i < target; i++ {
+			if err := p.write("playback_event", PlaybackEventData{Index: i, Event: p.events[i]}); err != nil {
+				return err
+			}
+		}
+		p.next = target
+	}
+	return p.write("playback_state", PlaybackStateData{Paused: p.paused, Speed: p.speed, Index: p.next})
+}
+
+func (p *playback) run(closed chan struct{}) {
+	ping := time.NewTicker(pingPeriod)
+	defer ping.Stop()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		var due <-chan time.Time
+		if !p.paused && p.next < len(p.events) {
+			due = timer.C
+		}
+
+		select {
+		case <-closed:
+			return
+
+		case control := <-p.controls:
+			if err := p.apply(control); err != nil {
+				return
+			}
+			timer.Reset(p.delayBefore(p.next))
+
+		case <-ping.C:
+			_ = p.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := p.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+
+		case <-due:
+			if err := p.write("playback_event", PlaybackEventData{Index: p.next, Event: p.events[p.next]}); err != nil {
+				return
+			}
+			p.next++
+			if p.next == len(p.events) {
+				if err := p.write("playback_end", struct{}{}); err != nil {
+					return
+				}
+				continue
+			}
+			timer.Reset(p.delayBefore(p.next))
+		}
+	}
+}
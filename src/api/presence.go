@@ -0,0 +1,243 @@
+package api
+
+import (
+	"CodeStream/src/resources"
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	StatusActive = "active"
+	StatusIdle   = "idle"
+	StatusTyping = "typing"
+
+	presenceTickPeriod = time.Second
+	// A participant is typing for typingWindow after their last edit, and
+	// idle once nothing was heard from them for idleAfter or their tab is in
+	// the background.
+	typingWindow = 3 * time.Second
+	idleAfter    = time.Minute
+)
+
+// clientPresence tracks what a participant is doing. It is written by the
+// client's read loop and read by the hub's presence ticker.
+type clientPresence struct {
+	mu         sync.Mutex
+	lastActive time.Time
+	lastTyped  time.Time
+	away       bool
+	startPos   int
+	endPos     int
+	status     string
+	// dirty is set when something other clients see changed since the
+	// status was last stored.
+	dirty bool
+}
+
+func (p *clientPresence) deriveStatus(now time.Time) string {
+	switch {
+	case p.away:
+		return StatusIdle
+	case now.Sub(p.lastTyped) < typingWindow:
+		return StatusTyping
+	case now.Sub(p.lastActive) < idleAfter:
+		return StatusActive
+	default:
+		return StatusIdle
+	}
+}
+
+// refresh recomputes the status and reports whether it changed.
+func (p *clientPresence) refresh(now time.Time) bool {
+	status := p.deriveStatus(now)
+	if status == p.status {
+		return false
+	}
+	p.status = status
+	return true
+}
+
+// changed refreshes the status and reports whether it changed and whether
+// anything needs storing, then considers it stored.
+func (p *clientPresence) changed(now time.Time) (statusChanged bool, dirty bool) {
+	statusChanged = p.refresh(now)
+	dirty = statusChanged || p.dirty
+	p.dirty = false
+	return statusChanged, dirty
+}
+
+func (c *Client) userInfo() UserInfo {
+	c.presence.mu.Lock()
+	defer c.presence.mu.Unlock()
+	return UserInfo{
+		Username:   c.Username,
+		Role:       c.Role,
+		Status:     c.presence.status,
+		StartPos:   c.presence.startPos,
+		EndPos:     c.presence.endPos,
+		LastActive: c.presence.lastActive.UnixMilli(),
+	}
+}
+
+// joinPresence marks a client that just connected as active.
+func (c *Client) joinPresence() {
+	c.presence.mu.Lock()
+	defer c.presence.mu.Unlock()
+	c.presence.lastActive = time.Now()
+	c.presence.status = StatusActive
+}
+
+// touch records activity from the client; typed is set for code edits. A
+// client that starts typing or comes back from idle is announced right away
+// rather than on the next tick.
+func (c *Client) touch(typed bool) {
+	now := time.Now()
+	c.presence.mu.Lock()
+	c.presence.lastActive = now
+	if typed {
+		c.presence.lastTyped = now
+	}
+	changed, _ := c.presence.changed(now)
+	c.presence.mu.Unlock()
+
+	if changed {
+		c.Hub.publishStatus(c, true)
+	}
+}
+
+// setAway records the client's tab going to the background or coming back.
+func (c *Client) setAway(away bool) {
+	now := time.Now()
+	c.presence.mu.Lock()
+	c.presence.away = away
+	if !away {
+		c.presence.lastActive = now
+	}
+	changed, _ := c.presence.changed(now)
+	c.presence.mu.Unlock()
+
+	if changed {
+		c.Hub.publishStatus(c, true)
+	}
+}
+
+// moveCursor records the client's selection for the participant list.
+func (c *Client) moveCursor(startPos int, endPos int) {
+	c.presence.mu.Lock()
+	defer c.presence.mu.Unlock()
+	c.presence.lastActive = time.Now()
+	if c.presence.startPos != startPos || c.presence.endPos != endPos {
+		c.presence.startPos = startPos
+		c.presence.endPos = endPos
+		c.presence.dirty = true
+	}
+}
+
+// publishStatus stores the client's status for the other instances and, when
+// it changed, sends it to everyone.
+func (h *Hub) publishStatus(client *Client, changed bool) {
+	info := client.userInfo()
+	err := resources.SetParticipantStatus(h.Interview.Cache, h.SessionID, client.Username, resources.ParticipantStatus{
+		Role:       info.Role,
+		Status:     info.Status,
+		StartPos:   info.StartPos,
+		EndPos:     info.EndPos,
+		LastActive: info.LastActive,
+	})
+	if err != nil {
+		log.Printf("Error storing status of %s in session %s: %v", client.Username, h.SessionID, err)
+	}
+	if changed {
+		h.broadcastAll(encodeMessage("user_status", info))
+	}
+}
+
+// tickPresence recomputes the statuses of the local clients until the hub
+// stops, publishing the ones that changed.
+func (h *Hub) tickPresence() {
+	ticker := time.NewTicker(presenceTickPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.done:
+			return
+		case now := <-ticker.C:
+			h.mu.RLock()
+			clients := make([]*Client, 0, len(h.Clients))
+			for _, client := range h.Clients {
+				clients = append(clients, client)
+			}
+			h.mu.RUnlock()
+
+			for _, client := range clients {
+				client.presence.mu.Lock()
+				changed, dirty := client.presence.changed(now)
+				client.presence.mu.Unlock()
+				if dirty {
+					h.publishStatus(client, changed)
+				}
+			}
+		}
+	}
+}
+
+// present reports whether username is connected to the session, assuming
+// they are when presence cannot be read.
+func (h *Hub) present(username string) bool {
+	usernames, err := resources.ListPresence(h.Interview.Cache, h.SessionID)
+	if err != nil {
+		log.Printf("Error listing presence for session %s: %v", h.SessionID, err)
+		return true
+	}
+	return slices.Contains(usernames, username)
+}
+
+// participants lists everyone connected to the session for self's
+// session_init, with the live status of local clients and the stored status
+// of the others.
+func (h *Hub) participants(self *Client) []UserInfo {
+	usernames, err := resources.ListPresence(h.Interview.Cache, h.SessionID)
+	if err != nil {
+		log.Printf("Error listing presence for session %s: %v", h.SessionID, err)
+	}
+	statuses, err := resources.ParticipantStatuses(h.Interview.Cache, h.SessionID)
+	if err != nil {
+		log.Printf("Error loading statuses for session %s: %v", h.SessionID, err)
+	}
+
+	h.mu.RLock()
+	local := make(map[string]*Client, len(h.Clients))
+	for username, client := range h.Clients {
+		local[username] = client
+	}
+	h.mu.RUnlock()
+	local[self.Username] = self
+
+	users := make([]UserInfo, 0, len(usernames)+len(local))
+	seen := make(map[string]bool, len(usernames))
+	for _, username := range usernames {
+		seen[username] = true
+		if client, ok := local[username]; ok {
+			users = append(users, client.userInfo())
+			continue
+		}
+		status := statuses[username]
+		users = append(users, UserInfo{
+			Username:   username,
+			Role:       status.Role,
+			Status:     status.Status,
+			StartPos:   status.StartPos,
+			EndPos:     status.EndPos,
+			LastActive: status.LastActive,
+		})
+	}
+	for username, client := range local {
+		if !seen[username] {
+			users = append(users, client.userInfo())
+		}
+	}
+	return users
+}
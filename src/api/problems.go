@@ -0,0 +1,151 @@
+package api
+
+import (
+	"CodeStream/src/resources"
+	"archive/zip"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func requireProblemBank(c *gin.Context) bool {
+	if resources.Problems == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Problem bank is not configured"})
+		return false
+	}
+	return true
+}
+
+func problemError(c *gin.Context, err error) {
+	if errors.Is(err, resources.ErrProblemNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// ListProblems lists the problem bank, filtered by the difficulty and tag
+// query parameters.
+func ListProblems(c *gin.Context) {
+	if !requireProblemBank(c) {
+		return
+	}
+
+	problems, err := resources.Problems.List(c.Request.Context(), resources.ProblemFilter{
+		Difficulty: c.Query("difficulty"),
+		Tag:        c.Query("tag"),
+	})
+	if err != nil {
+		problemError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"problems": problems})
+}
+
+func GetProblem(c *gin.Context) {
+	if !requireProblemBank(c) {
+		return
+	}
+
+	problem, err := resources.Problems.Get(c.Request.Context(), c.Param("problemID"))
+	if err != nil {
+		problemError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, problem)
+}
+
+func bindProblem(c *gin.Context) (resources.Problem, bool) {
+	var problem resources.Problem
+	if err := c.ShouldBindJSON(&problem); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return resources.Problem{}, false
+	}
+	if err := problem.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return resources.Problem{}, false
+	}
+	return problem, true
+}
+
+func CreateProblem(c *gin.Context) {
+	if !requireProblemBank(c) {
+		return
+	}
+	problem, ok := bindProblem(c)
+	if !ok {
+		return
+	}
+
+	problem, err := resources.Problems.Create(c.Request.Context(), problem)
+	if err != nil {
+		problemError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, problem)
+}
+
+func UpdateProblem(c *gin.Context) {
+	if !requireProblemBank(c) {
+		return
+	}
+	problem, ok := bindProblem(c)
+	if !ok {
+		return
+	}
+	problem.ID = c.Param("problemID")
+
+	problem, err := resources.Problems.Update(c.Request.Context(), problem)
+	if err != nil {
+		problemError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, problem)
+}
+
+func DeleteProblem(c *gin.Context) {
+	if !requireProblemBank(c) {
+		return
+	}
+
+	if err := resources.Problems.Delete(c.Request.Context(), c.Param("problemID")); err != nil {
+		problemError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ImportProblem adds a problem from an uploaded Polygon package or ICPC
+// problem archive, zipped, in the package form field.
+func ImportProblem(c *gin.Context) {
+	if !requireProblemBank(c) {
+		return
+	}
+
+	header, err := c.FormFile("package")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	archive, err := zip.NewReader(file, header.Size)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "package must be a zip archive"})
+		return
+	}
+	result, err := resources.ImportProblemPackage(c.Request.Context(), archive, resources.ImportOptions{
+		Difficulty: c.PostForm("difficulty"),
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, result)
+}
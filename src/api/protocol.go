@@ -0,0 +1,641 @@
+package api
+
+import (
+	"CodeStream/src/resources"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/invopop/jsonschema"
+)
+
+// ProtocolVersion is the newest websocket protocol version spoken by the
+// server. Clients ask for a version with the protocol_version query parameter
+// and the server answers with the version it will use in session_init.
+const (
+	ProtocolVersion    = 3
+	MinProtocolVersion = 1
+)
+
+// Message is the frame exchanged over the websocket. ID is chosen by the
+// client and echoed back as request_id in replies to that message. From
+// protocol 2 on every client message must carry an ID and is answered with
+// either an ack or a nack. Protocol 3 clients receive patches and cursor
+// moves as batch messages.
+type Message struct {
+	Type string          `json:"type"`
+	ID   string          `json:"id,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+type outboundMessage struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// Inbound messages.
+
+type CodePatchData struct {
+	Version  int64  `json:"version" jsonschema:"description=Version this patch produces; 0 skips the check"`
+	Op       string `json:"op" jsonschema:"enum=add,enum=remove,enum=replace"`
+	StartPos int    `json:"start_pos" jsonschema:"minimum=0"`
+	EndPos   int    `json:"end_pos,omitempty" jsonschema:"minimum=0"`
+	Content  string `json:"content,omitempty"`
+}
+
+func newCodePatchData(patch resources.CodePatch) CodePatchData {
+	return CodePatchData{
+		Version:  patch.Version,
+		Op:       patch.Operation,
+		StartPos: patch.StartPos,
+		EndPos:   patch.EndPos,
+		Content:  patch.Content,
+	}
+}
+
+func (p CodePatchData) toCodePatch() resources.CodePatch {
+	return resources.CodePatch{
+		Version:   p.Version,
+		Operation: p.Op,
+		StartPos:  p.StartPos,
+		EndPos:    p.EndPos,
+		Content:   p.Content,
+	}
+}
+
+type CursorSelectData struct {
+	StartPos int           `json:"start_pos" jsonschema:"minimum=0"`
+	EndPos   int           `json:"end_pos" jsonschema:"minimum=0"`
+	Viewport *ViewportData `json:"viewport,omitempty"`
+}
+
+// ViewportData is the part of the code a participant sees: the first and
+// last visible lines, counted from 0, and the editor's scroll offset in
+// pixels.
+type ViewportData struct {
+	FirstLine int `json:"first_line" jsonschema:"minimum=0"`
+	LastLine  int `json:"last_line" jsonschema:"minimum=0"`
+	ScrollTop int `json:"scroll_top" jsonschema:"minimum=0"`
+}
+
+// FollowData starts following a participant's selection and viewport, or
+// stops following when Username is empty.
+type FollowData struct {
+	Username string `json:"username"`
+}
+
+// DriveData carries Locked for drive_lock and Username for drive_grant.
+type DriveData struct {
+	Locked   bool   `json:"locked,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+// ModerationData names the participant to kick, ban or mute. Reason is
+// shown to a removed participant; Muted makes them read-only or lifts it.
+type ModerationData struct {
+	Username string `json:"username"`
+	Reason   string `json:"reason,omitempty"`
+	Muted    bool   `json:"muted,omitempty"`
+}
+
+type EditLangData struct {
+	Lang string `json:"lang"`
+}
+
+type RestoreVersionData struct {
+	Version int64 `json:"version" jsonschema:"minimum=1"`
+}
+
+// SwitchQuestionData moves everyone to the question at Index, counted from 0.
+type SwitchQuestionData struct {
+	Index int `json:"index" jsonschema:"minimum=0"`
+}
+
+// ScorecardUpdateData replaces the scorecard. Version is the version of the
+// scorecard the change was made to.
+type ScorecardUpdateData struct {
+	Version        int64                          `json:"version" jsonschema:"minimum=0"`
+	Criteria       []resources.ScorecardCriterion `json:"criteria"`
+	Recommendation string                         `json:"recommendation,omitempty" jsonschema:"enum=strong_no_hire,enum=no_hire,enum=hire,enum=strong_hire"`
+	Summary        string                         `json:"summary,omitempty"`
+}
+
+func (s ScorecardUpdateData) toScorecard() resources.Scorecard {
+	return resources.Scorecard{
+		Version:        s.Version,
+		Criteria:       s.Criteria,
+		Recommendation: s.Recommendation,
+		Summary:        s.Summary,
+	}
+}
+
+// TimerControlData starts the timer with phases, pauses or resumes it, or
+// adds DurationMs to the current phase.
+type TimerControlData struct {
+	Action     string                 `json:"action" jsonschema:"enum=start,enum=pause,enum=resume,enum=add_time"`
+	Phases     []resources.TimerPhase `json:"phases,omitempty"`
+	DurationMs int64                  `json:"duration_ms,omitempty" jsonschema:"exclusiveMinimum=0"`
+}
+
+type EmptyData struct{}
+
+type PlaybackControlData struct {
+	Action string  `json:"action" jsonschema:"enum=pause,enum=resume,enum=speed,enum=seek"`
+	Speed  float64 `json:"speed,omitempty" jsonschema:"exclusiveMinimum=0"`
+	Index  int     `json:"index,omitempty" jsonschema:"minimum=0"`
+}
+
+// Outbound messages.
+
+// UserInfo describes a participant. Status is active, idle or typing, and
+// LastActive is when they last sent anything, in Unix milliseconds.
+type UserInfo struct {
+	Username   string `json:"username"`
+	Role       string `json:"role,omitempty" jsonschema:"enum=interviewer,enum=candidate"`
+	Status     string `json:"status,omitempty" jsonschema:"enum=active,enum=idle,enum=typing"`
+	StartPos   int    `json:"start_pos"`
+	EndPos     int    `json:"end_pos"`
+	LastActive int64  `json:"last_active,omitempty"`
+}
+
+type SessionInitData struct {
+	ProtocolVersion int                       `json:"protocol_version"`
+	SessionID       string                    `json:"session_id"`
+	CurrentCode     string                    `json:"current_code"`
+	Lang            string                    `json:"lang"`
+	Version         int64                     `json:"version"`
+	Patches         []CodePatchData           `json:"patches"`
+	Users           []UserInfo                `json:"users"`
+	Username        string                    `json:"username"`
+	Role            string                    `json:"role" jsonschema:"enum=interviewer,enum=candidate"`
+	State           SessionStateData          `json:"state"`
+	Timer           *TimerData                `json:"timer,omitempty"`
+	Problem         *ProblemData              `json:"problem,omitempty"`
+	Question        *QuestionData             `json:"question,omitempty"`
+	Statement       *DocumentData             `json:"statement,omitempty"`
+	Chat            []resources.ChatMessage   `json:"chat,omitempty"`
+	Comments        []resources.CommentThread `json:"comments,omitempty"`
+	Drive           resources.DriveLock       `json:"drive"`
+	Muted           []string                  `json:"muted,omitempty"`
+
+	// Only sent to interviewers.
+	Notes     *DocumentData                `json:"notes,omitempty"`
+	Scorecard *resources.Scorecard         `json:"scorecard,omitempty"`
+	Integrity []resources.IntegritySummary `json:"integrity,omitempty"`
+}
+
+// QuestionData is the question everyone is working on, in sessions with
+// questions. It is broadcast when an interviewer switches questions, after
+// the batch that replaces the code with the new question's.
+type QuestionData struct {
+	Index     int               `json:"index"`
+	Lang      string            `json:"lang"`
+	Problem   *ProblemData      `json:"problem"`
+	Questions []QuestionSummary `json:"questions"`
+}
+
+type QuestionSummary struct {
+	Title      string `json:"title"`
+	Difficulty string `json:"difficulty"`
+}
+
+func newQuestionData(questions resources.SessionQuestions, lang string) *QuestionData {
+	data := &QuestionData{
+		Index:     questions.Active,
+		Lang:      lang,
+		Problem:   newProblemData(&questions.Questions[questions.Active].Problem),
+		Questions: make([]QuestionSummary, len(questions.Questions)),
+	}
+	for i, question := range questions.Questions {
+		data.Questions[i] = QuestionSummary{Title: question.Problem.Title, Difficulty: question.Problem.Difficulty}
+	}
+	return data
+}
+
+// SessionStateData times are unix milliseconds. EndsAt is set for live
+// sessions that end on their own.
+type SessionStateData struct {
+	State       string `json:"state" jsonschema:"enum=scheduled,enum=live,enum=ended,enum=archived"`
+	ScheduledAt int64  `json:"scheduled_at,omitempty"`
+	StartedAt   int64  `json:"started_at,omitempty"`
+	EndedAt     int64  `json:"ended_at,omitempty"`
+	EndsAt      int64  `json:"ends_at,omitempty"`
+}
+
+func newSessionStateData(lifecycle resources.SessionLifecycle) SessionStateData {
+	return SessionStateData{
+		State:       lifecycle.State,
+		ScheduledAt: lifecycle.ScheduledAt,
+		StartedAt:   lifecycle.StartedAt,
+		EndedAt:     lifecycle.EndedAt,
+		EndsAt:      lifecycle.EndsAt(),
+	}
+}
+
+// ProblemData is the question a session was created with, as candidates see
+// it: hidden test cases are left out.
+type ProblemData struct {
+	ID          string               `json:"id"`
+	Title       string               `json:"title"`
+	Statement   string               `json:"statement"`
+	Difficulty  string               `json:"difficulty"`
+	Tags        []string             `json:"tags"`
+	StarterCode map[string]string    `json:"starter_code"`
+	Examples    []resources.TestCase `json:"examples"`
+}
+
+func newProblemData(problem *resources.Problem) *ProblemData {
+	if problem == nil {
+		return nil
+	}
+	return &ProblemData{
+		ID:          problem.ID,
+		Title:       problem.Title,
+		Statement:   problem.Statement,
+		Difficulty:  problem.Difficulty,
+		Tags:        problem.Tags,
+		StarterCode: problem.StarterCode,
+		Examples:    problem.VisibleTestCases(),
+	}
+}
+
+// TimerData is broadcast when the timer changes and every second while it
+// runs. Locked is set once a phase that locks the editor is over.
+type TimerData struct {
+	Running          bool                   `json:"running"`
+	Finished         bool                   `json:"finished"`
+	RemainingMs      int64                  `json:"remaining_ms"`
+	Phase            int                    `json:"phase"`
+	PhaseName        string                 `json:"phase_name"`
+	PhaseRemainingMs int64                  `json:"phase_remaining_ms"`
+	Phases           []resources.TimerPhase `json:"phases"`
+	Locked           bool                   `json:"locked"`
+}
+
+func newTimerData(timer resources.SessionTimer, now time.Time) TimerData {
+	status := timer.StatusAt(now)
+	return TimerData{
+		Running:          status.Running,
+		Finished:         status.Finished,
+		RemainingMs:      status.RemainingMs,
+		Phase:            status.Phase,
+		PhaseName:        timer.Phases[status.Phase].Name,
+		PhaseRemainingMs: status.PhaseRemainingMs,
+		Phases:           timer.Phases,
+		Locked:           status.Locked,
+	}
+}
+
+type UserPresenceData struct {
+	Username string `json:"username"`
+}
+
+// UserRemovedData announces a participant kicked, or banned, by an
+// interviewer.
+type UserRemovedData struct {
+	Username string `json:"username"`
+	Reason   string `json:"reason,omitempty"`
+	Banned   bool   `json:"banned,omitempty"`
+}
+
+type UserMutedData struct {
+	Username string `json:"username"`
+	Muted    bool   `json:"muted"`
+}
+
+type CodePatchEvent struct {
+	Username string `json:"username"`
+	Version  int64  `json:"version"`
+	Op       string `json:"op"`
+	StartPos int    `json:"start_pos"`
+	EndPos   int    `json:"end_pos"`
+	Content  string `json:"content"`
+}
+
+// CommentCreateData starts a comment thread on the code from StartPos to
+// EndPos, in characters, as of Version. The server moves the range past any
+// patch committed since.
+type CommentCreateData struct {
+	Version  int64  `json:"version" jsonschema:"minimum=0"`
+	StartPos int    `json:"start_pos" jsonschema:"minimum=0"`
+	EndPos   int    `json:"end_pos" jsonschema:"minimum=1"`
+	Text     string `json:"text" jsonschema:"minLength=1,maxLength=2000"`
+}
+
+type CommentReplyData struct {
+	ThreadID string `json:"thread_id"`
+	Text     string `json:"text" jsonschema:"minLength=1,maxLength=2000"`
+}
+
+// CommentResolveData resolves a thread, or reopens it when Resolved is false.
+type CommentResolveData struct {
+	ThreadID string `json:"thread_id"`
+	Resolved bool   `json:"resolved"`
+}
+
+// ChatMessageData is a message to the session's chat. Private messages are
+// sent by and to interviewers only.
+type ChatMessageData struct {
+	Text    string `json:"text" jsonschema:"minLength=1,maxLength=2000"`
+	Private bool   `json:"private,omitempty"`
+}
+
+// IntegrityEventData is an integrity signal about a participant, sent to the
+// interviewers as it happens. Chars and Lines measure pastes.
+type IntegrityEventData struct {
+	Username string `json:"username"`
+	Type     string `json:"type" jsonschema:"enum=paste,enum=focus_lost,enum=focus_gained"`
+	At       int64  `json:"at"`
+	Chars    int    `json:"chars,omitempty"`
+	Lines    int    `json:"lines,omitempty"`
+}
+
+// DocumentData is a whole markdown document, the statement or the notes: its
+// markdown and the markdown rendered to sanitized HTML.
+type DocumentData struct {
+	Version  int64  `json:"version"`
+	Markdown string `json:"markdown"`
+	HTML     string `json:"html"`
+	Editable bool   `json:"editable,omitempty"`
+}
+
+// DocumentPatchEvent is a committed change to a markdown document, with the
+// document rendered after it.
+type DocumentPatchEvent struct {
+	Username string `json:"username"`
+	Version  int64  `json:"version"`
+	Op       string `json:"op"`
+	StartPos int    `json:"start_pos"`
+	EndPos   int    `json:"end_pos"`
+	Content  string `json:"content"`
+	HTML     string `json:"html"`
+}
+
+type CursorSelectEvent struct {
+	Username string        `json:"username"`
+	StartPos int           `json:"start_pos"`
+	EndPos   int           `json:"end_pos"`
+	Viewport *ViewportData `json:"viewport,omitempty"`
+}
+
+type EditLangEvent struct {
+	Username string `json:"username,omitempty"`
+	Lang     string `json:"lang"`
+}
+
+type CodeResultData struct {
+	StdOut   string `json:"std_out"`
+	StdErr   string `json:"std_err"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+	Info     string `json:"info,omitempty"`
+	Duration string `json:"duration,omitempty"`
+}
+
+// JudgeResultData is the verdict on the session's code against the problem's
+// test cases. Output is only included for the tests candidates may see.
+type JudgeResultData struct {
+	Lang    string                 `json:"lang"`
+	Version int64                  `json:"version"`
+	Verdict string                 `json:"verdict"`
+	Passed  int                    `json:"passed"`
+	Total   int                    `json:"total"`
+	Tests   []resources.TestResult `json:"tests"`
+}
+
+// BatchData carries the patches committed and the cursor moves received in
+// one batch window, including the recipient's own, which clients skip.
+type BatchData struct {
+	Patches []CodePatchEvent    `json:"patches,omitempty"`
+	Cursors []CursorSelectEvent `json:"cursors,omitempty"`
+}
+
+type AckData struct {
+	RequestID string `json:"request_id"`
+	Version   int64  `json:"version"`
+}
+
+type NackData struct {
+	RequestID string `json:"request_id"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Version   int64  `json:"version"`
+}
+
+type PlaybackInitData struct {
+	SessionID string  `json:"session_id"`
+	Events    int     `json:"events"`
+	StartedAt int64   `json:"started_at"`
+	EndedAt   int64   `json:"ended_at"`
+	Speed     float64 `json:"speed"`
+}
+
+type PlaybackEventData struct {
+	Index int                     `json:"index"`
+	Event resources.TimelineEvent `json:"event"`
+}
+
+type PlaybackStateData struct {
+	Paused bool    `json:"paused"`
+	Speed  float64 `json:"speed"`
+	Index  int     `json:"index"`
+}
+
+type ErrorData struct {
+	RequestID string `json:"request_id,omitempty"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+}
+
+// Error codes carried in ErrorData.Code.
+const (
+	errInvalidMessage   = "invalid_message"
+	errMissingID        = "missing_id"
+	errVersionMismatch  = "version_mismatch"
+	errOverloaded       = "overloaded"
+	errForbidden        = "forbidden"
+	errRestoreVersion   = "restore_version_error"
+	errUndo             = "undo_error"
+	errUndoConflict     = "undo_conflict"
+	errReadOnly         = "read_only"
+	errSessionLifecycle = "session_lifecycle_error"
+	errTimer            = "timer_error"
+	errUnknownType      = "unknown_type"
+	errCodePatch        = "code_patch_error"
+	errEditLang         = "edit_lang_error"
+	errRateLimited      = "rate_limited"
+	errRun              = "run_error"
+	errJudge            = "judge_error"
+	errSwitchQuestion   = "switch_question_error"
+	errStatementPatch   = "statement_patch_error"
+	errNotesPatch       = "notes_patch_error"
+	errScorecard        = "scorecard_error"
+	errChat             = "chat_error"
+	errComment          = "comment_error"
+	errFollow           = "follow_error"
+	errDrive            = "drive_error"
+	errModeration       = "moderation_error"
+	errSessionState     = "session_state_error"
+)
+
+var inboundMessages = map[string]interface{}{
+	"code_patch":       CodePatchData{},
+	"code_run":         EmptyData{},
+	"judge":            EmptyData{},
+	"cursor_select":    CursorSelectData{},
+	"edit_lang":        EditLangData{},
+	"refresh":          EmptyData{},
+	"restore_version":  RestoreVersionData{},
+	"undo":             EmptyData{},
+	"redo":             EmptyData{},
+	"start_session":    EmptyData{},
+	"end_session":      EmptyData{},
+	"timer_control":    TimerControlData{},
+	"switch_question":  SwitchQuestionData{},
+	"statement_patch":  CodePatchData{},
+	"notes_patch":      CodePatchData{},
+	"scorecard_update": ScorecardUpdateData{},
+	"focus_lost":       EmptyData{},
+	"focus_gained":     EmptyData{},
+	"chat_message":     ChatMessageData{},
+	"comment_create":   CommentCreateData{},
+	"comment_reply":    CommentReplyData{},
+	"comment_resolve":  CommentResolveData{},
+	"follow":           FollowData{},
+	"drive_lock":       DriveData{},
+	"drive_request":    EmptyData{},
+	"drive_grant":      DriveData{},
+	"drive_revoke":     EmptyData{},
+	"kick":             ModerationData{},
+	"ban":              ModerationData{},
+	"mute":             ModerationData{},
+
+	// Only on /ws/playback.
+	"playback_control": PlaybackControlData{},
+}
+
+var outboundMessages = map[string]interface{}{
+	"session_init":    SessionInitData{},
+	"session_state":   SessionStateData{},
+	"timer":           TimerData{},
+	"user_joined":     UserInfo{},
+	"user_left":       UserPresenceData{},
+	"user_status":     UserInfo{},
+	"user_removed":    UserRemovedData{},
+	"user_muted":      UserMutedData{},
+	"code_patch":      CodePatchEvent{},
+	"batch":           BatchData{},
+	"cursor_select":   CursorSelectEvent{},
+	"follow_update":   CursorSelectEvent{},
+	"edit_lang":       EditLangEvent{},
+	"code_res":        CodeResultData{},
+	"judge_result":    JudgeResultData{},
+	"question":        QuestionData{},
+	"statement":       DocumentData{},
+	"statement_patch": DocumentPatchEvent{},
+	"notes":           DocumentData{},
+	"notes_patch":     DocumentPatchEvent{},
+	"scorecard":       resources.Scorecard{},
+	"integrity":       IntegrityEventData{},
+	"chat_message":    resources.ChatMessage{},
+	"comment_thread":  resources.CommentThread{},
+	"drive":           resources.DriveLock{},
+	"ack":             AckData{},
+	"nack":            NackData{},
+	"error":           ErrorData{},
+
+	// Only on /ws/playback.
+	"playback_init":  PlaybackInitData{},
+	"playback_event": PlaybackEventData{},
+	"playback_state": PlaybackStateData{},
+	"playback_reset": EmptyData{},
+	"playback_end":   EmptyData{},
+}
+
+func encodeMessage(msgType string, data interface{}) []byte {
+	msgBytes, err := json.Marshal(outboundMessage{Type: msgType, Data: data})
+	if err != nil {
+		log.Printf("Error marshalling %s message: %v", msgType, err)
+		return nil
+	}
+	return msgBytes
+}
+
+func decodeMessageData(msg Message, target interface{}) error {
+	if len(msg.Data) == 0 {
+		return fmt.Errorf("missing data in %s message", msg.Type)
+	}
+	if err := json.Unmarshal(msg.Data, target); err != nil {
+		return fmt.Errorf("invalid data in %s message: %w", msg.Type, err)
+	}
+	return nil
+}
+
+func negotiateProtocolVersion(requested string) (int, error) {
+	if requested == "" {
+		return MinProtocolVersion, nil
+	}
+	version, err := strconv.Atoi(requested)
+	if err != nil {
+		return 0, fmt.Errorf("invalid protocol_version %q", requested)
+	}
+	if version < MinProtocolVersion {
+		return 0, fmt.Errorf("protocol_version %d is no longer supported, minimum is %d", version, MinProtocolVersion)
+	}
+	if version > ProtocolVersion {
+		return ProtocolVersion, nil
+	}
+	return version, nil
+}
+
+func messageSchemas(messages map[string]interface{}) map[string]*jsonschema.Schema {
+	reflector := jsonschema.Reflector{
+		DoNotReference: true,
+		ExpandedStruct: true,
+	}
+	schemas := make(map[string]*jsonschema.Schema, len(messages))
+	for msgType, data := range messages {
+		schema := reflector.Reflect(data)
+		schema.Version = ""
+		schemas[msgType] = schema
+	}
+	return schemas
+}
+
+// ProtocolSchemaDocument describes every websocket message by type, split by
+// direction, as JSON Schemas generated from the Go message types.
+func ProtocolSchemaDocument() map[string]interface{} {
+	return map[string]interface{}{
+		"$schema":              jsonschema.Version,
+		"title":                "CodeStream websocket protocol",
+		"protocol_version":     ProtocolVersion,
+		"min_protocol_version": MinProtocolVersion,
+		"subprotocols":         []string{subprotocolJSON, subprotocolMsgpack},
+		"envelope": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"type": map[string]string{"type": "string"},
+				"id":   map[string]string{"type": "string", "description": "Client-generated, required from protocol 2"},
+				"data": map[string]string{"type": "object"},
+			},
+			"required": []string{"type"},
+		},
+		"inbound":  messageSchemas(inboundMessages),
+		"outbound": messageSchemas(outboundMessages),
+	}
+}
+
+func WriteProtocolSchema(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(ProtocolSchemaDocument())
+}
+
+func ProtocolSchema(c *gin.Context) {
+	c.JSON(http.StatusOK, ProtocolSchemaDocument())
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"CodeStream/src/resources"
+	"errors"
+	"log"
+)
+
+// switchAttempts bounds the retries of a switch that raced with patches
+// committed on other instances.
+const switchAttempts = 3
+
+// processSwitchQuestion keeps the active question's code and statement, moves
+// the session to the next question and replaces both with its own. Local
+// flushes wait for the switch, so patches written against the previous
+// question are rejected and their senders resynced. If either cannot be
+// replaced the switch is reverted, so the active question always matches the
+// code and the statement.
+func (c *Client) processSwitchQuestion(requestID string, req SwitchQuestionData) error {
+	h := c.Hub
+	h.batch.flushMu.Lock()
+	defer h.batch.flushMu.Unlock()
+
+	questions, err := h.Interview.Questions()
+	if err != nil {
+		return err
+	}
+	if req.Index < 0 || req.Index >= len(questions.Questions) {
+		return resources.ErrQuestionNotFound
+	}
+	if req.Index == questions.Active {
+		c.acknowledge(requestID, h.currentVersion())
+		return nil
+	}
+	lang, code := questions.Questions[req.Index].Document()
+	statement := questions.Questions[req.Index].StatementDocument()
+
+	from := questions.Active
+	for attempt := 0; attempt < switchAttempts; attempt++ {
+		h.interviewMu.Lock()
+		current, version, err := h.Interview.CurrentCode()
+		currentLang := h.Interview.Language
+		h.interviewMu.Unlock()
+		if err != nil {
+			return err
+		}
+		currentStatement, statementVersion, err := h.statement.current()
+		if err != nil {
+			return err
+		}
+
+		questions, err = h.Interview.SwitchQuestion(from, req.Index, currentLang, current, version, currentStatement, c.Username)
+		if err != nil {
+			return err
+		}
+		err = h.replaceStatement(currentStatement, statement, statementVersion)
+		if err == nil {
+			err = h.replaceDocument(current, code, version)
+			if err == nil {
+				break
+			}
+			if revertErr := h.replaceStatement(statement, currentStatement, statementVersion+1); revertErr != nil {
+				log.Printf("Error restoring statement of session %s: %v", h.SessionID, revertErr)
+			}
+		}
+		if revertErr := h.Interview.RevertQuestionSwitch(from, req.Index, c.Username); revertErr != nil {
+			log.Printf("Error reverting question switch of session %s: %v", h.SessionID, revertErr)
+			return err
+		}
+		if !errors.Is(err, resources.ErrVersionMismatch) || attempt == switchAttempts-1 {
+			return err
+		}
+	}
+
+	h.interviewMu.Lock()
+	err = h.Interview.EditLanguage(lang)
+	h.interviewMu.Unlock()
+	if err != nil {
+		log.Printf("Error switching session %s to %s: %v", h.SessionID, lang, err)
+	}
+
+	h.broadcastAll(encodeMessage("question", newQuestionData(questions, lang)))
+	c.acknowledge(requestID, h.currentVersion())
+	return nil
+}
+
+// replaceDocument commits the patch turning current, at version, into target
+// and broadcasts it. The patch has no author, so no client skips it.
+func (h *Hub) replaceDocument(current string, target string, version int64) error {
+	data, changed := documentReplacePatch(current, target)
+	if !changed {
+		return nil
+	}
+	patch := data.toCodePatch()
+	patch.Version = version + 1
+	patch.Source = resources.PatchSourceQuestion
+
+	h.interviewMu.Lock()
+	results, err := h.Interview.AddCodePatches([]resources.CodePatch{patch})
+	h.interviewMu.Unlock()
+	if err != nil {
+		return err
+	}
+	if results[0].Err != nil {
+		return results[0].Err
+	}
+
+	h.broadcastBatch(BatchData{Patches: []CodePatchEvent{{
+		Version:  results[0].Version,
+		Op:       data.Op,
+		StartPos: data.StartPos,
+		EndPos:   data.EndPos,
+		Content:  data.Content,
+	}}})
+	return nil
+}
+
+func (h *Hub) questionData(lang string) *QuestionData {
+	questions, err := h.Interview.Questions()
+	if errors.Is(err, resources.ErrNoQuestions) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("Error loading questions for session %s: %v", h.SessionID, err)
+		return nil
+	}
+	return newQuestionData(questions, lang)
+}
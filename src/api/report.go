@@ -0,0 +1,67 @@
+package api
+
+import (
+	"CodeStream/src/resources"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// canReview reports whether the request may see what only interviewers of the
+// session see: it carries the ADMIN_TOKEN or the session's interviewer key.
+func canReview(c *gin.Context, cache *resources.Cache, sessionID string) bool {
+	return isAdmin(c) || resolveRole(&resources.Interview{SessionID: sessionID, Cache: cache}, c.Query("key")) == RoleInterviewer
+}
+
+// SessionReport exports the debrief report of a session as html (the
+// default), md or pdf, chosen with the format query parameter. The report
+// holds the interviewers' notes, so it needs the ADMIN_TOKEN or, while the
+// session is in Redis, its interviewer key as the key query parameter.
+func SessionReport(c *gin.Context) {
+	sessionID := c.Param("sessionID")
+	cache := resources.NewCacheContext()
+	if !canReview(c, cache, sessionID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	format := c.DefaultQuery("format", resources.ReportHTML)
+	if format != resources.ReportHTML && format != resources.ReportMarkdown && format != resources.ReportPDF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be html, md or pdf"})
+		return
+	}
+
+	report, err := resources.LoadSessionReport(c.Request.Context(), cache, sessionID)
+	if errors.Is(err, resources.ErrArchiveNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session does not exist"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error building report for session %s: %v", sessionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("codestream-%s.%s", sessionID, format)
+	switch format {
+	case resources.ReportMarkdown:
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(report.Markdown()))
+	case resources.ReportPDF:
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		c.Header("Content-Type", "application/pdf")
+		if err := report.WritePDF(c.Writer); err != nil {
+			log.Printf("Error writing PDF report for session %s: %v", sessionID, err)
+		}
+	default:
+		c.HTML(http.StatusOK, "report.html", gin.H{
+			"report": report,
+			"key":    c.Query("key"),
+			"notes":  template.HTML(resources.RenderMarkdown(report.Notes)),
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"CodeStream/src"
+	"CodeStream/src/resources"
+	"crypto/subtle"
+)
+
+const (
+	RoleInterviewer = "interviewer"
+	RoleCandidate   = "candidate"
+)
+
+// resolveRole grants the interviewer role to clients presenting the session's
+// interviewer key.
+func resolveRole(interview *resources.Interview, key string) string {
+	if key == "" {
+		return RoleCandidate
+	}
+	interviewerKey := interview.InterviewerKey()
+	if interviewerKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(interviewerKey)) == 1 {
+		return RoleInterviewer
+	}
+	return RoleCandidate
+}
+
+// isInterviewer reports whether the client may use interviewer-only actions.
+// With roles disabled every participant may.
+func (c *Client) isInterviewer() bool {
+	return !src.Config.RolesEnabled || c.Role == RoleInterviewer
+}
+
+// hasInterviewerRole reports whether the client presented the interviewer
+// key. Private notes and the scorecard need it even with roles disabled,
+// where every participant may use the interviewer-only actions.
+func (c *Client) hasInterviewerRole() bool {
+	return c.Role == RoleInterviewer
+}
@@ -1,12 +1,19 @@
 package api
 
 import (
+	"CodeStream/src"
 	"CodeStream/src/resources"
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// maxSessionProblems is how many questions a session can be created with.
+const maxSessionProblems = 10
+
 func StartSession(c *gin.Context) {
 	c.HTML(200, "ground.html", gin.H{})
 	return
@@ -14,12 +21,24 @@ func StartSession(c *gin.Context) {
 
 func CreateSession(c *gin.Context) {
 	var body struct {
-		CaptchaResponse string `json:"captcha" binding:"required"`
+		CaptchaResponse string    `json:"captcha" binding:"required"`
+		ScheduledAt     time.Time `json:"scheduled_at"`
+		DurationMinutes int       `json:"duration_minutes" binding:"min=0"`
+		ProblemID       string    `json:"problem_id"`
+		ProblemIDs      []string  `json:"problem_ids"`
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	problemIDs := body.ProblemIDs
+	if body.ProblemID != "" {
+		problemIDs = append([]string{body.ProblemID}, problemIDs...)
+	}
+	if len(problemIDs) > maxSessionProblems {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("a session can have at most %d problems", maxSessionProblems)})
+		return
+	}
 
 	if !resources.ValidateCaptcha(body.CaptchaResponse) {
 		c.JSON(400, gin.H{"error": "Captcha error"})
@@ -31,12 +50,38 @@ func CreateSession(c *gin.Context) {
 		c.JSON(429, gin.H{"error": "Too many sessions"})
 		return
 	}
-	interview, err, _ := resources.CreateInterviewSession(cache)
+	options := resources.SessionOptions{
+		ScheduledAt: body.ScheduledAt,
+		Duration:    src.Config.SessionDuration,
+	}
+	if body.DurationMinutes > 0 {
+		options.Duration = time.Duration(body.DurationMinutes) * time.Minute
+	}
+	if len(problemIDs) > 0 && resources.Problems == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Problem bank is not configured"})
+		return
+	}
+	for _, problemID := range problemIDs {
+		problem, err := resources.Problems.Get(c.Request.Context(), problemID)
+		if errors.Is(err, resources.ErrProblemNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: %s", err, problemID)})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		options.Problems = append(options.Problems, problem)
+	}
+	interview, err, _ := resources.CreateInterviewSession(cache, options)
 
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"session_id": interview.SessionID})
+	c.JSON(200, gin.H{
+		"session_id":      interview.SessionID,
+		"interviewer_key": interview.InterviewerKey(),
+	})
 	return
 }
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"CodeStream/src/resources"
+	"errors"
+	"log"
+	"sync"
+)
+
+// hubDocument serialises the changes to one of the session's markdown
+// documents, the statement or the notes. They are rare next to code patches,
+// so each is committed on its own.
+type hubDocument struct {
+	mu       sync.Mutex
+	document *resources.Interview
+}
+
+// current returns the document and its version.
+func (d *hubDocument) current() (string, int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.document.CurrentCode()
+}
+
+// data returns the whole document, or nil if it cannot be read.
+func (d *hubDocument) data(sessionID string) *DocumentData {
+	markdown, version, err := d.current()
+	if err != nil {
+		log.Printf("Error loading %s of session %s: %v", d.document.Document, sessionID, err)
+		return nil
+	}
+	return &DocumentData{
+		Version:  version,
+		Markdown: markdown,
+		HTML:     resources.RenderMarkdown(markdown),
+	}
+}
+
+// commit applies patch and returns the version it was committed at with the
+// document after it.
+func (d *hubDocument) commit(patch resources.CodePatch) (int64, string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.document.AddCodePatch(patch); err != nil {
+		return 0, "", err
+	}
+	markdown, _, err := d.document.CurrentCode()
+	return d.document.Version, markdown, err
+}
+
+func (h *Hub) statementData() *DocumentData {
+	return h.statement.data(h.SessionID)
+}
+
+// replaceStatement commits the patch turning the statement, current at
+// version, into target and broadcasts it, rendered, to everyone. Like the code
+// replaced on a question switch, the patch has no author.
+func (h *Hub) replaceStatement(current string, target string, version int64) error {
+	data, changed := documentReplacePatch(current, target)
+	if !changed {
+		return nil
+	}
+	patch := data.toCodePatch()
+	patch.Version = version + 1
+	patch.Source = resources.PatchSourceQuestion
+
+	committed, markdown, err := h.statement.commit(patch)
+	if err != nil {
+		return err
+	}
+	h.broadcastAll(encodeMessage("statement_patch", DocumentPatchEvent{
+		Version:  committed,
+		Op:       data.Op,
+		StartPos: data.StartPos,
+		EndPos:   data.EndPos,
+		Content:  data.Content,
+		HTML:     resources.RenderMarkdown(markdown),
+	}))
+	return nil
+}
+
+// processStatementPatch commits a change to the statement through the same
+// path as code patches and broadcasts it, rendered, to everyone.
+func (c *Client) processStatementPatch(requestID string, req CodePatchData) {
+	c.processDocumentPatch(requestID, req, &c.Hub.statement, "statement", errStatementPatch, c.Hub.broadcastAll)
+}
+
+// processDocumentPatch commits req to doc and sends the change, rendered, with
+// broadcast, reporting whether it was committed. A client that edited a stale
+// version gets the whole document back as a msgType message.
+func (c *Client) processDocumentPatch(requestID string, req CodePatchData, doc *hubDocument, msgType string, errCode string, broadcast func([]byte)) bool {
+	h := c.Hub
+	if req.Op != "add" && req.Op != "remove" && req.Op != "replace" {
+		c.reject(requestID, errCode, "invalid operation: "+req.Op)
+		return false
+	}
+	patch := req.toCodePatch()
+	patch.Author = c.Username
+
+	version, markdown, err := doc.commit(patch)
+	if errors.Is(err, resources.ErrVersionMismatch) {
+		c.reject(requestID, errVersionMismatch, err.Error())
+		if data := doc.data(h.SessionID); data != nil {
+			data.Editable = true
+			c.sendMessage(msgType, data)
+		}
+		return false
+	}
+	if err != nil {
+		c.reject(requestID, errCode, err.Error())
+		return false
+	}
+
+	broadcast(encodeMessage(msgType+"_patch", DocumentPatchEvent{
+		Username: c.Username,
+		Version:  version,
+		Op:       req.Op,
+		StartPos: req.StartPos,
+		EndPos:   req.EndPos,
+		Content:  req.Content,
+		HTML:     resources.RenderMarkdown(markdown),
+	}))
+	c.acknowledge(requestID, version)
+	return true
+}
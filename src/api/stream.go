@@ -4,33 +4,54 @@ import (
 	"CodeStream/src"
 	"CodeStream/src/resources"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/redis/go-redis/v9"
 )
 
 var upgrader = websocket.Upgrader{
-	ReadBufferSize:  4096,
-	WriteBufferSize: 4096,
+	ReadBufferSize:    4096,
+	WriteBufferSize:   4096,
+	Subprotocols:      []string{subprotocolMsgpack, subprotocolJSON},
+	EnableCompression: true,
 	CheckOrigin: func(r *http.Request) bool {
 		return true
 	},
 }
 
 type Client struct {
-	Username string
-	Conn     *websocket.Conn
-	Hub      *Hub
-	Send     chan []byte
-	mu       sync.Mutex
+	Username        string
+	Role            string
+	ProtocolVersion int
+	Codec           codec
+	Conn            *websocket.Conn
+	Hub             *Hub
+	Send            chan *outFrame
+	pending         *pendingRequests
+	mu              sync.Mutex
+
+	// Identity is who the participant is across reconnects, and muted is set
+	// while they are read-only.
+	Identity resources.ParticipantIdentity
+	muted    atomic.Bool
+
+	// away and chat are only touched by the client's read loop.
+	away     bool
+	chat     chatLimiter
+	presence clientPresence
+
+	closed    chan struct{}
+	closeOnce sync.Once
 }
 
 type Hub struct {
@@ -43,16 +64,21 @@ type Hub struct {
 	register   chan *Client
 	unregister chan *Client
 	broadcast  chan []byte
+	events     *redis.PubSub
+
+	batch        hubBatch
+	statement    hubDocument
+	notes        hubDocument
+	metrics      hubMetrics
+	sessionState hubLifecycle
+	timer        hubTimer
+	follow       hubFollow
+	drive        hubDrive
 
 	shutdown chan struct{}
 	done     chan struct{}
 }
 
-type Message struct {
-	Type string                 `json:"type"`
-	Data map[string]interface{} `json:"data,omitempty"`
-}
-
 var (
 	Sessions   = make(map[string]*Hub)
 	sessionsMu sync.RWMutex
@@ -64,6 +90,10 @@ const (
 	pingPeriod     = (pongWait * 9) / 10
 	maxMessageSize = 2048
 	sendBufferSize = 2048
+
+	// Deflating a keystroke-sized frame costs CPU and makes it larger, see
+	// BenchmarkCodec; only bigger frames such as session_init are compressed.
+	compressionThreshold = 512
 )
 
 func NewHub(sessionID string, cache *resources.Cache) (*Hub, error) {
@@ -96,9 +126,29 @@ func GetHub(sessionID string, cache *resources.Cache) (*Hub, error) {
 	if err != nil {
 		return nil, err
 	}
+	lifecycle, err := hub.Interview.Lifecycle()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session state: %w", err)
+	}
+	hub.applyLifecycle(lifecycle)
+	if err := hub.loadTimer(); err != nil {
+		return nil, fmt.Errorf("failed to get session timer: %w", err)
+	}
+	if hub.statement.document, err = hub.Interview.Statement(); err != nil {
+		return nil, fmt.Errorf("failed to get session statement: %w", err)
+	}
+	if hub.notes.document, err = hub.Interview.Notes(); err != nil {
+		return nil, fmt.Errorf("failed to get session notes: %w", err)
+	}
+	if err := hub.loadDrive(); err != nil {
+		return nil, fmt.Errorf("failed to get session drive lock: %w", err)
+	}
 
+	hub.events = resources.SubscribeSessionEvents(cache, sessionID)
 	Sessions[sessionID] = hub
 	go hub.run()
+	go hub.listen()
+	go hub.tickPresence()
 
 	return hub, nil
 }
@@ -117,48 +167,38 @@ func (h *Hub) run() {
 			log.Printf("Client %s joined session %s. Total clients: %d",
 				client.Username, h.SessionID, clientCount)
 
-			msg := Message{
-				Type: "user_joined",
-				Data: map[string]interface{}{
-					"username": client.Username,
-				},
-			}
-			msgBytes, err := json.Marshal(msg)
-			if err != nil {
-				log.Printf("Error marshalling message: %v", err)
-				return
-			}
-			h.broadcastToOthers(client, msgBytes)
+			h.publishStatus(client, false)
+			h.broadcastToOthers(client, encodeMessage("user_joined", client.userInfo()))
+			h.recordEvent(resources.TimelineJoin, client.Username, nil)
 
 		case client := <-h.unregister:
 			h.mu.Lock()
 			if _, ok := h.Clients[client.Username]; ok {
 				delete(h.Clients, client.Username)
-				close(client.Send)
+				client.close()
 			}
 			clientCount := len(h.Clients)
 			h.mu.Unlock()
+			h.forgetFollow(client)
+			resources.ReleasePresence(h.Interview.Cache, h.SessionID, client.Username)
+			h.releaseDrive(client)
+			if err := h.Interview.ForgetUndoHistory(client.Username); err != nil {
+				log.Printf("Error clearing undo history of %s in session %s: %v", client.Username, h.SessionID, err)
+			}
 
 			log.Printf("Client %s left session %s. Remaining clients: %d",
 				client.Username, h.SessionID, clientCount)
 
-			msg := Message{
-				Type: "user_left",
-				Data: map[string]interface{}{
-					"username": client.Username,
-				},
-			}
-			msgBytes, err := json.Marshal(msg)
-			if err != nil {
-				log.Printf("Error marshalling message: %v", err)
-				return
-			}
-			h.broadcastToOthers(client, msgBytes)
+			h.broadcastToOthers(client, encodeMessage("user_left", UserPresenceData{Username: client.Username}))
+			h.recordEvent(resources.TimelineLeave, client.Username, nil)
 
 			if clientCount == 0 {
 				sessionsMu.Lock()
 				delete(Sessions, h.SessionID)
 				sessionsMu.Unlock()
+				_ = h.events.Close()
+				h.stopLifecycle()
+				h.stopTimer()
 				log.Printf("Session %s cleaned up", h.SessionID)
 				return
 			}
@@ -166,10 +206,11 @@ func (h *Hub) run() {
 		case message := <-h.broadcast:
 			h.mu.Lock()
 			clientsToRemove := make([]*Client, 0)
+			frame := newOutFrame(message)
 
 			for _, client := range h.Clients {
 				select {
-				case client.Send <- message:
+				case client.Send <- frame:
 				default:
 					clientsToRemove = append(clientsToRemove, client)
 				}
@@ -177,7 +218,9 @@ func (h *Hub) run() {
 
 			for _, client := range clientsToRemove {
 				delete(h.Clients, client.Username)
-				close(client.Send)
+				client.close()
+				h.forgetFollow(client)
+				resources.ReleasePresence(h.Interview.Cache, h.SessionID, client.Username)
 				log.Printf("Removed unresponsive client: %s", client.Username)
 			}
 			h.mu.Unlock()
@@ -185,30 +228,186 @@ func (h *Hub) run() {
 		case <-h.shutdown:
 			h.mu.Lock()
 			for _, client := range h.Clients {
-				close(client.Send)
+				client.close()
 				_ = client.Conn.Close()
+				h.forgetFollow(client)
+				resources.ReleasePresence(h.Interview.Cache, h.SessionID, client.Username)
 			}
 			h.Clients = make(map[string]*Client)
 			h.mu.Unlock()
+			_ = h.events.Close()
+			h.stopLifecycle()
+			h.stopTimer()
 			return
 		}
 	}
 }
 
+// broadcastToOthers delivers msg to every participant of the session except
+// the sender, both on this instance and on other instances via Redis.
 func (h *Hub) broadcastToOthers(sender *Client, msg []byte) {
+	h.deliverLocal(sender.Username, msg)
+
+	if err := resources.PublishSessionEvent(h.Interview.Cache, h.SessionID, sender.Username, msg); err != nil {
+		log.Printf("Error publishing event for session %s: %v", h.SessionID, err)
+	}
+}
 
+// broadcastAll delivers msg to every participant of the session.
+func (h *Hub) broadcastAll(msg []byte) {
+	h.deliverLocal("", msg)
+
+	if err := resources.PublishSessionEvent(h.Interview.Cache, h.SessionID, "", msg); err != nil {
+		log.Printf("Error publishing event for session %s: %v", h.SessionID, err)
+	}
+}
+
+// broadcastInterviewers delivers msg to the participants holding the
+// interviewer role.
+func (h *Hub) broadcastInterviewers(msg []byte) {
+	h.deliverInterviewers(msg)
+
+	if err := resources.PublishPrivateSessionEvent(h.Interview.Cache, h.SessionID, msg); err != nil {
+		log.Printf("Error publishing event for session %s: %v", h.SessionID, err)
+	}
+}
+
+func (h *Hub) deliverInterviewers(msg []byte) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
+	frame := newOutFrame(msg)
 	for _, client := range h.Clients {
-		if client != sender {
-			select {
-			case client.Send <- msg:
-			default:
-				log.Printf("Client %s channel full, will be cleaned up", client.Username)
+		if client.hasInterviewerRole() {
+			h.enqueue(client, frame)
+		}
+	}
+}
+
+func (h *Hub) deliverLocal(exceptUsername string, msg []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	frame := newOutFrame(msg)
+	for _, client := range h.Clients {
+		if client.Username != exceptUsername {
+			h.enqueue(client, frame)
+		}
+	}
+}
+
+func (h *Hub) enqueue(client *Client, frame *outFrame) {
+	select {
+	case client.Send <- frame:
+	default:
+		h.metrics.DroppedFrames.Add(1)
+		log.Printf("Client %s channel full, will be cleaned up", client.Username)
+	}
+}
+
+// listen relays events published by other instances to the local clients.
+func (h *Hub) listen() {
+	for redisMsg := range h.events.Channel() {
+		var event resources.SessionEvent
+		if err := json.Unmarshal([]byte(redisMsg.Payload), &event); err != nil {
+			log.Printf("Error decoding event for session %s: %v", h.SessionID, err)
+			continue
+		}
+		if event.Origin == resources.InstanceID {
+			continue
+		}
+		if event.Private {
+			h.deliverInterviewers(event.Payload)
+			continue
+		}
+		if event.Leader != "" {
+			h.deliverRemoteFollow(event.Leader, event.Payload)
+			continue
+		}
+
+		if batch, ok := h.applyRemoteEvent(event.Payload); ok {
+			h.deliverBatchLocal(batch, event.Payload)
+			continue
+		}
+		h.deliverLocal(event.Sender, event.Payload)
+	}
+}
+
+// applyRemoteEvent keeps the local Interview in step with changes committed
+// by participants connected to other instances. Batches are returned decoded
+// so they can be expanded for older clients.
+func (h *Hub) applyRemoteEvent(payload []byte) (BatchData, bool) {
+	var msg Message
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return BatchData{}, false
+	}
+
+	switch msg.Type {
+	case "batch":
+		var batch BatchData
+		if decodeMessageData(msg, &batch) != nil {
+			return BatchData{}, false
+		}
+		h.interviewMu.Lock()
+		for _, patch := range batch.Patches {
+			if patch.Version > h.Interview.Version {
+				h.Interview.Version = patch.Version
 			}
 		}
+		h.interviewMu.Unlock()
+		return batch, true
+	case "edit_lang":
+		var event EditLangEvent
+		if decodeMessageData(msg, &event) != nil {
+			return BatchData{}, false
+		}
+		h.interviewMu.Lock()
+		h.Interview.Language = event.Lang
+		h.interviewMu.Unlock()
+	case "session_state":
+		lifecycle, err := h.Interview.Lifecycle()
+		if err != nil {
+			log.Printf("Error loading state of session %s: %v", h.SessionID, err)
+			return BatchData{}, false
+		}
+		h.applyLifecycle(lifecycle)
+	case "question":
+		var question QuestionData
+		if decodeMessageData(msg, &question) != nil {
+			return BatchData{}, false
+		}
+		h.interviewMu.Lock()
+		h.Interview.Language = question.Lang
+		h.interviewMu.Unlock()
+	case "timer":
+		if err := h.loadTimer(); err != nil {
+			log.Printf("Error loading timer of session %s: %v", h.SessionID, err)
+		}
+	case "drive":
+		if err := h.loadDrive(); err != nil {
+			log.Printf("Error loading drive lock of session %s: %v", h.SessionID, err)
+		}
+	case "user_muted":
+		h.refreshMuted()
+	case "user_removed":
+		var removed UserRemovedData
+		if decodeMessageData(msg, &removed) == nil {
+			h.disconnect(removed.Username, removed.Reason)
+		}
 	}
+	return BatchData{}, false
+}
+
+func (h *Hub) recordEvent(eventType string, author string, data interface{}) {
+	if err := h.Interview.RecordEvent(eventType, author, data); err != nil {
+		log.Printf("Error recording %s event for session %s: %v", eventType, h.SessionID, err)
+	}
+}
+
+func (h *Hub) currentVersion() int64 {
+	h.interviewMu.Lock()
+	defer h.interviewMu.Unlock()
+	return h.Interview.Version
 }
 
 func (h *Hub) Shutdown() {
@@ -229,15 +428,22 @@ func (c *Client) writePump() {
 
 	for {
 		select {
-		case message, ok := <-c.Send:
+		case <-c.closed:
 			_ = c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if !ok {
-				_ = c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
+			_ = c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+			return
+
+		case frame := <-c.Send:
+			_ = c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			message, err := frame.encode(c.Codec)
+			if err != nil {
+				log.Printf("Error encoding message for client %s: %v", c.Username, err)
+				continue
 			}
 
 			c.mu.Lock()
-			err := c.Conn.WriteMessage(websocket.TextMessage, message)
+			c.Conn.EnableWriteCompression(len(message) >= compressionThreshold)
+			err = c.Conn.WriteMessage(c.Codec.FrameType(), message)
 			c.mu.Unlock()
 
 			if err != nil {
@@ -274,8 +480,7 @@ func (c *Client) readPump() {
 	})
 
 	for {
-		var msg Message
-		err := c.Conn.ReadJSON(&msg)
+		_, frame, err := c.Conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error for client %s: %v", c.Username, err)
@@ -283,271 +488,438 @@ func (c *Client) readPump() {
 			break
 		}
 
-		switch msg.Type {
-		case "code_patch":
-			if err := c.processCodePatch(msg); err != nil {
-				log.Printf("Error processing code patch from %s: %v", c.Username, err)
-				errorMsg := Message{
-					Type: "error",
-					Data: map[string]interface{}{
-						"message": err.Error(),
-						"type":    "code_patch_error",
-					},
-				}
-				if jsonData, marshalErr := json.Marshal(errorMsg); marshalErr == nil {
-					select {
-					case c.Send <- jsonData:
-					default:
-					}
-				}
-			}
-		case "code_run":
-			go c.processRunCode()
-		case "cursor_select":
-			c.processCursorSelect(msg)
-		case "edit_lang":
-			c.processEditLang(msg)
-		case "refresh":
-			c.sendCurrentState()
+		var msg Message
+		data, err := c.Codec.Decode(frame)
+		if err == nil {
+			err = json.Unmarshal(data, &msg)
+		}
+		if err != nil || msg.Type == "" {
+			c.sendError("", errInvalidMessage, "malformed message")
+			continue
+		}
+		c.handleMessage(msg)
+	}
+}
 
-		default:
-			log.Printf("Unknown message type from %s: %s", c.Username, msg.Type)
+func (c *Client) handleMessage(msg Message) {
+	if c.ProtocolVersion >= 2 {
+		if msg.ID == "" {
+			c.sendError("", errMissingID, fmt.Sprintf("%s message has no id", msg.Type))
+			return
 		}
+		if !c.claimRequest(msg) {
+			return
+		}
+	}
+
+	if writeMessages[msg.Type] {
+		if err := c.checkWritable(); err != nil {
+			c.reject(msg.ID, errReadOnly, err.Error())
+			return
+		}
+	}
+	if msg.Type != "focus_lost" {
+		c.touch(msg.Type == "code_patch")
+	}
+
+	switch msg.Type {
+	case "code_patch":
+		var req CodePatchData
+		if err := decodeMessageData(msg, &req); err != nil {
+			c.reject(msg.ID, errInvalidMessage, err.Error())
+			return
+		}
+		if err := c.processCodePatch(msg.ID, req); err != nil {
+			if errors.Is(err, errHubOverloaded) {
+				c.reject(msg.ID, errOverloaded, err.Error())
+				return
+			}
+			if errors.Is(err, errNotDriver) {
+				c.reject(msg.ID, errDrive, err.Error())
+				return
+			}
+			log.Printf("Error processing code patch from %s: %v", c.Username, err)
+			c.reject(msg.ID, errCodePatch, err.Error())
+		}
+	case "code_run":
+		go c.processRunCode(msg.ID)
+	case "judge":
+		go c.processJudge(msg.ID)
+	case "cursor_select":
+		var req CursorSelectData
+		if err := decodeMessageData(msg, &req); err != nil {
+			c.reject(msg.ID, errInvalidMessage, err.Error())
+			return
+		}
+		c.processCursorSelect(req)
+		c.acknowledge(msg.ID, c.Hub.currentVersion())
+	case "edit_lang":
+		var req EditLangData
+		if err := decodeMessageData(msg, &req); err != nil {
+			c.reject(msg.ID, errInvalidMessage, err.Error())
+			return
+		}
+		if err := c.processEditLang(req); err != nil {
+			log.Printf("Error editing language to %s: %v", req.Lang, err)
+			c.reject(msg.ID, errEditLang, err.Error())
+			return
+		}
+		c.acknowledge(msg.ID, c.Hub.currentVersion())
+	case "restore_version":
+		var req RestoreVersionData
+		if err := decodeMessageData(msg, &req); err != nil {
+			c.reject(msg.ID, errInvalidMessage, err.Error())
+			return
+		}
+		if !c.isInterviewer() {
+			c.reject(msg.ID, errForbidden, "only interviewers can restore versions")
+			return
+		}
+		if err := c.processRestoreVersion(msg.ID, req); err != nil {
+			if errors.Is(err, errHubOverloaded) {
+				c.reject(msg.ID, errOverloaded, err.Error())
+				return
+			}
+			c.reject(msg.ID, errRestoreVersion, err.Error())
+		}
+	case "undo", "redo":
+		if err := c.processUndo(msg.ID, msg.Type == "redo"); err != nil {
+			switch {
+			case errors.Is(err, errHubOverloaded):
+				c.reject(msg.ID, errOverloaded, err.Error())
+			case errors.Is(err, resources.ErrUndoConflict):
+				c.reject(msg.ID, errUndoConflict, err.Error())
+			case errors.Is(err, errNotDriver):
+				c.reject(msg.ID, errDrive, err.Error())
+			default:
+				c.reject(msg.ID, errUndo, err.Error())
+			}
+		}
+	case "switch_question":
+		var req SwitchQuestionData
+		if err := decodeMessageData(msg, &req); err != nil {
+			c.reject(msg.ID, errInvalidMessage, err.Error())
+			return
+		}
+		if !c.isInterviewer() {
+			c.reject(msg.ID, errForbidden, "only interviewers can switch questions")
+			return
+		}
+		if err := c.processSwitchQuestion(msg.ID, req); err != nil {
+			c.reject(msg.ID, errSwitchQuestion, err.Error())
+		}
+	case "statement_patch":
+		var req CodePatchData
+		if err := decodeMessageData(msg, &req); err != nil {
+			c.reject(msg.ID, errInvalidMessage, err.Error())
+			return
+		}
+		if !c.isInterviewer() {
+			c.reject(msg.ID, errForbidden, "only interviewers can edit the statement")
+			return
+		}
+		c.processStatementPatch(msg.ID, req)
+	case "notes_patch":
+		var req CodePatchData
+		if err := decodeMessageData(msg, &req); err != nil {
+			c.reject(msg.ID, errInvalidMessage, err.Error())
+			return
+		}
+		if !c.hasInterviewerRole() {
+			c.reject(msg.ID, errForbidden, "only interviewers can take notes")
+			return
+		}
+		c.processNotesPatch(msg.ID, req)
+	case "scorecard_update":
+		var req ScorecardUpdateData
+		if err := decodeMessageData(msg, &req); err != nil {
+			c.reject(msg.ID, errInvalidMessage, err.Error())
+			return
+		}
+		if !c.hasInterviewerRole() {
+			c.reject(msg.ID, errForbidden, "only interviewers can fill in the scorecard")
+			return
+		}
+		c.processScorecardUpdate(msg.ID, req)
+	case "start_session":
+		c.processLifecycle(msg.ID, resources.SessionLive)
+	case "end_session":
+		c.processLifecycle(msg.ID, resources.SessionEnded)
+	case "timer_control":
+		var req TimerControlData
+		if err := decodeMessageData(msg, &req); err != nil {
+			c.reject(msg.ID, errInvalidMessage, err.Error())
+			return
+		}
+		if !c.isInterviewer() {
+			c.reject(msg.ID, errForbidden, "only interviewers can control the timer")
+			return
+		}
+		if err := c.processTimerControl(msg.ID, req); err != nil {
+			c.reject(msg.ID, errTimer, err.Error())
+		}
+	case "comment_create":
+		var req CommentCreateData
+		if err := decodeMessageData(msg, &req); err != nil {
+			c.reject(msg.ID, errInvalidMessage, err.Error())
+			return
+		}
+		c.processCommentCreate(msg.ID, req)
+	case "comment_reply":
+		var req CommentReplyData
+		if err := decodeMessageData(msg, &req); err != nil {
+			c.reject(msg.ID, errInvalidMessage, err.Error())
+			return
+		}
+		c.processCommentReply(msg.ID, req)
+	case "comment_resolve":
+		var req CommentResolveData
+		if err := decodeMessageData(msg, &req); err != nil {
+			c.reject(msg.ID, errInvalidMessage, err.Error())
+			return
+		}
+		c.processCommentResolve(msg.ID, req)
+	case "follow":
+		var req FollowData
+		if err := decodeMessageData(msg, &req); err != nil {
+			c.reject(msg.ID, errInvalidMessage, err.Error())
+			return
+		}
+		c.processFollow(msg.ID, req)
+	case "drive_lock", "drive_grant":
+		var req DriveData
+		if err := decodeMessageData(msg, &req); err != nil {
+			c.reject(msg.ID, errInvalidMessage, err.Error())
+			return
+		}
+		c.processDrive(msg.ID, msg.Type, req)
+	case "drive_request", "drive_revoke":
+		c.processDrive(msg.ID, msg.Type, DriveData{})
+	case "kick", "ban", "mute":
+		var req ModerationData
+		if err := decodeMessageData(msg, &req); err != nil {
+			c.reject(msg.ID, errInvalidMessage, err.Error())
+			return
+		}
+		c.processModeration(msg.ID, msg.Type, req)
+	case "chat_message":
+		var req ChatMessageData
+		if err := decodeMessageData(msg, &req); err != nil {
+			c.reject(msg.ID, errInvalidMessage, err.Error())
+			return
+		}
+		c.processChatMessage(msg.ID, req)
+	case "focus_lost":
+		c.processFocus(msg.ID, false)
+	case "focus_gained":
+		c.processFocus(msg.ID, true)
+	case "refresh":
+		c.sendCurrentState()
+		c.acknowledge(msg.ID, c.Hub.currentVersion())
+
+	default:
+		log.Printf("Unknown message type from %s: %s", c.Username, msg.Type)
+		c.reject(msg.ID, errUnknownType, fmt.Sprintf("unknown message type: %s", msg.Type))
 	}
 }
 
-func (c *Client) processRunCode() {
+func (c *Client) send(msg []byte) {
+	if msg == nil {
+		return
+	}
+	select {
+	case c.Send <- newOutFrame(msg):
+	default:
+		c.Hub.metrics.DroppedFrames.Add(1)
+		log.Printf("Client %s channel full, dropping message", c.Username)
+	}
+}
+
+// close stops the client's writePump. Send is never closed, so late replies
+// from batches or runs are simply discarded.
+func (c *Client) close() {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+}
+
+func (c *Client) sendMessage(msgType string, data interface{}) {
+	c.send(encodeMessage(msgType, data))
+}
+
+func (c *Client) sendError(requestID string, code string, message string) {
+	c.sendMessage("error", ErrorData{
+		RequestID: requestID,
+		Code:      code,
+		Message:   message,
+	})
+}
+
+func (c *Client) processRunCode(requestID string) {
 	if !c.Hub.Interview.CanRun() {
-		errorMsg := Message{
-			Type: "error",
-			Data: map[string]interface{}{
-				"message": fmt.Sprintf("Rate limit exceeded"),
-			},
-		}
-		msgBytes, _ := json.Marshal(errorMsg)
-		c.Send <- msgBytes
+		c.reject(requestID, errRateLimited, "Rate limit exceeded")
 		return
 	}
 
 	c.Hub.interviewMu.Lock()
 	currentCode := c.Hub.Interview.CompactCodePatches()
+	version := c.Hub.Interview.Version
 	c.Hub.interviewMu.Unlock()
 
 	if currentCode == "" {
-		msg := Message{
-			Type: "code_res",
-			Data: map[string]interface{}{
-				"std_out":   "",
-				"std_err":   "",
-				"exit_code": -1,
-				"error":     "empty code",
-				"duration":  "0.00",
-			},
-		}
-		msgBytes, _ := json.Marshal(msg)
-		c.Send <- msgBytes
+		c.sendMessage("code_res", CodeResultData{
+			ExitCode: -1,
+			Error:    "empty code",
+			Duration: "0.00",
+		})
+		c.acknowledge(requestID, c.Hub.currentVersion())
 		return
 	}
 
 	req := resources.RunRequest{Language: c.Hub.Interview.Language, Code: currentCode}
+	if problem, err := c.Hub.Interview.Problem(); err == nil && problem != nil {
+		req.TimeLimitMs = problem.Judging.TimeLimitMs
+		req.MemoryLimitMB = problem.Judging.MemoryLimitMB
+	}
 
 	resp, err := resources.RunUserCode(c.Hub.Interview.Cache.Ctx, src.Config.CodeWorkDir, req)
 	if err != nil {
-		errorMsg := Message{
-			Type: "error",
-			Data: map[string]interface{}{
-				"message": err.Error(),
-			},
-		}
-		msgBytes, _ := json.Marshal(errorMsg)
-		c.Send <- msgBytes
+		c.reject(requestID, errRun, err.Error())
 		return
 	}
 
-	msg := Message{
-		Type: "code_res",
-		Data: map[string]interface{}{
-			"std_out":   resp.Stdout,
-			"std_err":   resp.Stderr,
-			"exit_code": resp.ExitCode,
-			"error":     resp.Error,
-			"info":      resp.Info,
-		},
-	}
-	msgBytes, _ := json.Marshal(msg)
+	msgBytes := encodeMessage("code_res", CodeResultData{
+		StdOut:   resp.Stdout,
+		StdErr:   resp.Stderr,
+		ExitCode: resp.ExitCode,
+		Error:    resp.Error,
+		Info:     resp.Info,
+	})
 
-	c.Send <- msgBytes
+	c.send(msgBytes)
 	c.Hub.broadcastToOthers(c, msgBytes)
-
+	c.Hub.recordEvent(resources.TimelineRun, c.Username, resources.RunEventData{
+		Language: req.Language,
+		Version:  version,
+		Result:   resp,
+	})
+	c.acknowledge(requestID, c.Hub.currentVersion())
 }
 
 func (c *Client) sendCurrentState() {
 	c.Hub.interviewMu.Lock()
 	currentCode, patches, version, err := c.Hub.Interview.GetCurrentCode()
+	lang := c.Hub.Interview.Language
 	c.Hub.interviewMu.Unlock()
 
-	var users []map[string]string
-	for username, _ := range c.Hub.Clients {
-		users = append(users, map[string]string{
-			"username": username,
-		})
-	}
-
-	initialData := Message{
-		Type: "session_init",
-		Data: map[string]interface{}{
-			"session_id":   c.Hub.SessionID,
-			"current_code": currentCode,
-			"lang":         c.Hub.Interview.Language,
-			"version":      version,
-			"patches":      patches,
-			"users":        users,
-			"username":     c.Username,
-		},
-	}
-
 	if err != nil {
-		initialData.Data = map[string]interface{}{
-			"session_id": c.Hub.SessionID,
-			"error":      "Failed to load current code state",
-		}
+		log.Printf("Error loading code state for session %s: %v", c.Hub.SessionID, err)
+		c.sendError("", errSessionState, "Failed to load current code state")
+		return
 	}
 
-	if jsonData, err := json.Marshal(initialData); err == nil {
-		select {
-		case c.Send <- jsonData:
-		case <-time.After(5 * time.Second):
-			log.Printf("Timeout sending initial data to client %s", c.Username)
-		}
+	patchData := make([]CodePatchData, 0, len(patches))
+	for _, patch := range patches {
+		patchData = append(patchData, newCodePatchData(patch))
 	}
-}
 
-func (c *Client) processCodePatch(msg Message) error {
-	dataBytes, err := json.Marshal(msg.Data)
-	if err != nil {
-		return fmt.Errorf("failed to marshal patch data: %w", err)
+	var timer *TimerData
+	if data, ok := c.Hub.timerData(); ok {
+		timer = &data
 	}
 
-	var patch resources.CodePatch
-	if err := json.Unmarshal(dataBytes, &patch); err != nil {
-		return fmt.Errorf("failed to unmarshal patch: %w", err)
+	problem, err := c.Hub.Interview.Problem()
+	if err != nil {
+		log.Printf("Error loading problem for session %s: %v", c.Hub.SessionID, err)
 	}
 
-	if patch.Operation != "add" && patch.Operation != "remove" && patch.Operation != "replace" {
-		return fmt.Errorf("invalid operation: %s", patch.Operation)
+	statement := c.Hub.statementData()
+	if statement != nil {
+		statement.Editable = c.isInterviewer()
 	}
 
-	c.Hub.interviewMu.Lock()
-	err = c.Hub.Interview.AddCodePatch(patch)
-	c.Hub.interviewMu.Unlock()
+	notes, scorecard, integrity := c.privateData()
+
+	initialData := encodeMessage("session_init", SessionInitData{
+		ProtocolVersion: c.ProtocolVersion,
+		SessionID:       c.Hub.SessionID,
+		CurrentCode:     currentCode,
+		Lang:            lang,
+		Version:         version,
+		Patches:         patchData,
+		Users:           c.Hub.participants(c),
+		Username:        c.Username,
+		Role:            c.Role,
+		State:           newSessionStateData(c.Hub.lifecycle()),
+		Timer:           timer,
+		Problem:         newProblemData(problem),
+		Question:        c.Hub.questionData(lang),
+		Statement:       statement,
+		Chat:            c.chatHistory(),
+		Comments:        c.Hub.commentThreads(),
+		Drive:           c.Hub.driveLock(),
+		Muted:           c.Hub.mutedUsers(),
+		Notes:           notes,
+		Scorecard:       scorecard,
+		Integrity:       integrity,
+	})
 
-	if err != nil {
-		if strings.Contains(err.Error(), "version mismatch") {
-			c.sendCurrentState()
-			return nil
+	if initialData != nil {
+		select {
+		case c.Send <- newOutFrame(initialData):
+		case <-time.After(5 * time.Second):
+			log.Printf("Timeout sending initial data to client %s", c.Username)
 		}
-		return fmt.Errorf("failed to add code patch: %w", err)
-	}
-
-	broadcastMsg := Message{
-		Type: "code_patch",
-		Data: map[string]interface{}{
-			"username":  c.Username,
-			"version":   patch.Version,
-			"op":        patch.Operation,
-			"start_pos": patch.StartPos,
-			"end_pos":   patch.EndPos,
-			"content":   patch.Content,
-		},
 	}
-
-	msgBytes, _ := json.Marshal(broadcastMsg)
-	c.Hub.broadcastToOthers(c, msgBytes)
-	return nil
 }
 
-func (c *Client) processCursorSelect(msg Message) {
-	startVal, ok := msg.Data["start_pos"]
-	if !ok {
-		log.Printf("Missing start_pos data in cursor_position message from %s", c.Username)
-		return
+// processCodePatch queues the patch for the hub's next flush, which commits
+// it and answers with an ack or nack.
+func (c *Client) processCodePatch(requestID string, req CodePatchData) error {
+	if req.Op != "add" && req.Op != "remove" && req.Op != "replace" {
+		return fmt.Errorf("invalid operation: %s", req.Op)
 	}
-	startFloat, ok := startVal.(float64)
-	if !ok {
-		log.Printf("Invalid start_pos type in cursor_position message from %s", c.Username)
-		return
+	if err := c.checkDriver(); err != nil {
+		return err
 	}
-	startPos := int(startFloat)
+	return c.Hub.queuePatch(pendingPatch{
+		client:    c,
+		requestID: requestID,
+		data:      req,
+		paste:     !c.hasInterviewerRole() && isPaste(req),
+	})
+}
 
-	endVal, ok := msg.Data["end_pos"]
-	if !ok {
-		log.Printf("Missing end_pos data in cursor_position message from %s", c.Username)
-		return
-	}
-	endFloat, ok := endVal.(float64)
-	if !ok {
-		log.Printf("Invalid end_pos type in cursor_position message from %s", c.Username)
-		return
-	}
-	endPos := int(endFloat)
-	broadcastMsg := Message{
-		Type: "cursor_select",
-		Data: map[string]interface{}{
-			"username":  c.Username,
-			"start_pos": startPos,
-			"end_pos":   endPos,
-		},
+func (c *Client) processCursorSelect(req CursorSelectData) {
+	event := CursorSelectEvent{
+		Username: c.Username,
+		StartPos: req.StartPos,
+		EndPos:   req.EndPos,
+		Viewport: req.Viewport,
 	}
-	msgBytes, _ := json.Marshal(broadcastMsg)
-	c.Hub.broadcastToOthers(c, msgBytes)
+	c.moveCursor(req.StartPos, req.EndPos)
+	c.Hub.queueCursor(event)
+	c.Hub.queueFollow(event)
 }
-func (c *Client) processEditLang(msg Message) {
-	newLang, ok := msg.Data["lang"].(string)
-	if !ok {
-		log.Printf("Missing lang data in edit_lang message from %s", c.Username)
-		errorMsg := Message{
-			Type: "error",
-			Data: map[string]interface{}{
-				"message": "Missing lang data",
-				"type":    "edit_lang_error",
-			},
-		}
-		if jsonData, err := json.Marshal(errorMsg); err == nil {
-			select {
-			case c.Send <- jsonData:
-			default:
-			}
-		}
-		return
+
+func (c *Client) processEditLang(req EditLangData) error {
+	if req.Lang == "" {
+		return fmt.Errorf("missing lang data")
 	}
 
-	err := c.Hub.Interview.EditLanguage(newLang)
+	c.Hub.interviewMu.Lock()
+	err := c.Hub.Interview.EditLanguage(req.Lang)
+	c.Hub.interviewMu.Unlock()
 	if err != nil {
-		log.Printf("Error editing language to %s: %v", newLang, err)
-		errorMsg := Message{
-			Type: "error",
-			Data: map[string]interface{}{
-				"message": err.Error(),
-				"type":    "edit_lang_error",
-			},
-		}
-		if jsonData, err := json.Marshal(errorMsg); err == nil {
-			select {
-			case c.Send <- jsonData:
-			default:
-			}
-		}
-		return
+		return err
 	}
 
-	broadcastMsg := Message{
-		Type: "edit_lang",
-		Data: map[string]interface{}{
-			"lang": newLang,
-		},
-	}
-	msgBytes, _ := json.Marshal(broadcastMsg)
-	c.Hub.broadcastToOthers(c, msgBytes)
+	c.Hub.broadcastToOthers(c, encodeMessage("edit_lang", EditLangEvent{
+		Username: c.Username,
+		Lang:     req.Lang,
+	}))
+	c.Hub.recordEvent(resources.TimelineLanguage, c.Username, resources.LanguageEventData{Language: req.Lang})
+	return nil
 }
 
 func LiveStreamCoding(c *gin.Context) {
@@ -560,8 +932,9 @@ func LiveStreamCoding(c *gin.Context) {
 		return
 	}
 
-	if hub, exists := Sessions[sessionID]; exists && len(hub.Clients) >= 300 {
-		c.JSON(400, gin.H{"error": "Too many users"})
+	protocolVersion, err := negotiateProtocolVersion(c.Query("protocol_version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -571,6 +944,32 @@ func LiveStreamCoding(c *gin.Context) {
 		return
 	}
 
+	if resources.CountPresence(cache, sessionID) >= 300 {
+		c.JSON(400, gin.H{"error": "Too many users"})
+		return
+	}
+
+	// Bans and mutes go by address, which an interviewer may share with the
+	// candidate they removed, so they do not apply to the interviewer key.
+	role := resolveRole(&resources.Interview{SessionID: sessionID, Cache: cache}, c.Query("key"))
+	identity := resources.ParticipantIdentity{IP: c.ClientIP(), ID: c.Query("participant_id")}
+	var muted bool
+	if role != RoleInterviewer {
+		banned, err := resources.IsBanned(cache, sessionID, identity)
+		if err == nil && !banned {
+			muted, err = resources.IsMuted(cache, sessionID, identity)
+		}
+		if err != nil {
+			log.Printf("Failed to check bans of session %s: %v", sessionID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check bans"})
+			return
+		}
+		if banned {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You were banned from this session"})
+			return
+		}
+	}
+
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade error: %v", err)
@@ -591,22 +990,42 @@ func LiveStreamCoding(c *gin.Context) {
 		_ = conn.Close()
 		return
 	}
-	hub.interviewMu.Lock()
-	username := fmt.Sprintf("User%d", rand.Intn(360)+1)
+	var username string
 	for {
-		if _, exists := hub.Clients[username]; !exists {
+		username = fmt.Sprintf("User%d", rand.Intn(360)+1)
+		claimed, err := resources.ClaimPresence(cache, sessionID, username)
+		if err != nil {
+			log.Printf("Failed to claim presence in session %s: %v", sessionID, err)
+			_ = conn.Close()
+			return
+		}
+		if claimed {
 			break
 		}
-		username = fmt.Sprintf("User%d", rand.Intn(360)+1)
+	}
+	if role == RoleInterviewer {
+		err = resources.ForgetParticipant(cache, sessionID, username)
+	} else {
+		err = resources.RememberParticipant(cache, sessionID, username, identity)
+	}
+	if err != nil {
+		log.Printf("Failed to remember participant of session %s: %v", sessionID, err)
 	}
 	client := &Client{
-		Username: username,
-		Conn:     conn,
-		Hub:      hub,
-		Send:     make(chan []byte, sendBufferSize),
+		Username:        username,
+		Role:            role,
+		Identity:        identity,
+		ProtocolVersion: protocolVersion,
+		Codec:           codecForSubprotocol(conn.Subprotocol()),
+		Conn:            conn,
+		Hub:             hub,
+		Send:            make(chan *outFrame, sendBufferSize),
+		pending:         newPendingRequests(),
+		closed:          make(chan struct{}),
 	}
-	hub.interviewMu.Unlock()
+	client.muted.Store(muted)
 
+	client.joinPresence()
 	hub.register <- client
 	client.sendCurrentState()
 
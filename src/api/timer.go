@@ -0,0 +1,119 @@
+package api
+
+import (
+	"CodeStream/src/resources"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const timerTickPeriod = time.Second
+
+// hubTimer caches the session's timer. While it runs, each instance ticks to
+// its own clients; changes are broadcast to all of them.
+type hubTimer struct {
+	state *resources.SessionTimer
+	stop  chan struct{}
+}
+
+// timerData returns the timer as clients see it, and false if it was never
+// started.
+func (h *Hub) timerData() (TimerData, bool) {
+	h.interviewMu.Lock()
+	defer h.interviewMu.Unlock()
+	if h.timer.state == nil {
+		return TimerData{}, false
+	}
+	return newTimerData(*h.timer.state, time.Now()), true
+}
+
+func (h *Hub) editorLocked() bool {
+	data, ok := h.timerData()
+	return ok && data.Locked
+}
+
+// applyTimer stores the session's timer and ticks while it runs.
+func (h *Hub) applyTimer(timer resources.SessionTimer) {
+	h.interviewMu.Lock()
+	defer h.interviewMu.Unlock()
+
+	h.timer.state = &timer
+	if h.timer.stop != nil {
+		close(h.timer.stop)
+		h.timer.stop = nil
+	}
+	if !timer.StatusAt(time.Now()).Running {
+		return
+	}
+	stop := make(chan struct{})
+	h.timer.stop = stop
+	go h.tickTimer(stop)
+}
+
+func (h *Hub) stopTimer() {
+	h.interviewMu.Lock()
+	defer h.interviewMu.Unlock()
+	if h.timer.stop != nil {
+		close(h.timer.stop)
+		h.timer.stop = nil
+	}
+}
+
+// loadTimer picks up the timer of a session that already has one.
+func (h *Hub) loadTimer() error {
+	timer, err := h.Interview.Timer()
+	if errors.Is(err, resources.ErrTimerNotStarted) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	h.applyTimer(timer)
+	return nil
+}
+
+func (h *Hub) tickTimer(stop chan struct{}) {
+	ticker := time.NewTicker(timerTickPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			data, ok := h.timerData()
+			if !ok {
+				return
+			}
+			h.deliverLocal("", encodeMessage("timer", data))
+			if !data.Running {
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) processTimerControl(requestID string, req TimerControlData) error {
+	timer, err := c.Hub.Interview.UpdateTimer(c.Username, func(timer *resources.SessionTimer, now time.Time) error {
+		switch req.Action {
+		case "start":
+			return timer.Start(req.Phases, now)
+		case "pause":
+			return timer.Pause(now)
+		case "resume":
+			return timer.Resume(now)
+		case "add_time":
+			return timer.AddTime(time.Duration(req.DurationMs)*time.Millisecond, now)
+		default:
+			return fmt.Errorf("unknown timer action: %s", req.Action)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	c.Hub.applyTimer(timer)
+	c.Hub.broadcastAll(encodeMessage("timer", newTimerData(timer, time.Now())))
+	c.acknowledge(requestID, c.Hub.currentVersion())
+	return nil
+}
@@ -0,0 +1,23 @@
+package api
+
+// processUndo commits the inverse of the client's latest change, or of their
+// latest undo when redo is set. Clients skip their own patches in batches, so
+// the client is resynced once the inverse is committed.
+func (c *Client) processUndo(requestID string, redo bool) error {
+	if err := c.checkDriver(); err != nil {
+		return err
+	}
+	patch, err := c.Hub.Interview.UndoPatch(c.Username, redo)
+	if err != nil {
+		return err
+	}
+
+	return c.Hub.queuePatch(pendingPatch{
+		client:    c,
+		requestID: requestID,
+		data:      newCodePatchData(patch),
+		source:    patch.Source,
+		reverts:   patch.Reverts,
+		resync:    true,
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -12,27 +13,71 @@ import (
 var Config envData
 
 type envData struct {
-	RedisUrl         string   `env:"REDIS_URL"`
-	ApplicationMode  string   `env:"APPLICATION_MODE"`
-	Languages        []string `env:"LANGUAGES"`
-	CodeWorkDir      string   `env:"CODE_WORK_DIR"`
-	RunTimeoutSecond int      `env:"RUN_TIMEOUT_SECOND"`
-	GoogleCaptchaKey string   `env:"GOOGLE_CAPTCHA_KEY"`
+	RedisUrl            string        `env:"REDIS_URL"`
+	ApplicationMode     string        `env:"APPLICATION_MODE"`
+	Languages           []string      `env:"LANGUAGES"`
+	CodeWorkDir         string        `env:"CODE_WORK_DIR"`
+	RunTimeoutSecond    int           `env:"RUN_TIMEOUT_SECOND"`
+	JudgeTimeoutSecond  int           `env:"JUDGE_TIMEOUT_SECOND"`
+	GoogleCaptchaKey    string        `env:"GOOGLE_CAPTCHA_KEY"`
+	AdminToken          string        `env:"ADMIN_TOKEN"`
+	RolesEnabled        bool          `env:"ROLES_ENABLED"`
+	PatchBatchWindow    time.Duration `env:"PATCH_BATCH_WINDOW_MS"`
+	SessionDuration     time.Duration `env:"SESSION_DURATION_MINUTES"`
+	DatabaseDriver      string        `env:"DATABASE_DRIVER"`
+	DatabaseURL         string        `env:"DATABASE_URL"`
+	ScorecardRubric     []string      `env:"SCORECARD_RUBRIC"`
+	PasteThreshold      int           `env:"PASTE_THRESHOLD_CHARS"`
+	SimilarityCorpusDir string        `env:"SIMILARITY_CORPUS_DIR"`
 }
 
+var defaultScorecardRubric = []string{"Problem solving", "Code quality", "Communication", "Testing"}
+
 func (envData) SetupEnv() {
 	err := godotenv.Load()
 	if err != nil {
 		log.Fatal("Error loading .env file")
 	}
 	runTimeoutSecond, _ := strconv.Atoi(os.Getenv("RUN_TIMEOUT_SECOND"))
+	judgeTimeoutSecond, err := strconv.Atoi(os.Getenv("JUDGE_TIMEOUT_SECOND"))
+	if err != nil {
+		judgeTimeoutSecond = 60
+	}
+	patchBatchWindowMs, err := strconv.Atoi(os.Getenv("PATCH_BATCH_WINDOW_MS"))
+	if err != nil {
+		patchBatchWindowMs = 25
+	}
+	sessionDurationMinutes, _ := strconv.Atoi(os.Getenv("SESSION_DURATION_MINUTES"))
+	pasteThreshold, err := strconv.Atoi(os.Getenv("PASTE_THRESHOLD_CHARS"))
+	if err != nil {
+		pasteThreshold = 100
+	}
+	scorecardRubric := make([]string, 0)
+	for _, criterion := range strings.Split(os.Getenv("SCORECARD_RUBRIC"), ",") {
+		if criterion = strings.TrimSpace(criterion); criterion != "" {
+			scorecardRubric = append(scorecardRubric, criterion)
+		}
+	}
+	if len(scorecardRubric) == 0 {
+		scorecardRubric = defaultScorecardRubric
+	}
 
 	Config = envData{
-		RedisUrl:         os.Getenv("REDIS_URL"),
-		ApplicationMode:  os.Getenv("APPLICATION_MODE"),
-		Languages:        strings.Split(os.Getenv("LANGUAGES"), ","),
-		CodeWorkDir:      os.Getenv("CODE_WORK_DIR"),
-		RunTimeoutSecond: runTimeoutSecond,
-		GoogleCaptchaKey: os.Getenv("GOOGLE_CAPTCHA_KEY"),
+		RedisUrl:            os.Getenv("REDIS_URL"),
+		ApplicationMode:     os.Getenv("APPLICATION_MODE"),
+		Languages:           strings.Split(os.Getenv("LANGUAGES"), ","),
+		CodeWorkDir:         os.Getenv("CODE_WORK_DIR"),
+		RunTimeoutSecond:    runTimeoutSecond,
+		JudgeTimeoutSecond:  judgeTimeoutSecond,
+		GoogleCaptchaKey:    os.Getenv("GOOGLE_CAPTCHA_KEY"),
+		AdminToken:          os.Getenv("ADMIN_TOKEN"),
+		RolesEnabled:        os.Getenv("ROLES_ENABLED") == "true",
+		PatchBatchWindow:    time.Duration(patchBatchWindowMs) * time.Millisecond,
+		SessionDuration:     time.Duration(sessionDurationMinutes) * time.Minute,
+		DatabaseDriver:      os.Getenv("DATABASE_DRIVER"),
+		DatabaseURL:         os.Getenv("DATABASE_URL"),
+		ScorecardRubric:     scorecardRubric,
+		PasteThreshold:      pasteThreshold,
+		SimilarityCorpusDir: os.Getenv("SIMILARITY_CORPUS_DIR"),
 	}
 }
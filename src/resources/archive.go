@@ -0,0 +1,445 @@
+package resources
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Reasons an interview was archived.
+const (
+	ArchiveEnded   = "ended"
+	ArchiveExpired = "expired"
+)
+
+const (
+	sessionExpiryKey    = "sessions:expiry"
+	archiveSweepPeriod  = time.Minute
+	archiveExpiryMargin = 10 * time.Minute
+	archiveLockTTL      = 5 * time.Minute
+	sessionTTL          = time.Hour * 24
+	defaultArchivePage  = 50
+	maxArchivePage      = 200
+)
+
+var ErrArchiveNotFound = errors.New("archived interview not found")
+
+// ArchivedInterview is everything kept about a session once its Redis keys
+// are gone.
+type ArchivedInterview struct {
+	SessionID    string             `json:"session_id"`
+	Language     string             `json:"lang"`
+	Code         string             `json:"code"`
+	Version      int64              `json:"version"`
+	Participants []string           `json:"participants"`
+	Questions    []ArchivedQuestion `json:"questions"`
+	Notes        string             `json:"notes"`
+	Scorecard    Scorecard          `json:"scorecard"`
+	Integrity    []IntegritySummary `json:"integrity"`
+	Comments     []CommentThread    `json:"comments"`
+	Runs         []TimelineEvent    `json:"runs"`
+	Timeline     []TimelineEvent    `json:"timeline"`
+	Reason       string             `json:"reason"`
+	CreatedAt    time.Time          `json:"created_at"`
+	EndedAt      time.Time          `json:"ended_at"`
+}
+
+// ArchivedQuestion is the final code of one question of the interview.
+type ArchivedQuestion struct {
+	ProblemID string `json:"problem_id"`
+	Title     string `json:"title"`
+	Language  string `json:"lang"`
+	Code      string `json:"code"`
+	Version   int64  `json:"version"`
+}
+
+// ArchivedSolution is the final code an archived interview has for a problem.
+type ArchivedSolution struct {
+	SessionID string
+	EndedAt   time.Time
+	ArchivedQuestion
+}
+
+type ArchiveSummary struct {
+	SessionID    string    `json:"session_id"`
+	Language     string    `json:"lang"`
+	Participants []string  `json:"participants"`
+	Reason       string    `json:"reason"`
+	CreatedAt    time.Time `json:"created_at"`
+	EndedAt      time.Time `json:"ended_at"`
+}
+
+// ArchiveRepository stores finished interviews. Saving an interview that is
+// already archived replaces it, so a session that is resumed after it ended
+// is archived again with its final state.
+type ArchiveRepository interface {
+	Save(ctx context.Context, interview ArchivedInterview) error
+	Get(ctx context.Context, sessionID string) (ArchivedInterview, error)
+	List(ctx context.Context, limit int, offset int) ([]ArchiveSummary, error)
+	ListSolutions(ctx context.Context, problemID string, limit int) ([]ArchivedSolution, error)
+}
+
+// Archive is nil when no database is configured.
+var Archive ArchiveRepository
+
+func SetupArchive() {
+	if DB == nil {
+		return
+	}
+	Archive = &sqlArchiveRepository{db: DB}
+	go sweepExpiringSessions()
+}
+
+type sqlArchiveRepository struct {
+	db *Database
+}
+
+func (r *sqlArchiveRepository) Save(ctx context.Context, interview ArchivedInterview) error {
+	participants, err := json.Marshal(interview.Participants)
+	if err != nil {
+		return err
+	}
+	runs, err := json.Marshal(interview.Runs)
+	if err != nil {
+		return err
+	}
+	timeline, err := json.Marshal(interview.Timeline)
+	if err != nil {
+		return err
+	}
+	questions, err := json.Marshal(interview.Questions)
+	if err != nil {
+		return err
+	}
+	scorecard, err := json.Marshal(interview.Scorecard)
+	if err != nil {
+		return err
+	}
+	integrity, err := json.Marshal(interview.Integrity)
+	if err != nil {
+		return err
+	}
+	comments, err := json.Marshal(interview.Comments)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, r.db.Rebind(`
+		INSERT INTO archived_interviews
+			(session_id, language, code, version, participants, questions, notes, scorecard, integrity, comments, runs,
+			timeline, reason, created_at, ended_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (session_id) DO UPDATE SET
+			language = excluded.language,
+			code = excluded.code,
+			version = excluded.version,
+			participants = excluded.participants,
+			questions = excluded.questions,
+			notes = excluded.notes,
+			scorecard = excluded.scorecard,
+			integrity = excluded.integrity,
+			comments = excluded.comments,
+			runs = excluded.runs,
+			timeline = excluded.timeline,
+			reason = excluded.reason,
+			ended_at = excluded.ended_at`),
+		interview.SessionID, interview.Language, interview.Code, interview.Version,
+		string(participants), string(questions), interview.Notes, string(scorecard), string(integrity), string(comments),
+		string(runs), string(timeline), interview.Reason,
+		interview.CreatedAt.UnixMilli(), interview.EndedAt.UnixMilli(),
+	)
+	return err
+}
+
+func (r *sqlArchiveRepository) Get(ctx context.Context, sessionID string) (ArchivedInterview, error) {
+	var interview ArchivedInterview
+	var participants, questions, scorecard, integrity, comments, runs, timeline string
+	var createdAt, endedAt int64
+
+	err := r.db.QueryRowContext(ctx, r.db.Rebind(`
+		SELECT session_id, language, code, version, participants, questions, notes, scorecard, integrity, comments, runs,
+			timeline, reason, created_at, ended_at
+		FROM archived_interviews WHERE session_id = ?`), sessionID,
+	).Scan(&interview.SessionID, &interview.Language, &interview.Code, &interview.Version, &participants, &questions,
+		&interview.Notes, &scorecard, &integrity, &comments, &runs, &timeline, &interview.Reason, &createdAt, &endedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ArchivedInterview{}, ErrArchiveNotFound
+	}
+	if err != nil {
+		return ArchivedInterview{}, err
+	}
+
+	if err := json.Unmarshal([]byte(participants), &interview.Participants); err != nil {
+		return ArchivedInterview{}, err
+	}
+	if err := json.Unmarshal([]byte(questions), &interview.Questions); err != nil {
+		return ArchivedInterview{}, err
+	}
+	if err := json.Unmarshal([]byte(scorecard), &interview.Scorecard); err != nil {
+		return ArchivedInterview{}, err
+	}
+	if err := json.Unmarshal([]byte(integrity), &interview.Integrity); err != nil {
+		return ArchivedInterview{}, err
+	}
+	if err := json.Unmarshal([]byte(comments), &interview.Comments); err != nil {
+		return ArchivedInterview{}, err
+	}
+	if err := json.Unmarshal([]byte(runs), &interview.Runs); err != nil {
+		return ArchivedInterview{}, err
+	}
+	if err := json.Unmarshal([]byte(timeline), &interview.Timeline); err != nil {
+		return ArchivedInterview{}, err
+	}
+	interview.CreatedAt = time.UnixMilli(createdAt)
+	interview.EndedAt = time.UnixMilli(endedAt)
+	return interview, nil
+}
+
+// List returns archived interviews, most recently ended first.
+func (r *sqlArchiveRepository) List(ctx context.Context, limit int, offset int) ([]ArchiveSummary, error) {
+	rows, err := r.db.QueryContext(ctx, r.db.Rebind(`
+		SELECT session_id, language, participants, reason, created_at, ended_at
+		FROM archived_interviews ORDER BY ended_at DESC LIMIT ? OFFSET ?`), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := make([]ArchiveSummary, 0)
+	for rows.Next() {
+		var summary ArchiveSummary
+		var participants string
+		var createdAt, endedAt int64
+		if err := rows.Scan(&summary.SessionID, &summary.Language, &participants, &summary.Reason, &createdAt, &endedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(participants), &summary.Participants); err != nil {
+			return nil, err
+		}
+		summary.CreatedAt = time.UnixMilli(createdAt)
+		summary.EndedAt = time.UnixMilli(endedAt)
+		summaries = append(summaries, summary)
+	}
+	return summaries, rows.Err()
+}
+
+// ListSolutions returns the code archived interviews have for the problem,
+// most recently ended first, from at most limit interviews.
+func (r *sqlArchiveRepository) ListSolutions(ctx context.Context, problemID string, limit int) ([]ArchivedSolution, error) {
+	quoted, err := json.Marshal(problemID)
+	if err != nil {
+		return nil, err
+	}
+	pattern := `%"problem_id":` + likeEscaper.Replace(string(quoted)) + `%`
+	rows, err := r.db.QueryContext(ctx, r.db.Rebind(`
+		SELECT session_id, questions, ended_at
+		FROM archived_interviews WHERE questions LIKE ? ESCAPE '\' ORDER BY ended_at DESC LIMIT ?`), pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	solutions := make([]ArchivedSolution, 0)
+	for rows.Next() {
+		var sessionID, questions string
+		var endedAt int64
+		if err := rows.Scan(&sessionID, &questions, &endedAt); err != nil {
+			return nil, err
+		}
+		var archived []ArchivedQuestion
+		if err := json.Unmarshal([]byte(questions), &archived); err != nil {
+			return nil, err
+		}
+		for _, question := range archived {
+			if question.ProblemID == problemID {
+				solutions = append(solutions, ArchivedSolution{sessionID, time.UnixMilli(endedAt), question})
+			}
+		}
+	}
+	return solutions, rows.Err()
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ArchivePage clamps the limit and offset query parameters of a listing.
+func ArchivePage(limitParam string, offsetParam string) (int, int) {
+	limit, err := strconv.Atoi(limitParam)
+	if err != nil || limit <= 0 {
+		limit = defaultArchivePage
+	}
+	offset, _ := strconv.Atoi(offsetParam)
+	return min(limit, maxArchivePage), max(offset, 0)
+}
+
+// trackSessionExpiry queues the session for archiving shortly before its keys
+// expire.
+func trackSessionExpiry(c *Cache, pipe redis.Pipeliner, sessionID string) {
+	pipe.ZAdd(c.Ctx, sessionExpiryKey, redis.Z{
+		Score:  float64(time.Now().Add(sessionTTL).Unix()),
+		Member: sessionID,
+	})
+}
+
+// ArchiveSession saves the current state of the session to the archive and
+// marks it archived. It is a no-op when no database is configured.
+func ArchiveSession(c *Cache, sessionID string, reason string) error {
+	if Archive == nil {
+		return nil
+	}
+
+	interview, err := GetInterviewSession(c, sessionID)
+	if err != nil {
+		return err
+	}
+	archived, err := interview.Snapshot(reason)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(c.Ctx, 10*time.Second)
+	defer cancel()
+	if err := Archive.Save(ctx, archived); err != nil {
+		return err
+	}
+
+	_, err = interview.TransitionLifecycle(SessionArchived, "")
+	if err != nil && !errors.Is(err, ErrInvalidTransition) {
+		return err
+	}
+	return nil
+}
+
+// Snapshot returns the session as it would be archived now.
+func (interview *Interview) Snapshot(reason string) (ArchivedInterview, error) {
+	c := interview.Cache
+	sessionID := interview.SessionID
+	code, err := interview.CodeAt(interview.Version)
+	if err != nil {
+		return ArchivedInterview{}, err
+	}
+	timeline, err := GetTimeline(c, sessionID)
+	if err != nil {
+		return ArchivedInterview{}, err
+	}
+	questions, err := interview.FinalQuestions()
+	if err != nil {
+		return ArchivedInterview{}, err
+	}
+	notes, err := interview.NotesText()
+	if err != nil {
+		return ArchivedInterview{}, err
+	}
+	scorecard, err := interview.Scorecard()
+	if err != nil {
+		return ArchivedInterview{}, err
+	}
+	comments, err := interview.CommentThreads()
+	if err != nil {
+		return ArchivedInterview{}, err
+	}
+
+	archived := ArchivedInterview{
+		SessionID:    sessionID,
+		Language:     interview.Language,
+		Code:         code,
+		Version:      interview.Version,
+		Participants: make([]string, 0),
+		Questions:    make([]ArchivedQuestion, 0, len(questions)),
+		Notes:        notes,
+		Scorecard:    scorecard,
+		Integrity:    SummarizeIntegrity(timeline, time.Now().UnixMilli()),
+		Comments:     comments,
+		Runs:         make([]TimelineEvent, 0),
+		Timeline:     timeline,
+		Reason:       reason,
+		CreatedAt:    time.Now(),
+		EndedAt:      time.Now(),
+	}
+	if len(timeline) > 0 {
+		archived.CreatedAt = time.UnixMilli(timeline[0].At)
+	}
+
+	for _, question := range questions {
+		archived.Questions = append(archived.Questions, ArchivedQuestion{
+			ProblemID: question.Problem.ID,
+			Title:     question.Problem.Title,
+			Language:  question.Language,
+			Code:      question.Code,
+			Version:   question.Version,
+		})
+	}
+
+	seen := make(map[string]bool)
+	for _, event := range timeline {
+		switch event.Type {
+		case TimelineJoin:
+			if !seen[event.Author] {
+				seen[event.Author] = true
+				archived.Participants = append(archived.Participants, event.Author)
+			}
+		case TimelineRun:
+			archived.Runs = append(archived.Runs, event)
+		}
+	}
+	return archived, nil
+}
+
+// sessionArchived reports whether the session ended and was archived already,
+// in which case it can no longer have changed.
+func sessionArchived(c *Cache, sessionID string) (bool, error) {
+	interview := Interview{SessionID: sessionID, Cache: c}
+	lifecycle, err := interview.Lifecycle()
+	if err != nil {
+		return false, err
+	}
+	return lifecycle.State == SessionArchived, nil
+}
+
+// sweepExpiringSessions archives sessions whose keys are about to expire. A
+// lock per session keeps replicas from archiving the same one twice.
+func sweepExpiringSessions() {
+	c := NewCacheContext()
+	ticker := time.NewTicker(archiveSweepPeriod)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		sessionIDs, err := c.Client.ZRangeByScore(c.Ctx, sessionExpiryKey, &redis.ZRangeBy{
+			Min: "-inf",
+			Max: strconv.FormatInt(time.Now().Add(archiveExpiryMargin).Unix(), 10),
+		}).Result()
+		if err != nil {
+			log.Printf("Failed to list expiring sessions: %v", err)
+			continue
+		}
+
+		for _, sessionID := range sessionIDs {
+			lockKey := fmt.Sprintf("session:%s:archive_lock", sessionID)
+			locked, err := c.Client.SetNX(c.Ctx, lockKey, InstanceID, archiveLockTTL).Result()
+			if err != nil || !locked {
+				continue
+			}
+			if !c.Exists(fmt.Sprintf("session:%s:state", sessionID)) {
+				c.Client.ZRem(c.Ctx, sessionExpiryKey, sessionID)
+				continue
+			}
+			if archived, err := sessionArchived(c, sessionID); err == nil && archived {
+				c.Client.ZRem(c.Ctx, sessionExpiryKey, sessionID)
+				continue
+			}
+			if err := ArchiveSession(c, sessionID, ArchiveExpired); err != nil {
+				log.Printf("Failed to archive session %s: %v", sessionID, err)
+				continue
+			}
+			c.Client.ZRem(c.Ctx, sessionExpiryKey, sessionID)
+			log.Printf("Archived expiring session %s", sessionID)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// MaxChatLength is the longest chat message, in characters.
+	MaxChatLength = 2000
+	// maxChatHistory is how many chat messages a session keeps; older ones
+	// are dropped.
+	maxChatHistory = 200
+)
+
+var ErrInvalidChatMessage = errors.New("invalid chat message")
+
+// ChatMessage is a message typed in the session's chat. Private messages are
+// only shown to interviewers.
+type ChatMessage struct {
+	ID      string `json:"id"`
+	Author  string `json:"author"`
+	Text    string `json:"text"`
+	Private bool   `json:"private,omitempty"`
+	At      int64  `json:"at"`
+}
+
+func chatKey(sessionID string) string {
+	return fmt.Sprintf("session:%s:chat", sessionID)
+}
+
+// AddChatMessage appends a message by author to the chat, keeping the latest
+// maxChatHistory.
+func (interview *Interview) AddChatMessage(author string, text string, private bool) (ChatMessage, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ChatMessage{}, fmt.Errorf("%w: empty text", ErrInvalidChatMessage)
+	}
+	if utf8.RuneCountInString(text) > MaxChatLength {
+		return ChatMessage{}, fmt.Errorf("%w: longer than %d characters", ErrInvalidChatMessage, MaxChatLength)
+	}
+
+	message := ChatMessage{
+		ID:      generateSessionID(12),
+		Author:  author,
+		Text:    text,
+		Private: private,
+		At:      time.Now().UnixMilli(),
+	}
+	messageJSON, err := json.Marshal(message)
+	if err != nil {
+		return ChatMessage{}, err
+	}
+
+	c := interview.Cache
+	key := chatKey(interview.SessionID)
+	_, err = c.Client.TxPipelined(c.Ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(c.Ctx, key, messageJSON)
+		pipe.LTrim(c.Ctx, key, -maxChatHistory, -1)
+		pipe.ExpireNX(c.Ctx, key, sessionTTL)
+		return nil
+	})
+	if err != nil {
+		return ChatMessage{}, err
+	}
+	return message, nil
+}
+
+// ChatHistory returns the chat, oldest first, without the private messages
+// unless includePrivate is set.
+func (interview *Interview) ChatHistory(includePrivate bool) ([]ChatMessage, error) {
+	c := interview.Cache
+	messageStrings, err := c.Client.LRange(c.Ctx, chatKey(interview.SessionID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]ChatMessage, 0, len(messageStrings))
+	for _, messageStr := range messageStrings {
+		var message ChatMessage
+		if err := json.Unmarshal([]byte(messageStr), &message); err != nil {
+			return nil, err
+		}
+		if message.Private && !includePrivate {
+			continue
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
@@ -5,11 +5,19 @@ import (
 	"errors"
 	"fmt"
 	"slices"
+	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// ErrVersionMismatch is returned for a patch that was written
+// against a stale version of the code.
+var ErrVersionMismatch = errors.New("version mismatch")
+
+// ErrVersionNotFound is returned by CodeAt for versions the session never had.
+var ErrVersionNotFound = errors.New("version not found")
+
 var validOperations = map[string]bool{
 	"add":     true,
 	"remove":  true,
@@ -26,8 +34,15 @@ func (interview *Interview) CanRun() bool {
 	return true
 }
 
-func (interview *Interview) AddCodePatch(patch CodePatch) error {
-	c := interview.Cache
+// PatchResult is the outcome of one patch passed to AddCodePatches.
+type PatchResult struct {
+	Version int64
+	Err     error
+}
+
+const maxCommitAttempts = 3
+
+func validatePatch(patch CodePatch) error {
 	if !validOperations[patch.Operation] {
 		return fmt.Errorf("invalid patch operation: %s", patch.Operation)
 	}
@@ -35,29 +50,83 @@ func (interview *Interview) AddCodePatch(patch CodePatch) error {
 	if patch.StartPos < 0 || (patch.Operation != "add" && patch.EndPos <= patch.StartPos) {
 		return fmt.Errorf("invalid position range")
 	}
+	return nil
+}
 
-	return c.Client.Watch(c.Ctx, func(tx *redis.Tx) error {
+func (interview *Interview) AddCodePatch(patch CodePatch) error {
+	results, err := interview.AddCodePatches([]CodePatch{patch})
+	if err != nil {
+		return err
+	}
+	return results[0].Err
+}
+
+// AddCodePatches commits patches in order in a single Redis transaction. A
+// patch that is invalid or written against a stale version is rejected on its
+// own, in its PatchResult, without affecting the others.
+func (interview *Interview) AddCodePatches(patches []CodePatch) ([]PatchResult, error) {
+	c := interview.Cache
+	results := make([]PatchResult, len(patches))
+	invalid := make([]error, len(patches))
+	for i, patch := range patches {
+		invalid[i] = validatePatch(patch)
+	}
+
+	commit := func(tx *redis.Tx) error {
 		currentVersion, err := tx.Get(c.Ctx, interview.VersionCacheKey).Int64()
 		if err != nil && !errors.Is(err, redis.Nil) {
 			return err
 		}
 
-		if patch.Version != 0 && patch.Version-1 != currentVersion {
-			return fmt.Errorf("version mismatch: patch version %d, expected base %d", patch.Version, currentVersion)
-		}
-
-		newVersion := currentVersion + 1
-		patch.Version = newVersion
-
 		pipe := tx.TxPipeline()
-		pipe.Set(c.Ctx, interview.VersionCacheKey, newVersion, redis.KeepTTL)
+		stacks := newUndoStacks(c, tx)
+		newVersion := currentVersion
+		for i, patch := range patches {
+			results[i] = PatchResult{Err: invalid[i]}
+			if invalid[i] != nil {
+				continue
+			}
+			if patch.Version != 0 && patch.Version-1 != newVersion {
+				results[i].Err = fmt.Errorf("%w: patch version %d, expected base %d", ErrVersionMismatch, patch.Version, newVersion)
+				continue
+			}
+			if patch.Author != "" {
+				if err := stacks.check(interview, patch); err != nil {
+					if errors.Is(err, ErrUndoStale) {
+						results[i].Err = err
+						continue
+					}
+					return err
+				}
+			}
 
-		patchJSON, err := json.Marshal(patch)
-		if err != nil {
-			return err
+			newVersion++
+			patch.Version = newVersion
+			patchJSON, err := json.Marshal(patch)
+			if err != nil {
+				return err
+			}
+			pipe.LPush(c.Ctx, interview.PatchKey, patchJSON)
+			pipe.ZAdd(c.Ctx, interview.HistoryKey, redis.Z{Score: float64(newVersion), Member: patchJSON})
+			if eventType := interview.patchEventType(); eventType != "" {
+				if err := appendTimelineEvent(c, pipe, interview.TimelineKey, eventType, patch.Author, patch); err != nil {
+					return err
+				}
+			}
+			if patch.Author != "" {
+				if err := stacks.record(interview, patch, newVersion); err != nil {
+					return err
+				}
+			}
+			results[i].Version = newVersion
 		}
 
-		pipe.LPush(c.Ctx, interview.PatchKey, patchJSON)
+		if newVersion == currentVersion {
+			return nil
+		}
+		pipe.Set(c.Ctx, interview.VersionCacheKey, newVersion, redis.KeepTTL)
+		pipe.ExpireNX(c.Ctx, interview.HistoryKey, time.Hour*24)
+		stacks.write(pipe)
 
 		_, err = pipe.Exec(c.Ctx)
 		if err != nil {
@@ -65,57 +134,82 @@ func (interview *Interview) AddCodePatch(patch CodePatch) error {
 		}
 		interview.Version = newVersion
 
-		if newVersion%10 == 0 {
+		if newVersion/10 > currentVersion/10 {
 			go interview.CompactCodePatches()
 		}
 
 		return nil
-	})
+	}
+
+	for attempt := 0; attempt < maxCommitAttempts; attempt++ {
+		err := c.Client.Watch(c.Ctx, commit, interview.VersionCacheKey)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return results, err
+		}
+	}
+	return results, redis.TxFailedErr
 }
+
+// CompactCodePatches folds the pending patches into the code state and keeps
+// the result as a checkpoint for CodeAt. The full patch history is kept.
 func (interview *Interview) CompactCodePatches() string {
 	c := interview.Cache
 
-	code, err := interview.rebuildCodeFromPatches()
-	if err != nil {
-		return ""
-	}
-	pipe := c.Client.TxPipeline()
+	var code string
+	err := c.Client.Watch(c.Ctx, func(tx *redis.Tx) error {
+		var version int64
+		var err error
+		code, version, err = interview.rebuildCodeFromPatches()
+		if err != nil {
+			return err
+		}
 
-	state := CodeState{
-		Content: code,
-		Version: interview.Version,
-	}
-	stateJSON, err := json.Marshal(state)
-	if err != nil {
+		state := CodeState{
+			Content: code,
+			Version: version,
+		}
+		stateJSON, err := json.Marshal(state)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(c.Ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(c.Ctx, interview.StateCacheKey, stateJSON, redis.KeepTTL)
+			pipe.LTrim(c.Ctx, interview.PatchKey, 1, 0)
+			pipe.ZAdd(c.Ctx, interview.CheckpointKey, redis.Z{Score: float64(version), Member: stateJSON})
+			return nil
+		})
+		return err
+	}, interview.PatchKey)
+	if err != nil && !errors.Is(err, redis.TxFailedErr) {
 		return ""
 	}
-
-	pipe.Set(c.Ctx, interview.StateCacheKey, stateJSON, redis.KeepTTL)
-	pipe.LTrim(c.Ctx, interview.PatchKey, 1, 0)
-
-	_, err = pipe.Exec(c.Ctx)
 	return code
 }
 
-func (interview *Interview) rebuildCodeFromPatches() (string, error) {
+// CurrentCode returns the latest code with its version.
+func (interview *Interview) CurrentCode() (string, int64, error) {
+	return interview.rebuildCodeFromPatches()
+}
+
+// rebuildCodeFromPatches applies the pending patches to the code state and
+// returns the code with the version it corresponds to.
+func (interview *Interview) rebuildCodeFromPatches() (string, int64, error) {
 	c := interview.Cache
 	stateStr, err := c.Client.Get(c.Ctx, interview.StateCacheKey).Result()
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 	var state CodeState
-	var baseCode string
-	if json.Unmarshal([]byte(stateStr), &state) == nil {
-		baseCode = state.Content
-	} else {
-		return "", err
+	if err := json.Unmarshal([]byte(stateStr), &state); err != nil {
+		return "", 0, err
 	}
 	patchStrings, err := c.Client.LRange(c.Ctx, interview.PatchKey, 0, -1).Result()
 
 	if err != nil || len(patchStrings) == 0 {
-		return baseCode, nil
+		return state.Content, state.Version, nil
 	}
-	patches := make([]CodePatch, len(patchStrings))
+	patches := make([]CodePatch, 0, len(patchStrings))
 
 	for _, patchStr := range patchStrings {
 		var patch CodePatch
@@ -125,12 +219,61 @@ func (interview *Interview) rebuildCodeFromPatches() (string, error) {
 		patches = append(patches, patch)
 	}
 
-	code := []rune(baseCode)
+	code := []rune(state.Content)
+	version := state.Version
 	slices.Reverse(patches)
 	for _, patch := range patches {
 		code = c.applyPatch(code, patch)
+		version = max(version, patch.Version)
+	}
+
+	return string(code), version, nil
+}
+
+// CodeAt returns the code as it was right after version was committed,
+// starting from the closest checkpoint and replaying the patch history.
+func (interview *Interview) CodeAt(version int64) (string, error) {
+	c := interview.Cache
+
+	currentVersion, err := c.Client.Get(c.Ctx, interview.VersionCacheKey).Int64()
+	if err != nil {
+		return "", err
+	}
+	if version < 1 || version > currentVersion {
+		return "", fmt.Errorf("%w: %d, current version is %d", ErrVersionNotFound, version, currentVersion)
 	}
 
+	checkpoints, err := c.Client.ZRevRangeByScore(c.Ctx, interview.CheckpointKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(version, 10),
+		Count: 1,
+	}).Result()
+	if err != nil {
+		return "", err
+	}
+	if len(checkpoints) == 0 {
+		return "", fmt.Errorf("%w: no checkpoint before version %d", ErrVersionNotFound, version)
+	}
+	var state CodeState
+	if err := json.Unmarshal([]byte(checkpoints[0]), &state); err != nil {
+		return "", err
+	}
+
+	patchStrings, err := c.Client.ZRangeByScore(c.Ctx, interview.HistoryKey, &redis.ZRangeBy{
+		Min: "(" + strconv.FormatInt(state.Version, 10),
+		Max: strconv.FormatInt(version, 10),
+	}).Result()
+	if err != nil {
+		return "", err
+	}
+
+	code := []rune(state.Content)
+	for _, patchStr := range patchStrings {
+		var patch CodePatch
+		if json.Unmarshal([]byte(patchStr), &patch) == nil {
+			code = c.applyPatch(code, patch)
+		}
+	}
 	return string(code), nil
 }
 
@@ -0,0 +1,300 @@
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// MaxCommentLength is the longest comment, in characters.
+	MaxCommentLength  = 2000
+	maxCommentThreads = 200
+	maxThreadComments = 100
+)
+
+var (
+	ErrInvalidComment  = errors.New("invalid comment")
+	ErrThreadNotFound  = errors.New("comment thread not found")
+	ErrTooManyComments = errors.New("too many comments")
+)
+
+// CommentThread is a discussion anchored to the code from StartPos to EndPos,
+// in runes, as of Version. Every later patch shifts the anchor the way it
+// shifts the text; once the commented code is deleted or the question is
+// switched the thread is Outdated and its anchor no longer moves.
+type CommentThread struct {
+	ID         string    `json:"id"`
+	StartPos   int       `json:"start_pos"`
+	EndPos     int       `json:"end_pos"`
+	Version    int64     `json:"version"`
+	Outdated   bool      `json:"outdated,omitempty"`
+	Resolved   bool      `json:"resolved,omitempty"`
+	ResolvedBy string    `json:"resolved_by,omitempty"`
+	Comments   []Comment `json:"comments"`
+	CreatedAt  int64     `json:"created_at"`
+}
+
+type Comment struct {
+	ID     string `json:"id"`
+	Author string `json:"author"`
+	Text   string `json:"text"`
+	At     int64  `json:"at"`
+}
+
+func commentsKey(sessionID string) string {
+	return fmt.Sprintf("session:%s:comments", sessionID)
+}
+
+func newComment(author string, text string) (Comment, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Comment{}, fmt.Errorf("%w: empty text", ErrInvalidComment)
+	}
+	if utf8.RuneCountInString(text) > MaxCommentLength {
+		return Comment{}, fmt.Errorf("%w: longer than %d characters", ErrInvalidComment, MaxCommentLength)
+	}
+	return Comment{ID: generateSessionID(12), Author: author, Text: text, At: time.Now().UnixMilli()}, nil
+}
+
+// shiftAnchor moves an anchor position past patch like applyPatch moves the
+// text around it. Text inserted where a range starts goes before it, text
+// inserted where it ends goes after it, and a position inside replaced text
+// moves to the start or, for the end, the end of the new text.
+func shiftAnchor(pos int, patch CodePatch, isEnd bool) int {
+	inserted := utf8.RuneCountInString(patch.Content)
+	switch patch.Operation {
+	case "add":
+		at := max(patch.StartPos, 0)
+		if pos > at || pos == at && !isEnd {
+			return pos + inserted
+		}
+		return pos
+	case "remove", "replace":
+		start, end := patch.StartPos, patch.EndPos
+		if patch.Operation == "remove" {
+			inserted = 0
+		}
+		if start < 0 || end < start || end == start && patch.Operation == "remove" {
+			return pos
+		}
+		switch {
+		case pos <= start:
+			return pos
+		case pos >= end:
+			return pos + inserted - (end - start)
+		case isEnd:
+			return start + inserted
+		default:
+			return start
+		}
+	}
+	return pos
+}
+
+// rebase shifts the thread's anchor past patch, committed after the version
+// the anchor is at.
+func (t *CommentThread) rebase(patch CodePatch) {
+	if patch.Version <= t.Version {
+		return
+	}
+	t.Version = patch.Version
+	if t.Outdated {
+		return
+	}
+	if patch.Source == PatchSourceQuestion {
+		t.Outdated = true
+		return
+	}
+	t.StartPos = shiftAnchor(t.StartPos, patch, false)
+	t.EndPos = shiftAnchor(t.EndPos, patch, true)
+	if t.EndPos <= t.StartPos {
+		t.EndPos = t.StartPos
+		t.Outdated = true
+	}
+}
+
+// rebaseThreads brings the anchors of threads up to version.
+func (interview *Interview) rebaseThreads(threads []CommentThread, version int64) error {
+	if len(threads) == 0 {
+		return nil
+	}
+	oldest := threads[0].Version
+	for _, thread := range threads {
+		oldest = min(oldest, thread.Version)
+	}
+	if oldest >= version {
+		return nil
+	}
+	patches, err := interview.historyRange(oldest+1, version)
+	if err != nil {
+		return err
+	}
+	for i := range threads {
+		for _, patch := range patches {
+			threads[i].rebase(patch)
+		}
+	}
+	return nil
+}
+
+// CommentThreads returns the session's comment threads, oldest first, with
+// their anchors at the current version.
+func (interview *Interview) CommentThreads() ([]CommentThread, error) {
+	var threads []CommentThread
+	err := interview.updateThreads(func(loaded []CommentThread, _ int64) ([]CommentThread, error) {
+		threads = loaded
+		return nil, nil
+	})
+	return threads, err
+}
+
+// CreateCommentThread starts a thread with author's comment, anchored from
+// start to end in the code as of version.
+func (interview *Interview) CreateCommentThread(version int64, start int, end int, author string, text string) (CommentThread, error) {
+	if start < 0 || end <= start {
+		return CommentThread{}, fmt.Errorf("%w: empty range", ErrInvalidComment)
+	}
+	comment, err := newComment(author, text)
+	if err != nil {
+		return CommentThread{}, err
+	}
+
+	var created CommentThread
+	err = interview.updateThreads(func(threads []CommentThread, current int64) ([]CommentThread, error) {
+		if len(threads) >= maxCommentThreads {
+			return nil, ErrTooManyComments
+		}
+		if version > current {
+			return nil, fmt.Errorf("%w: version %d, current version is %d", ErrInvalidComment, version, current)
+		}
+		thread := []CommentThread{{
+			ID:        generateSessionID(12),
+			StartPos:  start,
+			EndPos:    end,
+			Version:   version,
+			Comments:  []Comment{comment},
+			CreatedAt: comment.At,
+		}}
+		if err := interview.rebaseThreads(thread, current); err != nil {
+			return nil, err
+		}
+		created = thread[0]
+		return thread, nil
+	})
+	if err != nil {
+		return CommentThread{}, err
+	}
+	return created, nil
+}
+
+// ReplyToThread adds author's comment to a thread.
+func (interview *Interview) ReplyToThread(threadID string, author string, text string) (CommentThread, error) {
+	comment, err := newComment(author, text)
+	if err != nil {
+		return CommentThread{}, err
+	}
+	return interview.updateThread(threadID, func(thread *CommentThread) error {
+		if len(thread.Comments) >= maxThreadComments {
+			return ErrTooManyComments
+		}
+		thread.Comments = append(thread.Comments, comment)
+		return nil
+	})
+}
+
+// ResolveThread marks a thread resolved by author, or reopens it.
+func (interview *Interview) ResolveThread(threadID string, author string, resolved bool) (CommentThread, error) {
+	return interview.updateThread(threadID, func(thread *CommentThread) error {
+		thread.Resolved = resolved
+		thread.ResolvedBy = ""
+		if resolved {
+			thread.ResolvedBy = author
+		}
+		return nil
+	})
+}
+
+func (interview *Interview) updateThread(threadID string, update func(thread *CommentThread) error) (CommentThread, error) {
+	var updated CommentThread
+	err := interview.updateThreads(func(threads []CommentThread, _ int64) ([]CommentThread, error) {
+		for _, thread := range threads {
+			if thread.ID == threadID {
+				if err := update(&thread); err != nil {
+					return nil, err
+				}
+				updated = thread
+				return []CommentThread{thread}, nil
+			}
+		}
+		return nil, ErrThreadNotFound
+	})
+	return updated, err
+}
+
+// updateThreads loads the threads with their anchors rebased to the current
+// version and stores them back along with the threads update returns, in
+// one transaction. Storing the rebased anchors keeps later rebases short.
+func (interview *Interview) updateThreads(update func(threads []CommentThread, version int64) ([]CommentThread, error)) error {
+	c := interview.Cache
+	key := commentsKey(interview.SessionID)
+
+	commit := func(tx *redis.Tx) error {
+		version, err := tx.Get(c.Ctx, interview.VersionCacheKey).Int64()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		threadStrings, err := tx.HGetAll(c.Ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		threads := make([]CommentThread, 0, len(threadStrings))
+		for _, threadStr := range threadStrings {
+			var thread CommentThread
+			if err := json.Unmarshal([]byte(threadStr), &thread); err != nil {
+				return err
+			}
+			threads = append(threads, thread)
+		}
+		sort.Slice(threads, func(i, j int) bool { return threads[i].CreatedAt < threads[j].CreatedAt })
+		if err := interview.rebaseThreads(threads, version); err != nil {
+			return err
+		}
+
+		changed, err := update(threads, version)
+		if err != nil {
+			return err
+		}
+		fields := make([]interface{}, 0, 2*(len(threads)+len(changed)))
+		for _, thread := range append(threads, changed...) {
+			threadJSON, err := json.Marshal(thread)
+			if err != nil {
+				return err
+			}
+			fields = append(fields, thread.ID, threadJSON)
+		}
+		if len(fields) == 0 {
+			return nil
+		}
+		_, err = tx.TxPipelined(c.Ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(c.Ctx, key, fields...)
+			pipe.ExpireNX(c.Ctx, key, sessionTTL)
+			return nil
+		})
+		return err
+	}
+
+	for attempt := 0; attempt < maxCommitAttempts; attempt++ {
+		err := c.Client.Watch(c.Ctx, commit, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return redis.TxFailedErr
+}
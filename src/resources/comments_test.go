@@ -0,0 +1,142 @@
+package resources
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestShiftAnchor(t *testing.T) {
+	tests := []struct {
+		name  string
+		pos   int
+		patch CodePatch
+		isEnd bool
+		want  int
+	}{
+		{"add before", 5, CodePatch{Operation: "add", StartPos: 2, Content: "abc"}, false, 8},
+		{"add after", 5, CodePatch{Operation: "add", StartPos: 7, Content: "abc"}, false, 5},
+		{"add at a start", 5, CodePatch{Operation: "add", StartPos: 5, Content: "abc"}, false, 8},
+		{"add at an end", 5, CodePatch{Operation: "add", StartPos: 5, Content: "abc"}, true, 5},
+		{"add counts runes", 5, CodePatch{Operation: "add", StartPos: 0, Content: "é✓"}, false, 7},
+		{"add before the code", 5, CodePatch{Operation: "add", StartPos: -3, Content: "ab"}, false, 7},
+		{"remove before", 9, CodePatch{Operation: "remove", StartPos: 2, EndPos: 5}, false, 6},
+		{"remove after", 2, CodePatch{Operation: "remove", StartPos: 4, EndPos: 6}, false, 2},
+		{"remove ending at", 5, CodePatch{Operation: "remove", StartPos: 2, EndPos: 5}, true, 2},
+		{"remove around a start", 4, CodePatch{Operation: "remove", StartPos: 2, EndPos: 6}, false, 2},
+		{"remove around an end", 4, CodePatch{Operation: "remove", StartPos: 2, EndPos: 6}, true, 2},
+		{"empty remove", 4, CodePatch{Operation: "remove", StartPos: 2, EndPos: 2}, false, 4},
+		{"inverted remove", 4, CodePatch{Operation: "remove", StartPos: 6, EndPos: 2}, false, 4},
+		{"replace before", 9, CodePatch{Operation: "replace", StartPos: 2, EndPos: 5, Content: "x"}, false, 7},
+		{"replace around a start", 4, CodePatch{Operation: "replace", StartPos: 2, EndPos: 6, Content: "xyz"}, false, 2},
+		{"replace around an end", 4, CodePatch{Operation: "replace", StartPos: 2, EndPos: 6, Content: "xyz"}, true, 5},
+		{"empty replace inserts", 4, CodePatch{Operation: "replace", StartPos: 2, EndPos: 2, Content: "xy"}, false, 6},
+		{"unknown operation", 4, CodePatch{Operation: "move", StartPos: 0, EndPos: 2}, false, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shiftAnchor(tt.pos, tt.patch, tt.isEnd); got != tt.want {
+				t.Errorf("shiftAnchor(%d) = %d, want %d", tt.pos, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommentThreadRebase(t *testing.T) {
+	tests := []struct {
+		name   string
+		thread CommentThread
+		patch  CodePatch
+		want   CommentThread
+	}{
+		{
+			name:   "typing before",
+			thread: CommentThread{StartPos: 10, EndPos: 20, Version: 3},
+			patch:  CodePatch{Version: 4, Operation: "add", StartPos: 0, Content: "ab"},
+			want:   CommentThread{StartPos: 12, EndPos: 22, Version: 4},
+		},
+		{
+			name:   "typing at both ends",
+			thread: CommentThread{StartPos: 10, EndPos: 20, Version: 3},
+			patch:  CodePatch{Version: 4, Operation: "add", StartPos: 20, Content: "ab"},
+			want:   CommentThread{StartPos: 10, EndPos: 20, Version: 4},
+		},
+		{
+			name:   "typing inside",
+			thread: CommentThread{StartPos: 10, EndPos: 20, Version: 3},
+			patch:  CodePatch{Version: 4, Operation: "add", StartPos: 15, Content: "ab"},
+			want:   CommentThread{StartPos: 10, EndPos: 22, Version: 4},
+		},
+		{
+			name:   "already past the patch",
+			thread: CommentThread{StartPos: 10, EndPos: 20, Version: 4},
+			patch:  CodePatch{Version: 4, Operation: "add", StartPos: 0, Content: "ab"},
+			want:   CommentThread{StartPos: 10, EndPos: 20, Version: 4},
+		},
+		{
+			name:   "part of the code deleted",
+			thread: CommentThread{StartPos: 10, EndPos: 20, Version: 3},
+			patch:  CodePatch{Version: 4, Operation: "remove", StartPos: 5, EndPos: 15},
+			want:   CommentThread{StartPos: 5, EndPos: 10, Version: 4},
+		},
+		{
+			name:   "commented code deleted",
+			thread: CommentThread{StartPos: 10, EndPos: 20, Version: 3},
+			patch:  CodePatch{Version: 4, Operation: "remove", StartPos: 8, EndPos: 25},
+			want:   CommentThread{StartPos: 8, EndPos: 8, Version: 4, Outdated: true},
+		},
+		{
+			name:   "outdated stays put",
+			thread: CommentThread{StartPos: 8, EndPos: 8, Version: 4, Outdated: true},
+			patch:  CodePatch{Version: 5, Operation: "add", StartPos: 0, Content: "ab"},
+			want:   CommentThread{StartPos: 8, EndPos: 8, Version: 5, Outdated: true},
+		},
+		{
+			name:   "question switched",
+			thread: CommentThread{StartPos: 10, EndPos: 20, Version: 3},
+			patch:  CodePatch{Version: 4, Operation: "replace", StartPos: 0, EndPos: 30, Content: "x", Source: PatchSourceQuestion},
+			want:   CommentThread{StartPos: 10, EndPos: 20, Version: 4, Outdated: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thread := tt.thread
+			thread.rebase(tt.patch)
+			if thread.StartPos != tt.want.StartPos || thread.EndPos != tt.want.EndPos ||
+				thread.Version != tt.want.Version || thread.Outdated != tt.want.Outdated {
+				t.Errorf("rebase() = %+v, want %+v", thread, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewComment(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		wantText string
+		wantErr  error
+	}{
+		{"trimmed", "  looks good\n", "looks good", nil},
+		{"empty", " \n\t", "", ErrInvalidComment},
+		{"at the limit", strings.Repeat("é", MaxCommentLength), strings.Repeat("é", MaxCommentLength), nil},
+		{"too long", strings.Repeat("a", MaxCommentLength+1), "", ErrInvalidComment},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comment, err := newComment("User1", tt.text)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("newComment() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if comment.Text != tt.wantText || comment.Author != "User1" || comment.ID == "" {
+				t.Errorf("newComment() = %+v", comment)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package resources
+
+import (
+	"CodeStream/src"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// DB is the durable store for data that must outlive the Redis TTL. It is nil
+// when DATABASE_DRIVER is not set.
+var DB *Database
+
+type Database struct {
+	*sql.DB
+	Driver string
+}
+
+// migrations are applied in order, once each, on startup. Statements must be
+// valid for both SQLite and PostgreSQL.
+var migrations = []string{
+	`CREATE TABLE archived_interviews (
+		session_id   TEXT PRIMARY KEY,
+		language     TEXT NOT NULL,
+		code         TEXT NOT NULL,
+		version      BIGINT NOT NULL,
+		participants TEXT NOT NULL,
+		runs         TEXT NOT NULL,
+		timeline     TEXT NOT NULL,
+		reason       TEXT NOT NULL,
+		created_at   BIGINT NOT NULL,
+		ended_at     BIGINT NOT NULL
+	)`,
+	`CREATE INDEX archived_interviews_ended_at ON archived_interviews (ended_at)`,
+	`CREATE TABLE problems (
+		id           TEXT PRIMARY KEY,
+		title        TEXT NOT NULL,
+		statement    TEXT NOT NULL,
+		starter_code TEXT NOT NULL,
+		test_cases   TEXT NOT NULL,
+		difficulty   TEXT NOT NULL,
+		tags         TEXT NOT NULL,
+		created_at   BIGINT NOT NULL,
+		updated_at   BIGINT NOT NULL
+	)`,
+	`ALTER TABLE problems ADD COLUMN judging TEXT NOT NULL DEFAULT '{}'`,
+	`ALTER TABLE archived_interviews ADD COLUMN questions TEXT NOT NULL DEFAULT '[]'`,
+	`ALTER TABLE archived_interviews ADD COLUMN notes TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE archived_interviews ADD COLUMN scorecard TEXT NOT NULL DEFAULT '{}'`,
+	`ALTER TABLE archived_interviews ADD COLUMN integrity TEXT NOT NULL DEFAULT '[]'`,
+	`ALTER TABLE archived_interviews ADD COLUMN comments TEXT NOT NULL DEFAULT '[]'`,
+}
+
+func SetupDatabase() {
+	var driverName string
+	switch src.Config.DatabaseDriver {
+	case "":
+		return
+	case "sqlite":
+		driverName = "sqlite3"
+		if dir := filepath.Dir(src.Config.DatabaseURL); dir != "." {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				panic(err)
+			}
+		}
+	case "postgres":
+		driverName = "pgx"
+	default:
+		panic(fmt.Sprintf("unsupported DATABASE_DRIVER: %s", src.Config.DatabaseDriver))
+	}
+
+	sqlDB, err := sql.Open(driverName, src.Config.DatabaseURL)
+	if err != nil {
+		panic(err)
+	}
+	if driverName == "sqlite3" {
+		// SQLite allows a single writer; serialising avoids "database is locked".
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := sqlDB.PingContext(ctx); err != nil {
+		panic(fmt.Sprintf("failed to connect to database: %s", err))
+	}
+
+	db := &Database{DB: sqlDB, Driver: src.Config.DatabaseDriver}
+	if err := db.migrate(ctx); err != nil {
+		panic(fmt.Sprintf("failed to migrate database: %s", err))
+	}
+	DB = db
+}
+
+func (db *Database) migrate(ctx context.Context) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`)
+	if err != nil {
+		return err
+	}
+
+	var applied int
+	err = db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&applied)
+	if err != nil {
+		return err
+	}
+
+	for i := applied; i < len(migrations); i++ {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, db.Rebind(`INSERT INTO schema_migrations (version) VALUES (?)`), i+1); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rebind rewrites the ? placeholders of query for the database's driver.
+func (db *Database) Rebind(query string) string {
+	if db.Driver != "postgres" {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
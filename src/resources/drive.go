@@ -0,0 +1,138 @@
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DriveLock lets only one participant edit the code at a time. While Locked,
+// only the Driver may patch it; Requests holds who asked to drive, oldest
+// first.
+type DriveLock struct {
+	Locked   bool     `json:"locked"`
+	Driver   string   `json:"driver,omitempty"`
+	Requests []string `json:"requests,omitempty"`
+}
+
+var ErrInvalidDrive = errors.New("invalid drive change")
+
+func driveKey(sessionID string) string {
+	return fmt.Sprintf("session:%s:drive", sessionID)
+}
+
+// Lock turns the lock on with driver at the wheel.
+func (d *DriveLock) Lock(driver string) {
+	d.Locked = true
+	d.Grant(driver)
+}
+
+// Unlock lets everyone edit again and drops the pending requests.
+func (d *DriveLock) Unlock() {
+	*d = DriveLock{}
+}
+
+// Request queues username asking to drive.
+func (d *DriveLock) Request(username string) error {
+	if !d.Locked {
+		return fmt.Errorf("%w: the editor is not locked", ErrInvalidDrive)
+	}
+	if d.Driver == username {
+		return fmt.Errorf("%w: %s is already driving", ErrInvalidDrive, username)
+	}
+	if !slices.Contains(d.Requests, username) {
+		d.Requests = append(d.Requests, username)
+	}
+	return nil
+}
+
+// Grant hands the wheel to username, answering their request if they made
+// one.
+func (d *DriveLock) Grant(username string) {
+	d.Driver = username
+	d.Requests = slices.DeleteFunc(d.Requests, func(requester string) bool { return requester == username })
+}
+
+// Release drops username's request to drive and, if they were driving,
+// leaves the wheel free for an interviewer to grant or take back. It reports
+// whether the lock changed.
+func (d *DriveLock) Release(username string) bool {
+	requests := len(d.Requests)
+	d.Requests = slices.DeleteFunc(d.Requests, func(requester string) bool { return requester == username })
+	if d.Locked && d.Driver == username {
+		d.Driver = ""
+		return true
+	}
+	return len(d.Requests) != requests
+}
+
+// CanEdit reports whether username may change the code.
+func (d DriveLock) CanEdit(username string) bool {
+	return !d.Locked || d.Driver == username
+}
+
+// DriveLock returns the session's drive lock, unlocked if it was never set.
+func (interview *Interview) DriveLock() (DriveLock, error) {
+	c := interview.Cache
+	driveStr, err := c.Client.Get(c.Ctx, driveKey(interview.SessionID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return DriveLock{}, nil
+	}
+	if err != nil {
+		return DriveLock{}, err
+	}
+
+	var lock DriveLock
+	if err := json.Unmarshal([]byte(driveStr), &lock); err != nil {
+		return DriveLock{}, err
+	}
+	return lock, nil
+}
+
+// UpdateDriveLock applies update to the session's drive lock atomically and
+// records the result, changed by author, on the timeline.
+func (interview *Interview) UpdateDriveLock(author string, update func(lock *DriveLock) error) (DriveLock, error) {
+	c := interview.Cache
+	key := driveKey(interview.SessionID)
+
+	var lock DriveLock
+	commit := func(tx *redis.Tx) error {
+		lock = DriveLock{}
+		driveStr, err := tx.Get(c.Ctx, key).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		if err == nil {
+			if err := json.Unmarshal([]byte(driveStr), &lock); err != nil {
+				return err
+			}
+		}
+		if err := update(&lock); err != nil {
+			return err
+		}
+
+		lockJSON, err := json.Marshal(lock)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(c.Ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(c.Ctx, key, lockJSON, sessionTTL)
+			return appendTimelineEvent(c, pipe, interview.TimelineKey, TimelineDrive, author, lock)
+		})
+		return err
+	}
+
+	for attempt := 0; attempt < maxCommitAttempts; attempt++ {
+		err := c.Client.Watch(c.Ctx, commit, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			if err != nil {
+				return DriveLock{}, err
+			}
+			return lock, nil
+		}
+	}
+	return DriveLock{}, redis.TxFailedErr
+}
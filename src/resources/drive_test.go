@@ -0,0 +1,135 @@
+package resources
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDriveLockTransitions(t *testing.T) {
+	tests := []struct {
+		name    string
+		lock    DriveLock
+		change  func(lock *DriveLock) error
+		want    DriveLock
+		wantErr error
+	}{
+		{
+			name:   "lock",
+			change: func(lock *DriveLock) error { lock.Lock("Interviewer"); return nil },
+			want:   DriveLock{Locked: true, Driver: "Interviewer"},
+		},
+		{
+			name:   "unlock drops requests",
+			lock:   DriveLock{Locked: true, Driver: "Interviewer", Requests: []string{"User1"}},
+			change: func(lock *DriveLock) error { lock.Unlock(); return nil },
+			want:   DriveLock{},
+		},
+		{
+			name:   "request",
+			lock:   DriveLock{Locked: true, Driver: "Interviewer", Requests: []string{"User1"}},
+			change: func(lock *DriveLock) error { return lock.Request("User2") },
+			want:   DriveLock{Locked: true, Driver: "Interviewer", Requests: []string{"User1", "User2"}},
+		},
+		{
+			name:   "request twice",
+			lock:   DriveLock{Locked: true, Driver: "Interviewer", Requests: []string{"User1"}},
+			change: func(lock *DriveLock) error { return lock.Request("User1") },
+			want:   DriveLock{Locked: true, Driver: "Interviewer", Requests: []string{"User1"}},
+		},
+		{
+			name:    "request while unlocked",
+			change:  func(lock *DriveLock) error { return lock.Request("User1") },
+			want:    DriveLock{},
+			wantErr: ErrInvalidDrive,
+		},
+		{
+			name:    "request by the driver",
+			lock:    DriveLock{Locked: true, Driver: "User1"},
+			change:  func(lock *DriveLock) error { return lock.Request("User1") },
+			want:    DriveLock{Locked: true, Driver: "User1"},
+			wantErr: ErrInvalidDrive,
+		},
+		{
+			name:   "grant answers the request",
+			lock:   DriveLock{Locked: true, Driver: "Interviewer", Requests: []string{"User1", "User2"}},
+			change: func(lock *DriveLock) error { lock.Grant("User2"); return nil },
+			want:   DriveLock{Locked: true, Driver: "User2", Requests: []string{"User1"}},
+		},
+		{
+			name:   "release by the driver",
+			lock:   DriveLock{Locked: true, Driver: "User1", Requests: []string{"User2"}},
+			change: func(lock *DriveLock) error { lock.Release("User1"); return nil },
+			want:   DriveLock{Locked: true, Requests: []string{"User2"}},
+		},
+		{
+			name:   "release by a requester",
+			lock:   DriveLock{Locked: true, Driver: "Interviewer", Requests: []string{"User1", "User2"}},
+			change: func(lock *DriveLock) error { lock.Release("User1"); return nil },
+			want:   DriveLock{Locked: true, Driver: "Interviewer", Requests: []string{"User2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lock := tt.lock
+			if err := tt.change(&lock); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if len(lock.Requests) == 0 {
+				lock.Requests = nil
+			}
+			if !reflect.DeepEqual(lock, tt.want) {
+				t.Errorf("lock = %+v, want %+v", lock, tt.want)
+			}
+		})
+	}
+}
+
+func TestDriveLockRelease(t *testing.T) {
+	tests := []struct {
+		name        string
+		lock        DriveLock
+		username    string
+		wantChanged bool
+	}{
+		{"driver", DriveLock{Locked: true, Driver: "User1"}, "User1", true},
+		{"requester", DriveLock{Locked: true, Driver: "User1", Requests: []string{"User2"}}, "User2", true},
+		{"bystander", DriveLock{Locked: true, Driver: "User1", Requests: []string{"User2"}}, "User3", false},
+		{"unlocked", DriveLock{}, "User1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lock := tt.lock
+			if changed := lock.Release(tt.username); changed != tt.wantChanged {
+				t.Errorf("Release(%q) = %v, want %v", tt.username, changed, tt.wantChanged)
+			}
+			if lock.Locked && lock.Driver == tt.username {
+				t.Errorf("%s still drives", tt.username)
+			}
+		})
+	}
+}
+
+func TestDriveLockCanEdit(t *testing.T) {
+	tests := []struct {
+		name     string
+		lock     DriveLock
+		username string
+		want     bool
+	}{
+		{"unlocked", DriveLock{}, "User1", true},
+		{"driver", DriveLock{Locked: true, Driver: "User1"}, "User1", true},
+		{"someone else", DriveLock{Locked: true, Driver: "User1"}, "User2", false},
+		{"nobody driving", DriveLock{Locked: true}, "User1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.lock.CanEdit(tt.username); got != tt.want {
+				t.Errorf("CanEdit(%q) = %v, want %v", tt.username, got, tt.want)
+			}
+		})
+	}
+}
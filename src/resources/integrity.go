@@ -0,0 +1,79 @@
+package resources
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// PasteEventData describes an insertion large enough to have been pasted.
+type PasteEventData struct {
+	Version  int64 `json:"version"`
+	StartPos int   `json:"start_pos"`
+	Chars    int   `json:"chars"`
+	Lines    int   `json:"lines"`
+}
+
+// IntegritySummary totals a participant's integrity signals. Unfocused is set
+// while the participant is away from the session.
+type IntegritySummary struct {
+	Username     string `json:"username"`
+	Pastes       int    `json:"pastes"`
+	PastedChars  int    `json:"pasted_chars"`
+	LargestPaste int    `json:"largest_paste"`
+	FocusLosses  int    `json:"focus_losses"`
+	UnfocusedMs  int64  `json:"unfocused_ms"`
+	Unfocused    bool   `json:"unfocused,omitempty"`
+}
+
+// SummarizeIntegrity totals the integrity signals of each participant that
+// has any, in order of username. Time away is counted until the participant
+// returns or leaves, or until now.
+func SummarizeIntegrity(timeline []TimelineEvent, now int64) []IntegritySummary {
+	summaries := make(map[string]*IntegritySummary)
+	awaySince := make(map[string]int64)
+	summary := func(username string) *IntegritySummary {
+		if summaries[username] == nil {
+			summaries[username] = &IntegritySummary{Username: username}
+		}
+		return summaries[username]
+	}
+	returned := func(username string, at int64) {
+		if since, ok := awaySince[username]; ok {
+			summary(username).UnfocusedMs += at - since
+			delete(awaySince, username)
+		}
+	}
+
+	for _, event := range timeline {
+		switch event.Type {
+		case TimelinePaste:
+			var data PasteEventData
+			if json.Unmarshal(event.Data, &data) != nil {
+				continue
+			}
+			s := summary(event.Author)
+			s.Pastes++
+			s.PastedChars += data.Chars
+			s.LargestPaste = max(s.LargestPaste, data.Chars)
+		case TimelineFocusLost:
+			if _, ok := awaySince[event.Author]; !ok {
+				summary(event.Author).FocusLosses++
+				awaySince[event.Author] = event.At
+			}
+		case TimelineFocusGained, TimelineLeave:
+			returned(event.Author, event.At)
+		}
+	}
+	for username, since := range awaySince {
+		s := summary(username)
+		s.UnfocusedMs += max(now-since, 0)
+		s.Unfocused = true
+	}
+
+	result := make([]IntegritySummary, 0, len(summaries))
+	for _, s := range summaries {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Username < result[j].Username })
+	return result
+}
@@ -0,0 +1,104 @@
+package resources
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func pasteEvent(author string, at int64, chars int) TimelineEvent {
+	data, _ := json.Marshal(PasteEventData{Chars: chars, Lines: 1})
+	return TimelineEvent{Type: TimelinePaste, At: at, Author: author, Data: data}
+}
+
+func TestSummarizeIntegrity(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeline []TimelineEvent
+		now      int64
+		want     []IntegritySummary
+	}{
+		{
+			name: "no signals",
+			timeline: []TimelineEvent{
+				{Type: TimelineJoin, At: 0, Author: "User1"},
+				{Type: TimelinePatch, At: 10, Author: "User1"},
+			},
+			now:  100,
+			want: []IntegritySummary{},
+		},
+		{
+			name: "pastes",
+			timeline: []TimelineEvent{
+				pasteEvent("User1", 10, 120),
+				pasteEvent("User1", 20, 300),
+				pasteEvent("User2", 30, 150),
+				{Type: TimelinePaste, At: 40, Author: "User2", Data: json.RawMessage(`"broken"`)},
+			},
+			now: 100,
+			want: []IntegritySummary{
+				{Username: "User1", Pastes: 2, PastedChars: 420, LargestPaste: 300},
+				{Username: "User2", Pastes: 1, PastedChars: 150, LargestPaste: 150},
+			},
+		},
+		{
+			name: "time away until returning",
+			timeline: []TimelineEvent{
+				{Type: TimelineFocusLost, At: 100, Author: "User1"},
+				{Type: TimelineFocusGained, At: 400, Author: "User1"},
+				{Type: TimelineFocusLost, At: 1000, Author: "User1"},
+				{Type: TimelineFocusGained, At: 1050, Author: "User1"},
+			},
+			now:  5000,
+			want: []IntegritySummary{{Username: "User1", FocusLosses: 2, UnfocusedMs: 350}},
+		},
+		{
+			name: "repeated focus loss counts once",
+			timeline: []TimelineEvent{
+				{Type: TimelineFocusLost, At: 100, Author: "User1"},
+				{Type: TimelineFocusLost, At: 200, Author: "User1"},
+				{Type: TimelineFocusGained, At: 300, Author: "User1"},
+				{Type: TimelineFocusGained, At: 900, Author: "User1"},
+			},
+			now:  5000,
+			want: []IntegritySummary{{Username: "User1", FocusLosses: 1, UnfocusedMs: 200}},
+		},
+		{
+			name: "leaving ends time away",
+			timeline: []TimelineEvent{
+				{Type: TimelineFocusLost, At: 100, Author: "User1"},
+				{Type: TimelineLeave, At: 600, Author: "User1"},
+			},
+			now:  5000,
+			want: []IntegritySummary{{Username: "User1", FocusLosses: 1, UnfocusedMs: 500}},
+		},
+		{
+			name: "still away",
+			timeline: []TimelineEvent{
+				pasteEvent("User2", 50, 200),
+				{Type: TimelineFocusLost, At: 100, Author: "User1"},
+			},
+			now: 1100,
+			want: []IntegritySummary{
+				{Username: "User1", FocusLosses: 1, UnfocusedMs: 1000, Unfocused: true},
+				{Username: "User2", Pastes: 1, PastedChars: 200, LargestPaste: 200},
+			},
+		},
+		{
+			name: "clock behind the timeline",
+			timeline: []TimelineEvent{
+				{Type: TimelineFocusLost, At: 100, Author: "User1"},
+			},
+			now:  50,
+			want: []IntegritySummary{{Username: "User1", FocusLosses: 1, Unfocused: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SummarizeIntegrity(tt.timeline, tt.now); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SummarizeIntegrity() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
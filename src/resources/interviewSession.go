@@ -8,16 +8,24 @@ import (
 	"math/big"
 	"strconv"
 	"time"
+
+	"github.com/redis/go-redis/v9"
 )
 
+// Interview is a session's code document. The same type edits the session's
+// other documents, see Statement, with Document naming them.
 type Interview struct {
 	SessionID       string
+	Document        string
 	Language        string
 	Version         int64
 	StateCacheKey   string
 	VersionCacheKey string
 	PatchKey        string
 	LanguageKey     string
+	TimelineKey     string
+	HistoryKey      string
+	CheckpointKey   string
 	Cache           *Cache
 }
 
@@ -27,6 +35,9 @@ type CodePatch struct {
 	StartPos  int    `json:"start_pos"`
 	EndPos    int    `json:"end_pos"`
 	Content   string `json:"content"`
+	Author    string `json:"author,omitempty"`
+	Source    string `json:"source,omitempty"`
+	Reverts   int64  `json:"reverts,omitempty"`
 }
 
 type CodeState struct {
@@ -61,7 +72,7 @@ func CanCreateSession(c *Cache, ip string) bool {
 	return true
 }
 
-func CreateInterviewSession(c *Cache) (Interview, error, bool) {
+func CreateInterviewSession(c *Cache, options SessionOptions) (Interview, error, bool) {
 	var sessionID string
 	var stateKey string
 	for i := 6; i < 100; i++ {
@@ -77,13 +88,19 @@ func CreateInterviewSession(c *Cache) (Interview, error, bool) {
 	}
 
 	defaultLanguage := src.Config.Languages[0]
+	starterCode := ""
+	if len(options.Problems) > 0 {
+		defaultLanguage, starterCode = options.Problems[0].StarterFor(src.Config.Languages)
+	}
 
 	currentLanguageKey := fmt.Sprintf("session:%s:lang", sessionID)
 	versionKey := fmt.Sprintf("session:%s:version", sessionID)
 	patchKey := fmt.Sprintf("session:%s:patch", sessionID)
+	checkpointKey := fmt.Sprintf("session:%s:checkpoints", sessionID)
+	interviewerKey := fmt.Sprintf("session:%s:interviewer_key", sessionID)
 
 	state := CodeState{
-		Content: "",
+		Content: starterCode,
 		Version: 1,
 	}
 
@@ -91,6 +108,10 @@ func CreateInterviewSession(c *Cache) (Interview, error, bool) {
 	if err != nil {
 		return Interview{}, err, false
 	}
+	lifecycleJSON, err := json.Marshal(newSessionLifecycle(options))
+	if err != nil {
+		return Interview{}, err, false
+	}
 
 	pipe := c.Client.TxPipeline()
 	pipe.Set(c.Ctx, stateKey, stateJSON, time.Hour*24)
@@ -98,6 +119,30 @@ func CreateInterviewSession(c *Cache) (Interview, error, bool) {
 	pipe.Set(c.Ctx, currentLanguageKey, defaultLanguage, time.Hour*24)
 	pipe.LPush(c.Ctx, patchKey, "", time.Hour*24)
 	pipe.LTrim(c.Ctx, patchKey, 1, 0)
+	err = appendTimelineEvent(c, pipe, timelineKey(sessionID), TimelineCreated, "", CreatedEventData{
+		Language: defaultLanguage,
+		Code:     state.Content,
+		Version:  state.Version,
+	})
+	if err != nil {
+		return Interview{}, err, false
+	}
+	pipe.Expire(c.Ctx, timelineKey(sessionID), time.Hour*24)
+	pipe.ZAdd(c.Ctx, checkpointKey, redis.Z{Score: float64(state.Version), Member: stateJSON})
+	pipe.Expire(c.Ctx, checkpointKey, time.Hour*24)
+	pipe.Set(c.Ctx, interviewerKey, generateSessionID(24), time.Hour*24)
+	pipe.Set(c.Ctx, lifecycleKey(sessionID), lifecycleJSON, time.Hour*24)
+	if err := storeSessionQuestions(c, pipe, sessionID, options.Problems); err != nil {
+		return Interview{}, err, false
+	}
+	statement := ""
+	if len(options.Problems) > 0 {
+		statement = options.Problems[0].Statement
+	}
+	if err := initDocument(c, pipe, sessionDocument(c, sessionID, DocumentStatement), statement); err != nil {
+		return Interview{}, err, false
+	}
+	trackSessionExpiry(c, pipe, sessionID)
 	_, err = pipe.Exec(c.Ctx)
 	return Interview{
 		SessionID:       sessionID,
@@ -108,9 +153,20 @@ func CreateInterviewSession(c *Cache) (Interview, error, bool) {
 		PatchKey:        patchKey,
 		VersionCacheKey: versionKey,
 		LanguageKey:     currentLanguageKey,
+		TimelineKey:     timelineKey(sessionID),
+		HistoryKey:      fmt.Sprintf("session:%s:history", sessionID),
+		CheckpointKey:   checkpointKey,
 	}, nil, true
 }
 
+// InterviewerKey returns the secret that grants the interviewer role in the
+// session; it is handed out once, when the session is created.
+func (interview *Interview) InterviewerKey() string {
+	val := interview.Cache.Get(fmt.Sprintf("session:%s:interviewer_key", interview.SessionID))
+	key, _ := val.(string)
+	return key
+}
+
 func (interview *Interview) EditLanguage(newLang string) error {
 	for _, lang := range src.Config.Languages {
 		if lang == newLang {
@@ -130,7 +186,7 @@ func GetInterviewSession(c *Cache, sessionID string) (Interview, error) {
 
 	langVal := c.Get(currentLanguageKey)
 	if langVal == nil {
-		return Interview{}, fmt.Errorf("failed to get language: %w")
+		return Interview{}, fmt.Errorf("failed to get language")
 	}
 	language, ok := langVal.(string)
 	if !ok {
@@ -139,7 +195,7 @@ func GetInterviewSession(c *Cache, sessionID string) (Interview, error) {
 
 	versionVal := c.Get(versionKey)
 	if versionVal == nil {
-		return Interview{}, fmt.Errorf("failed to get version: %w")
+		return Interview{}, fmt.Errorf("failed to get version")
 	}
 	versionStr, ok := versionVal.(string)
 	if !ok {
@@ -158,6 +214,9 @@ func GetInterviewSession(c *Cache, sessionID string) (Interview, error) {
 		VersionCacheKey: versionKey,
 		PatchKey:        patchKey,
 		LanguageKey:     currentLanguageKey,
+		TimelineKey:     timelineKey(sessionID),
+		HistoryKey:      fmt.Sprintf("session:%s:history", sessionID),
+		CheckpointKey:   fmt.Sprintf("session:%s:checkpoints", sessionID),
 		Cache:           c,
 	}, nil
 }
@@ -0,0 +1,200 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Judge verdicts, for a test case and for a whole solution.
+const (
+	VerdictAccepted     = "accepted"
+	VerdictWrongAnswer  = "wrong_answer"
+	VerdictTimeLimit    = "time_limit_exceeded"
+	VerdictMemoryLimit  = "memory_limit_exceeded"
+	VerdictOutputLimit  = "output_limit_exceeded"
+	VerdictRuntimeError = "runtime_error"
+)
+
+// TestResult is the outcome of one test case. The program's output is only
+// kept for test cases candidates may see.
+type TestResult struct {
+	Index    int    `json:"index"`
+	Hidden   bool   `json:"hidden,omitempty"`
+	Verdict  string `json:"verdict"`
+	TimeMs   int    `json:"time_ms,omitempty"`
+	MemoryKB int    `json:"memory_kb,omitempty"`
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+}
+
+// ErrJudgeTimeout is returned when judging outlasts its context's deadline.
+var ErrJudgeTimeout = errors.New("judging took too long")
+
+type JudgeResult struct {
+	Verdict string       `json:"verdict"`
+	Passed  int          `json:"passed"`
+	Total   int          `json:"total"`
+	Tests   []TestResult `json:"tests"`
+}
+
+// JudgeSolution runs code against the problem's test cases in order, under
+// the problem's limits, and stops at the first one that fails. Each run may
+// print DefaultOutputLimit bytes more than the expected output, and the whole
+// judging is bounded by ctx.
+func JudgeSolution(ctx context.Context, baseWorkdir string, problem *Problem, language string, code string) (*JudgeResult, error) {
+	result := &JudgeResult{
+		Verdict: VerdictAccepted,
+		Total:   len(problem.TestCases),
+		Tests:   make([]TestResult, 0, len(problem.TestCases)),
+	}
+
+	for i, testCase := range problem.TestCases {
+		if ctx.Err() != nil {
+			return nil, ErrJudgeTimeout
+		}
+		resp, err := RunUserCode(ctx, baseWorkdir, RunRequest{
+			Language:      language,
+			Code:          code,
+			Stdin:         testCase.Input,
+			TimeLimitMs:   problem.Judging.TimeLimitMs,
+			MemoryLimitMB: problem.Judging.MemoryLimitMB,
+			OutputLimit:   len(testCase.Output) + DefaultOutputLimit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		// A run cut short by the overall deadline says nothing about the
+		// solution.
+		if ctx.Err() != nil {
+			return nil, ErrJudgeTimeout
+		}
+
+		test := TestResult{
+			Index:    i,
+			Hidden:   testCase.Hidden,
+			Verdict:  runVerdict(resp),
+			TimeMs:   resp.TimeMs,
+			MemoryKB: resp.MemoryKB,
+		}
+		if test.Verdict == VerdictAccepted && !problem.Judging.Check(testCase.Output, resp.Stdout) {
+			test.Verdict = VerdictWrongAnswer
+		}
+		if !testCase.Hidden {
+			test.Stdout = truncateOutput(resp.Stdout)
+			test.Stderr = resp.Stderr
+		}
+		result.Tests = append(result.Tests, test)
+
+		if test.Verdict != VerdictAccepted {
+			result.Verdict = test.Verdict
+			break
+		}
+		result.Passed++
+	}
+	return result, nil
+}
+
+// truncateOutput keeps what is sent back of a test's output to the size of a
+// plain run's.
+func truncateOutput(output string) string {
+	if len(output) <= DefaultOutputLimit {
+		return output
+	}
+	return strings.ToValidUTF8(output[:DefaultOutputLimit], "")
+}
+
+func runVerdict(resp *RunResponse) string {
+	switch {
+	case resp.Error == errTimeLimit:
+		return VerdictTimeLimit
+	case resp.Error == errMemoryLimit:
+		return VerdictMemoryLimit
+	case resp.Error == errOutputLimit:
+		return VerdictOutputLimit
+	case resp.Error != "" || resp.ExitCode != 0:
+		return VerdictRuntimeError
+	default:
+		return VerdictAccepted
+	}
+}
+
+// Check reports whether output is an accepted answer when expected is.
+func (j ProblemJudging) Check(expected string, output string) bool {
+	switch j.Checker {
+	case CheckerExact:
+		return j.sameLines(expected, output, strings.TrimRight)
+	case CheckerLines:
+		return j.sameLines(expected, output, func(line string, _ string) string {
+			return strings.Join(strings.Fields(line), " ")
+		})
+	case CheckerFloat:
+		return j.sameTokens(expected, output, j.FloatTolerance)
+	default:
+		return j.sameTokens(expected, output, 0)
+	}
+}
+
+func (j ProblemJudging) same(a string, b string) bool {
+	if j.IgnoreCase {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// sameLines compares the lines of both answers after normalize, ignoring
+// trailing blank lines.
+func (j ProblemJudging) sameLines(expected string, output string, normalize func(string, string) string) bool {
+	split := func(text string) []string {
+		lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+		for i := range lines {
+			lines[i] = normalize(lines[i], " \t\r")
+		}
+		for len(lines) > 0 && lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+		return lines
+	}
+
+	expectedLines, outputLines := split(expected), split(output)
+	if len(expectedLines) != len(outputLines) {
+		return false
+	}
+	for i := range expectedLines {
+		if !j.same(expectedLines[i], outputLines[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameTokens compares whitespace-separated tokens. With a tolerance, numbers
+// match when their absolute or relative error is within it.
+func (j ProblemJudging) sameTokens(expected string, output string, tolerance float64) bool {
+	expectedTokens, outputTokens := strings.Fields(expected), strings.Fields(output)
+	if len(expectedTokens) != len(outputTokens) {
+		return false
+	}
+	for i := range expectedTokens {
+		if j.same(expectedTokens[i], outputTokens[i]) {
+			continue
+		}
+		if tolerance == 0 {
+			return false
+		}
+		want, err := strconv.ParseFloat(expectedTokens[i], 64)
+		if err != nil {
+			return false
+		}
+		got, err := strconv.ParseFloat(outputTokens[i], 64)
+		if err != nil || math.IsNaN(got) {
+			return false
+		}
+		if math.Abs(want-got) > tolerance*math.Max(1, math.Abs(want)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+package resources
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProblemJudgingCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		judging  ProblemJudging
+		expected string
+		output   string
+		want     bool
+	}{
+		{"exact match", ProblemJudging{Checker: CheckerExact}, "1 2\n3\n", "1 2\n3\n", true},
+		{"exact trailing spaces and lines", ProblemJudging{Checker: CheckerExact}, "1 2\n3\n", "1 2  \r\n3\n\n\n", true},
+		{"exact inner spacing", ProblemJudging{Checker: CheckerExact}, "1 2\n3\n", "1  2\n3\n", false},
+		{"exact leading spaces", ProblemJudging{Checker: CheckerExact}, "1 2", " 1 2", false},
+		{"lines inner spacing", ProblemJudging{Checker: CheckerLines}, "1 2\n3\n", "  1\t 2 \n3", true},
+		{"lines joined", ProblemJudging{Checker: CheckerLines}, "1 2\n3\n", "1 2 3\n", false},
+		{"tokens joined", ProblemJudging{Checker: CheckerTokens}, "1 2\n3\n", "1 2 3", true},
+		{"tokens default checker", ProblemJudging{}, "1 2\n3\n", "1\n2\n3", true},
+		{"tokens missing one", ProblemJudging{Checker: CheckerTokens}, "1 2 3", "1 2", false},
+		{"tokens case", ProblemJudging{Checker: CheckerTokens}, "YES", "yes", false},
+		{"tokens ignoring case", ProblemJudging{Checker: CheckerTokens, IgnoreCase: true}, "YES", "yes", true},
+		{"lines ignoring case", ProblemJudging{Checker: CheckerLines, IgnoreCase: true}, "Hello World", "hello  world", true},
+		{"float within tolerance", ProblemJudging{Checker: CheckerFloat, FloatTolerance: 1e-6}, "3.1415926", "3.14159265", true},
+		{"float outside tolerance", ProblemJudging{Checker: CheckerFloat, FloatTolerance: 1e-6}, "3.1415926", "3.1416", false},
+		{"float relative tolerance", ProblemJudging{Checker: CheckerFloat, FloatTolerance: 1e-6}, "1000000", "1000000.5", true},
+		{"float words", ProblemJudging{Checker: CheckerFloat, FloatTolerance: 1e-6}, "answer 1.0", "answer 1", true},
+		{"float word mismatch", ProblemJudging{Checker: CheckerFloat, FloatTolerance: 1e-6}, "answer 1.0", "result 1.0", false},
+		{"float nan", ProblemJudging{Checker: CheckerFloat, FloatTolerance: 1e-6}, "1.0", "NaN", false},
+		{"tokens without tolerance", ProblemJudging{Checker: CheckerTokens}, "1.0", "1", false},
+		{"empty output", ProblemJudging{Checker: CheckerTokens}, "1", "", false},
+		{"both empty", ProblemJudging{Checker: CheckerExact}, "", "\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.judging.Check(tt.expected, tt.output); got != tt.want {
+				t.Errorf("Check(%q, %q) = %v, want %v", tt.expected, tt.output, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunVerdict(t *testing.T) {
+	tests := []struct {
+		name string
+		resp RunResponse
+		want string
+	}{
+		{"clean exit", RunResponse{}, VerdictAccepted},
+		{"time limit", RunResponse{Error: errTimeLimit, ExitCode: -1}, VerdictTimeLimit},
+		{"memory limit", RunResponse{Error: errMemoryLimit, ExitCode: 137}, VerdictMemoryLimit},
+		{"output limit", RunResponse{Error: errOutputLimit, ExitCode: -1}, VerdictOutputLimit},
+		{"non-zero exit", RunResponse{ExitCode: 1}, VerdictRuntimeError},
+		{"runner error", RunResponse{Error: "unsupported language"}, VerdictRuntimeError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runVerdict(&tt.resp); got != tt.want {
+				t.Errorf("runVerdict() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncateOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{"short", "42\n", "42\n"},
+		{"at the limit", strings.Repeat("a", DefaultOutputLimit), strings.Repeat("a", DefaultOutputLimit)},
+		{"over the limit", strings.Repeat("a", DefaultOutputLimit+10), strings.Repeat("a", DefaultOutputLimit)},
+		{"split rune", strings.Repeat("a", DefaultOutputLimit-1) + "é", strings.Repeat("a", DefaultOutputLimit-1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateOutput(tt.output); got != tt.want {
+				t.Errorf("truncateOutput() has %d bytes, want %d", len(got), len(tt.want))
+			}
+		})
+	}
+}
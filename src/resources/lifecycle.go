@@ -0,0 +1,162 @@
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Session states. A session is scheduled until the interviewer starts it or
+// its start time passes, live until it is ended or its duration runs out, and
+// archived once it has been saved to the archive.
+const (
+	SessionScheduled = "scheduled"
+	SessionLive      = "live"
+	SessionEnded     = "ended"
+	SessionArchived  = "archived"
+)
+
+var ErrInvalidTransition = errors.New("invalid session state transition")
+
+// sessionTransitions lists the states each state may move to. Any session may
+// be archived, since the sweeper archives sessions that are about to expire
+// whatever their state.
+var sessionTransitions = map[string][]string{
+	SessionScheduled: {SessionLive, SessionEnded, SessionArchived},
+	SessionLive:      {SessionEnded, SessionArchived},
+	SessionEnded:     {SessionArchived},
+}
+
+// SessionLifecycle is stored on the session and recorded on the timeline at
+// each transition. Times are unix milliseconds.
+type SessionLifecycle struct {
+	State       string `json:"state"`
+	ScheduledAt int64  `json:"scheduled_at,omitempty"`
+	StartedAt   int64  `json:"started_at,omitempty"`
+	EndedAt     int64  `json:"ended_at,omitempty"`
+	DurationMs  int64  `json:"duration_ms,omitempty"`
+}
+
+// SessionOptions are chosen when a session is created. A session with
+// Problems asks them in order and starts with the first one's starter code.
+type SessionOptions struct {
+	ScheduledAt time.Time
+	Duration    time.Duration
+	Problems    []Problem
+}
+
+// EndsAt returns when a live session ends on its own, or 0 if it has no
+// duration.
+func (l SessionLifecycle) EndsAt() int64 {
+	if l.StartedAt == 0 || l.DurationMs == 0 {
+		return 0
+	}
+	return l.StartedAt + l.DurationMs
+}
+
+// ReadOnly reports whether the code can no longer be changed.
+func (l SessionLifecycle) ReadOnly() bool {
+	return l.State == SessionEnded || l.State == SessionArchived
+}
+
+func lifecycleKey(sessionID string) string {
+	return fmt.Sprintf("session:%s:lifecycle", sessionID)
+}
+
+func newSessionLifecycle(options SessionOptions) SessionLifecycle {
+	now := time.Now()
+	lifecycle := SessionLifecycle{
+		State:      SessionLive,
+		StartedAt:  now.UnixMilli(),
+		DurationMs: options.Duration.Milliseconds(),
+	}
+	if options.ScheduledAt.After(now) {
+		lifecycle.State = SessionScheduled
+		lifecycle.ScheduledAt = options.ScheduledAt.UnixMilli()
+		lifecycle.StartedAt = 0
+	}
+	return lifecycle
+}
+
+// Lifecycle returns the session's state. Sessions created before states
+// existed are live.
+func (interview *Interview) Lifecycle() (SessionLifecycle, error) {
+	c := interview.Cache
+	lifecycleStr, err := c.Client.Get(c.Ctx, lifecycleKey(interview.SessionID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return SessionLifecycle{State: SessionLive}, nil
+	}
+	if err != nil {
+		return SessionLifecycle{}, err
+	}
+
+	var lifecycle SessionLifecycle
+	if err := json.Unmarshal([]byte(lifecycleStr), &lifecycle); err != nil {
+		return SessionLifecycle{}, err
+	}
+	return lifecycle, nil
+}
+
+// transition returns the lifecycle moved to state at now, or
+// ErrInvalidTransition if the current state cannot move there.
+func (l SessionLifecycle) transition(state string, now int64) (SessionLifecycle, error) {
+	allowed := false
+	for _, next := range sessionTransitions[l.State] {
+		allowed = allowed || next == state
+	}
+	if !allowed {
+		return l, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, l.State, state)
+	}
+
+	l.State = state
+	switch state {
+	case SessionLive:
+		l.StartedAt = now
+	case SessionEnded:
+		l.EndedAt = now
+	case SessionArchived:
+		if l.EndedAt == 0 {
+			l.EndedAt = now
+		}
+	}
+	return l, nil
+}
+
+// TransitionLifecycle moves the session to state and records the transition,
+// made by author or by the server when author is empty, on the timeline.
+func (interview *Interview) TransitionLifecycle(state string, author string) (SessionLifecycle, error) {
+	c := interview.Cache
+	key := lifecycleKey(interview.SessionID)
+
+	var lifecycle SessionLifecycle
+	err := c.Client.Watch(c.Ctx, func(tx *redis.Tx) error {
+		var err error
+		lifecycle, err = interview.Lifecycle()
+		if err != nil {
+			return err
+		}
+
+		lifecycle, err = lifecycle.transition(state, time.Now().UnixMilli())
+		if err != nil {
+			return err
+		}
+
+		lifecycleJSON, err := json.Marshal(lifecycle)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(c.Ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(c.Ctx, key, lifecycleJSON, redis.KeepTTL)
+			pipe.ExpireNX(c.Ctx, key, sessionTTL)
+			return appendTimelineEvent(c, pipe, interview.TimelineKey, TimelineState, author, lifecycle)
+		})
+		return err
+	}, key)
+	if err != nil {
+		return SessionLifecycle{}, err
+	}
+	return lifecycle, nil
+}
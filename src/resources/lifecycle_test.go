@@ -0,0 +1,149 @@
+package resources
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSessionLifecycleTransition(t *testing.T) {
+	tests := []struct {
+		name      string
+		lifecycle SessionLifecycle
+		state     string
+		want      SessionLifecycle
+		wantErr   error
+	}{
+		{
+			name:      "start a scheduled session",
+			lifecycle: SessionLifecycle{State: SessionScheduled, ScheduledAt: 500},
+			state:     SessionLive,
+			want:      SessionLifecycle{State: SessionLive, ScheduledAt: 500, StartedAt: 1000},
+		},
+		{
+			name:      "end a live session",
+			lifecycle: SessionLifecycle{State: SessionLive, StartedAt: 200},
+			state:     SessionEnded,
+			want:      SessionLifecycle{State: SessionEnded, StartedAt: 200, EndedAt: 1000},
+		},
+		{
+			name:      "archive an ended session",
+			lifecycle: SessionLifecycle{State: SessionEnded, StartedAt: 200, EndedAt: 600},
+			state:     SessionArchived,
+			want:      SessionLifecycle{State: SessionArchived, StartedAt: 200, EndedAt: 600},
+		},
+		{
+			name:      "archive a live session",
+			lifecycle: SessionLifecycle{State: SessionLive, StartedAt: 200},
+			state:     SessionArchived,
+			want:      SessionLifecycle{State: SessionArchived, StartedAt: 200, EndedAt: 1000},
+		},
+		{
+			name:      "cancel a scheduled session",
+			lifecycle: SessionLifecycle{State: SessionScheduled, ScheduledAt: 500},
+			state:     SessionEnded,
+			want:      SessionLifecycle{State: SessionEnded, ScheduledAt: 500, EndedAt: 1000},
+		},
+		{
+			name:      "restart an ended session",
+			lifecycle: SessionLifecycle{State: SessionEnded, StartedAt: 200, EndedAt: 600},
+			state:     SessionLive,
+			want:      SessionLifecycle{State: SessionEnded, StartedAt: 200, EndedAt: 600},
+			wantErr:   ErrInvalidTransition,
+		},
+		{
+			name:      "back to scheduled",
+			lifecycle: SessionLifecycle{State: SessionLive, StartedAt: 200},
+			state:     SessionScheduled,
+			want:      SessionLifecycle{State: SessionLive, StartedAt: 200},
+			wantErr:   ErrInvalidTransition,
+		},
+		{
+			name:      "end twice",
+			lifecycle: SessionLifecycle{State: SessionEnded, EndedAt: 600},
+			state:     SessionEnded,
+			want:      SessionLifecycle{State: SessionEnded, EndedAt: 600},
+			wantErr:   ErrInvalidTransition,
+		},
+		{
+			name:      "leave the archive",
+			lifecycle: SessionLifecycle{State: SessionArchived, EndedAt: 600},
+			state:     SessionLive,
+			want:      SessionLifecycle{State: SessionArchived, EndedAt: 600},
+			wantErr:   ErrInvalidTransition,
+		},
+		{
+			name:      "unknown state",
+			lifecycle: SessionLifecycle{State: SessionLive, StartedAt: 200},
+			state:     "paused",
+			want:      SessionLifecycle{State: SessionLive, StartedAt: 200},
+			wantErr:   ErrInvalidTransition,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.lifecycle.transition(tt.state, 1000)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("transition() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("transition() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionLifecycleFullRun(t *testing.T) {
+	lifecycle := SessionLifecycle{State: SessionScheduled, ScheduledAt: 100}
+	for i, state := range []string{SessionLive, SessionEnded, SessionArchived} {
+		var err error
+		if lifecycle, err = lifecycle.transition(state, int64(i+1)*1000); err != nil {
+			t.Fatalf("transition(%s) error = %v", state, err)
+		}
+	}
+
+	want := SessionLifecycle{State: SessionArchived, ScheduledAt: 100, StartedAt: 1000, EndedAt: 2000}
+	if lifecycle != want {
+		t.Errorf("lifecycle = %+v, want %+v", lifecycle, want)
+	}
+}
+
+func TestSessionLifecycleEndsAt(t *testing.T) {
+	tests := []struct {
+		name      string
+		lifecycle SessionLifecycle
+		want      int64
+	}{
+		{"with a duration", SessionLifecycle{State: SessionLive, StartedAt: 1000, DurationMs: 500}, 1500},
+		{"without a duration", SessionLifecycle{State: SessionLive, StartedAt: 1000}, 0},
+		{"not started", SessionLifecycle{State: SessionScheduled, ScheduledAt: 1000, DurationMs: 500}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.lifecycle.EndsAt(); got != tt.want {
+				t.Errorf("EndsAt() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionLifecycleReadOnly(t *testing.T) {
+	tests := []struct {
+		state string
+		want  bool
+	}{
+		{SessionScheduled, false},
+		{SessionLive, false},
+		{SessionEnded, true},
+		{SessionArchived, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.state, func(t *testing.T) {
+			if got := (SessionLifecycle{State: tt.state}).ReadOnly(); got != tt.want {
+				t.Errorf("ReadOnly() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
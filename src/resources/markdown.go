@@ -0,0 +1,26 @@
+package resources
+
+import (
+	"bytes"
+	"html"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+var (
+	markdown       = goldmark.New(goldmark.WithExtensions(extension.GFM))
+	markdownPolicy = bluemonday.UGCPolicy()
+)
+
+// RenderMarkdown renders GitHub-flavoured markdown to HTML that is safe to
+// insert into the page: raw HTML is escaped by the renderer and the result is
+// sanitized again.
+func RenderMarkdown(source string) string {
+	var rendered bytes.Buffer
+	if err := markdown.Convert([]byte(source), &rendered); err != nil {
+		return "<pre>" + html.EscapeString(source) + "</pre>"
+	}
+	return markdownPolicy.Sanitize(rendered.String())
+}
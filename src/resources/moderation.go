@@ -0,0 +1,169 @@
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrParticipantNotFound = errors.New("participant not found")
+
+// ParticipantIdentity is what a participant can be recognised by when they
+// reconnect: their address and the ID their browser keeps, if it sent one.
+type ParticipantIdentity struct {
+	IP string `json:"ip"`
+	ID string `json:"id,omitempty"`
+}
+
+func participantsKey(sessionID string) string {
+	return fmt.Sprintf("session:%s:participants", sessionID)
+}
+
+func bansKey(sessionID string) string {
+	return fmt.Sprintf("session:%s:bans", sessionID)
+}
+
+func mutedKey(sessionID string) string {
+	return fmt.Sprintf("session:%s:muted", sessionID)
+}
+
+// entries are the members of the bans and muted sets matching identity.
+func (identity ParticipantIdentity) entries() []interface{} {
+	entries := []interface{}{"ip:" + identity.IP}
+	if identity.ID != "" {
+		entries = append(entries, "id:"+identity.ID)
+	}
+	return entries
+}
+
+// RememberParticipant records who joined the session as username, so they
+// can be banned or muted later.
+func RememberParticipant(c *Cache, sessionID string, username string, identity ParticipantIdentity) error {
+	identityJSON, err := json.Marshal(identity)
+	if err != nil {
+		return err
+	}
+	key := participantsKey(sessionID)
+	_, err = c.Client.TxPipelined(c.Ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(c.Ctx, key, username, identityJSON)
+		pipe.Expire(c.Ctx, key, sessionPresenceExpiry)
+		return nil
+	})
+	return err
+}
+
+// ForgetParticipant drops what was recorded about whoever joined as username
+// before, for a participant who cannot be banned or muted.
+func ForgetParticipant(c *Cache, sessionID string, username string) error {
+	return c.Client.HDel(c.Ctx, participantsKey(sessionID), username).Err()
+}
+
+func (interview *Interview) participantIdentity(username string) (ParticipantIdentity, error) {
+	c := interview.Cache
+	identityStr, err := c.Client.HGet(c.Ctx, participantsKey(interview.SessionID), username).Result()
+	if errors.Is(err, redis.Nil) {
+		return ParticipantIdentity{}, fmt.Errorf("%w: %s", ErrParticipantNotFound, username)
+	}
+	if err != nil {
+		return ParticipantIdentity{}, err
+	}
+	var identity ParticipantIdentity
+	if err := json.Unmarshal([]byte(identityStr), &identity); err != nil {
+		return ParticipantIdentity{}, err
+	}
+	return identity, nil
+}
+
+func identityListed(c *Cache, key string, identity ParticipantIdentity) (bool, error) {
+	listed, err := c.Client.SMIsMember(c.Ctx, key, identity.entries()...).Result()
+	if err != nil {
+		return false, err
+	}
+	for _, isListed := range listed {
+		if isListed {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// IsBanned reports whether a participant with identity was banned from the
+// session.
+func IsBanned(c *Cache, sessionID string, identity ParticipantIdentity) (bool, error) {
+	return identityListed(c, bansKey(sessionID), identity)
+}
+
+// IsMuted reports whether a participant with identity was made read-only.
+func IsMuted(c *Cache, sessionID string, identity ParticipantIdentity) (bool, error) {
+	return identityListed(c, mutedKey(sessionID), identity)
+}
+
+// BanParticipant keeps whoever joined as username from joining the session
+// again, by address and by browser ID.
+func (interview *Interview) BanParticipant(username string) error {
+	identity, err := interview.participantIdentity(username)
+	if err != nil {
+		return err
+	}
+	c := interview.Cache
+	key := bansKey(interview.SessionID)
+	_, err = c.Client.TxPipelined(c.Ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(c.Ctx, key, identity.entries()...)
+		pipe.ExpireNX(c.Ctx, key, sessionTTL)
+		return nil
+	})
+	return err
+}
+
+// SetMuted makes whoever joined as username read-only, by address and by
+// browser ID so that it holds when they reconnect, or lets them edit again.
+func (interview *Interview) SetMuted(username string, muted bool) error {
+	identity, err := interview.participantIdentity(username)
+	if err != nil {
+		return err
+	}
+	c := interview.Cache
+	key := mutedKey(interview.SessionID)
+	if !muted {
+		return c.Client.SRem(c.Ctx, key, identity.entries()...).Err()
+	}
+	_, err = c.Client.TxPipelined(c.Ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(c.Ctx, key, identity.entries()...)
+		pipe.ExpireNX(c.Ctx, key, sessionTTL)
+		return nil
+	})
+	return err
+}
+
+// MutedParticipants returns which of usernames are read-only.
+func (interview *Interview) MutedParticipants(usernames []string) ([]string, error) {
+	c := interview.Cache
+	muted := make([]string, 0)
+	if len(usernames) == 0 {
+		return muted, nil
+	}
+	identities, err := c.Client.HMGet(c.Ctx, participantsKey(interview.SessionID), usernames...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, identityVal := range identities {
+		identityStr, ok := identityVal.(string)
+		if !ok {
+			continue
+		}
+		var identity ParticipantIdentity
+		if json.Unmarshal([]byte(identityStr), &identity) != nil {
+			continue
+		}
+		isMuted, err := IsMuted(c, interview.SessionID, identity)
+		if err != nil {
+			return nil, err
+		}
+		if isMuted {
+			muted = append(muted, usernames[i])
+		}
+	}
+	return muted, nil
+}
@@ -0,0 +1,44 @@
+package resources
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParticipantIdentityEntries(t *testing.T) {
+	tests := []struct {
+		name     string
+		identity ParticipantIdentity
+		want     []interface{}
+	}{
+		{
+			name:     "address only",
+			identity: ParticipantIdentity{IP: "203.0.113.7"},
+			want:     []interface{}{"ip:203.0.113.7"},
+		},
+		{
+			name:     "address and browser ID",
+			identity: ParticipantIdentity{IP: "203.0.113.7", ID: "4f1c"},
+			want:     []interface{}{"ip:203.0.113.7", "id:4f1c"},
+		},
+		{
+			name:     "IPv6 address",
+			identity: ParticipantIdentity{IP: "2001:db8::1", ID: "4f1c"},
+			want:     []interface{}{"ip:2001:db8::1", "id:4f1c"},
+		},
+		{
+			// An ID that looks like an address must not match the address.
+			name:     "ID shaped like an address",
+			identity: ParticipantIdentity{IP: "203.0.113.7", ID: "203.0.113.8"},
+			want:     []interface{}{"ip:203.0.113.7", "id:203.0.113.8"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.identity.entries(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("entries() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
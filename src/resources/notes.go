@@ -0,0 +1,173 @@
+package resources
+
+import (
+	"CodeStream/src"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DocumentNotes is the interviewers' private markdown document. Its patches
+// are kept off the timeline, which anyone with the session ID can replay.
+const DocumentNotes = "notes"
+
+// Hire recommendations of a scorecard.
+const (
+	RecommendationStrongNoHire = "strong_no_hire"
+	RecommendationNoHire       = "no_hire"
+	RecommendationHire         = "hire"
+	RecommendationStrongHire   = "strong_hire"
+)
+
+const (
+	MinRating             = 1
+	MaxRating             = 4
+	maxScorecardCriteria  = 20
+	maxScorecardNameLen   = 100
+	maxScorecardTextBytes = 4000
+)
+
+var (
+	ErrInvalidScorecard = errors.New("invalid scorecard")
+	ErrScorecardChanged = errors.New("scorecard was changed by someone else")
+)
+
+// ScorecardCriterion is one rubric criterion. Rating is 0 until rated.
+type ScorecardCriterion struct {
+	Name    string `json:"name"`
+	Rating  int    `json:"rating,omitempty"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// Scorecard is the interviewers' structured assessment of the session. Each
+// update must carry the version it was made against, so concurrent edits by
+// two interviewers do not silently overwrite each other.
+type Scorecard struct {
+	Version        int64                `json:"version"`
+	Criteria       []ScorecardCriterion `json:"criteria"`
+	Recommendation string               `json:"recommendation,omitempty"`
+	Summary        string               `json:"summary,omitempty"`
+	UpdatedBy      string               `json:"updated_by,omitempty"`
+	UpdatedAt      int64                `json:"updated_at,omitempty"`
+}
+
+// NewScorecard returns an unrated scorecard with the configured rubric.
+func NewScorecard() Scorecard {
+	criteria := make([]ScorecardCriterion, 0, len(src.Config.ScorecardRubric))
+	for _, name := range src.Config.ScorecardRubric {
+		criteria = append(criteria, ScorecardCriterion{Name: name})
+	}
+	return Scorecard{Criteria: criteria}
+}
+
+func (s Scorecard) Validate() error {
+	if len(s.Criteria) > maxScorecardCriteria {
+		return fmt.Errorf("%w: at most %d criteria", ErrInvalidScorecard, maxScorecardCriteria)
+	}
+	seen := make(map[string]bool)
+	for _, criterion := range s.Criteria {
+		if criterion.Name == "" || len(criterion.Name) > maxScorecardNameLen {
+			return fmt.Errorf("%w: criterion names must be 1 to %d bytes", ErrInvalidScorecard, maxScorecardNameLen)
+		}
+		if seen[criterion.Name] {
+			return fmt.Errorf("%w: duplicate criterion %q", ErrInvalidScorecard, criterion.Name)
+		}
+		seen[criterion.Name] = true
+		if criterion.Rating != 0 && (criterion.Rating < MinRating || criterion.Rating > MaxRating) {
+			return fmt.Errorf("%w: %q rated %d, ratings are %d to %d", ErrInvalidScorecard, criterion.Name, criterion.Rating, MinRating, MaxRating)
+		}
+		if len(criterion.Comment) > maxScorecardTextBytes {
+			return fmt.Errorf("%w: comment on %q is too long", ErrInvalidScorecard, criterion.Name)
+		}
+	}
+	switch s.Recommendation {
+	case "", RecommendationStrongNoHire, RecommendationNoHire, RecommendationHire, RecommendationStrongHire:
+	default:
+		return fmt.Errorf("%w: unknown recommendation %q", ErrInvalidScorecard, s.Recommendation)
+	}
+	if len(s.Summary) > maxScorecardTextBytes {
+		return fmt.Errorf("%w: summary is too long", ErrInvalidScorecard)
+	}
+	return nil
+}
+
+func scorecardKey(sessionID string) string {
+	return fmt.Sprintf("session:%s:scorecard", sessionID)
+}
+
+// Notes returns the interviewers' notes document, creating it empty.
+func (interview *Interview) Notes() (*Interview, error) {
+	return interview.openDocument(DocumentNotes)
+}
+
+// NotesText returns the notes, or "" if none were taken.
+func (interview *Interview) NotesText() (string, error) {
+	notes, _, err := sessionDocument(interview.Cache, interview.SessionID, DocumentNotes).CurrentCode()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return notes, err
+}
+
+// Scorecard returns the session's scorecard, unrated until first updated.
+func (interview *Interview) Scorecard() (Scorecard, error) {
+	c := interview.Cache
+	scorecardStr, err := c.Client.Get(c.Ctx, scorecardKey(interview.SessionID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return NewScorecard(), nil
+	}
+	if err != nil {
+		return Scorecard{}, err
+	}
+
+	var scorecard Scorecard
+	if err := json.Unmarshal([]byte(scorecardStr), &scorecard); err != nil {
+		return Scorecard{}, err
+	}
+	return scorecard, nil
+}
+
+// UpdateScorecard replaces the scorecard with scorecard, made by author
+// against scorecard.Version. It fails with ErrScorecardChanged if the
+// scorecard was updated since.
+func (interview *Interview) UpdateScorecard(scorecard Scorecard, author string) (Scorecard, error) {
+	if err := scorecard.Validate(); err != nil {
+		return Scorecard{}, err
+	}
+	c := interview.Cache
+	key := scorecardKey(interview.SessionID)
+
+	err := c.Client.Watch(c.Ctx, func(tx *redis.Tx) error {
+		current, err := interview.Scorecard()
+		if err != nil {
+			return err
+		}
+		if scorecard.Version != current.Version {
+			return ErrScorecardChanged
+		}
+
+		scorecard.Version++
+		scorecard.UpdatedBy = author
+		scorecard.UpdatedAt = time.Now().UnixMilli()
+		scorecardJSON, err := json.Marshal(scorecard)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(c.Ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(c.Ctx, key, scorecardJSON, redis.KeepTTL)
+			pipe.ExpireNX(c.Ctx, key, sessionTTL)
+			return nil
+		})
+		return err
+	}, key)
+	if errors.Is(err, redis.TxFailedErr) {
+		return Scorecard{}, ErrScorecardChanged
+	}
+	if err != nil {
+		return Scorecard{}, err
+	}
+	return scorecard, nil
+}
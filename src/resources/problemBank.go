@@ -0,0 +1,337 @@
+package resources
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrProblemNotFound = errors.New("problem not found")
+
+var problemDifficulties = map[string]bool{
+	"easy":   true,
+	"medium": true,
+	"hard":   true,
+}
+
+// TestCase feeds Input to the program on stdin and expects Output on stdout.
+// Hidden test cases are never shown to candidates.
+type TestCase struct {
+	Input  string `json:"input"`
+	Output string `json:"output"`
+	Hidden bool   `json:"hidden,omitempty"`
+}
+
+// Checkers compare a program's output with the expected answer.
+const (
+	CheckerExact  = "exact"  // identical, up to trailing whitespace
+	CheckerLines  = "lines"  // line by line, ignoring the spacing within lines
+	CheckerTokens = "tokens" // the same whitespace-separated tokens
+	CheckerFloat  = "float"  // tokens, with numbers within FloatTolerance
+)
+
+var problemCheckers = map[string]bool{
+	CheckerExact:  true,
+	CheckerLines:  true,
+	CheckerTokens: true,
+	CheckerFloat:  true,
+}
+
+const (
+	maxTimeLimitMs        = 10000
+	maxMemoryLimitMB      = 1024
+	defaultFloatTolerance = 1e-6
+)
+
+// ProblemJudging sets the limits solutions run under and how their output is
+// checked. Zero limits keep the runner's defaults.
+type ProblemJudging struct {
+	TimeLimitMs    int     `json:"time_limit_ms,omitempty"`
+	MemoryLimitMB  int     `json:"memory_limit_mb,omitempty"`
+	Checker        string  `json:"checker"`
+	FloatTolerance float64 `json:"float_tolerance,omitempty"`
+	IgnoreCase     bool    `json:"ignore_case,omitempty"`
+}
+
+// Problem is a reusable interview question. StarterCode is keyed by language.
+type Problem struct {
+	ID          string            `json:"id"`
+	Title       string            `json:"title"`
+	Statement   string            `json:"statement"`
+	StarterCode map[string]string `json:"starter_code"`
+	TestCases   []TestCase        `json:"test_cases"`
+	Judging     ProblemJudging    `json:"judging"`
+	Difficulty  string            `json:"difficulty"`
+	Tags        []string          `json:"tags"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+type ProblemSummary struct {
+	ID         string   `json:"id"`
+	Title      string   `json:"title"`
+	Difficulty string   `json:"difficulty"`
+	Tags       []string `json:"tags"`
+}
+
+// ProblemFilter narrows a listing to problems with the difficulty and the tag,
+// when set.
+type ProblemFilter struct {
+	Difficulty string
+	Tag        string
+}
+
+func (p *Problem) Validate() error {
+	p.Title = strings.TrimSpace(p.Title)
+	if p.Title == "" {
+		return fmt.Errorf("title is required")
+	}
+	if !problemDifficulties[p.Difficulty] {
+		return fmt.Errorf("difficulty must be easy, medium or hard")
+	}
+	for lang := range p.StarterCode {
+		if _, ok := runners[lang]; !ok {
+			return fmt.Errorf("unsupported starter code language: %s", lang)
+		}
+	}
+	if err := p.Judging.validate(); err != nil {
+		return err
+	}
+	if p.StarterCode == nil {
+		p.StarterCode = map[string]string{}
+	}
+	if p.TestCases == nil {
+		p.TestCases = []TestCase{}
+	}
+	if p.Tags == nil {
+		p.Tags = []string{}
+	}
+	return nil
+}
+
+func (j *ProblemJudging) validate() error {
+	if j.Checker == "" {
+		j.Checker = CheckerTokens
+	}
+	if !problemCheckers[j.Checker] {
+		return fmt.Errorf("checker must be exact, lines, tokens or float")
+	}
+	if j.TimeLimitMs < 0 || j.TimeLimitMs > maxTimeLimitMs {
+		return fmt.Errorf("time limit must be between 0 and %dms", maxTimeLimitMs)
+	}
+	if j.MemoryLimitMB < 0 || j.MemoryLimitMB > maxMemoryLimitMB {
+		return fmt.Errorf("memory limit must be between 0 and %dMB", maxMemoryLimitMB)
+	}
+	if j.FloatTolerance < 0 {
+		return fmt.Errorf("float tolerance must not be negative")
+	}
+	if j.Checker == CheckerFloat && j.FloatTolerance == 0 {
+		j.FloatTolerance = defaultFloatTolerance
+	}
+	return nil
+}
+
+// VisibleTestCases returns the test cases candidates may see.
+func (p *Problem) VisibleTestCases() []TestCase {
+	visible := make([]TestCase, 0, len(p.TestCases))
+	for _, testCase := range p.TestCases {
+		if !testCase.Hidden {
+			visible = append(visible, testCase)
+		}
+	}
+	return visible
+}
+
+// StarterFor picks the language a session on this problem starts in: the
+// first of languages with starter code, or the first of languages.
+func (p *Problem) StarterFor(languages []string) (string, string) {
+	for _, lang := range languages {
+		if code, ok := p.StarterCode[lang]; ok {
+			return lang, code
+		}
+	}
+	return languages[0], ""
+}
+
+type ProblemRepository interface {
+	Create(ctx context.Context, problem Problem) (Problem, error)
+	Update(ctx context.Context, problem Problem) (Problem, error)
+	Delete(ctx context.Context, id string) error
+	Get(ctx context.Context, id string) (Problem, error)
+	List(ctx context.Context, filter ProblemFilter) ([]ProblemSummary, error)
+}
+
+// Problems is nil when no database is configured.
+var Problems ProblemRepository
+
+func SetupProblemBank() {
+	if DB == nil {
+		return
+	}
+	Problems = &sqlProblemRepository{db: DB}
+}
+
+type sqlProblemRepository struct {
+	db *Database
+}
+
+type problemColumns struct {
+	starterCode string
+	testCases   string
+	judging     string
+	tags        string
+}
+
+func marshalProblemColumns(problem Problem) (problemColumns, error) {
+	starterCode, err := json.Marshal(problem.StarterCode)
+	if err != nil {
+		return problemColumns{}, err
+	}
+	testCases, err := json.Marshal(problem.TestCases)
+	if err != nil {
+		return problemColumns{}, err
+	}
+	judging, err := json.Marshal(problem.Judging)
+	if err != nil {
+		return problemColumns{}, err
+	}
+	tags, err := json.Marshal(problem.Tags)
+	if err != nil {
+		return problemColumns{}, err
+	}
+	return problemColumns{string(starterCode), string(testCases), string(judging), string(tags)}, nil
+}
+
+func (r *sqlProblemRepository) Create(ctx context.Context, problem Problem) (Problem, error) {
+	problem.ID = generateSessionID(12)
+	problem.CreatedAt = time.Now()
+	problem.UpdatedAt = problem.CreatedAt
+
+	columns, err := marshalProblemColumns(problem)
+	if err != nil {
+		return Problem{}, err
+	}
+	_, err = r.db.ExecContext(ctx, r.db.Rebind(`
+		INSERT INTO problems (id, title, statement, starter_code, test_cases, judging, difficulty, tags, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		problem.ID, problem.Title, problem.Statement, columns.starterCode, columns.testCases, columns.judging,
+		problem.Difficulty, columns.tags, problem.CreatedAt.UnixMilli(), problem.UpdatedAt.UnixMilli(),
+	)
+	if err != nil {
+		return Problem{}, err
+	}
+	return problem, nil
+}
+
+func (r *sqlProblemRepository) Update(ctx context.Context, problem Problem) (Problem, error) {
+	existing, err := r.Get(ctx, problem.ID)
+	if err != nil {
+		return Problem{}, err
+	}
+	problem.CreatedAt = existing.CreatedAt
+	problem.UpdatedAt = time.Now()
+
+	columns, err := marshalProblemColumns(problem)
+	if err != nil {
+		return Problem{}, err
+	}
+	_, err = r.db.ExecContext(ctx, r.db.Rebind(`
+		UPDATE problems SET title = ?, statement = ?, starter_code = ?, test_cases = ?, judging = ?, difficulty = ?, tags = ?, updated_at = ?
+		WHERE id = ?`),
+		problem.Title, problem.Statement, columns.starterCode, columns.testCases, columns.judging,
+		problem.Difficulty, columns.tags, problem.UpdatedAt.UnixMilli(), problem.ID,
+	)
+	if err != nil {
+		return Problem{}, err
+	}
+	return problem, nil
+}
+
+func (r *sqlProblemRepository) Delete(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, r.db.Rebind(`DELETE FROM problems WHERE id = ?`), id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrProblemNotFound
+	}
+	return nil
+}
+
+func (r *sqlProblemRepository) Get(ctx context.Context, id string) (Problem, error) {
+	var problem Problem
+	var starterCode, testCases, judging, tags string
+	var createdAt, updatedAt int64
+
+	err := r.db.QueryRowContext(ctx, r.db.Rebind(`
+		SELECT id, title, statement, starter_code, test_cases, judging, difficulty, tags, created_at, updated_at
+		FROM problems WHERE id = ?`), id,
+	).Scan(&problem.ID, &problem.Title, &problem.Statement, &starterCode, &testCases, &judging,
+		&problem.Difficulty, &tags, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Problem{}, ErrProblemNotFound
+	}
+	if err != nil {
+		return Problem{}, err
+	}
+
+	if err := json.Unmarshal([]byte(starterCode), &problem.StarterCode); err != nil {
+		return Problem{}, err
+	}
+	if err := json.Unmarshal([]byte(testCases), &problem.TestCases); err != nil {
+		return Problem{}, err
+	}
+	if err := json.Unmarshal([]byte(judging), &problem.Judging); err != nil {
+		return Problem{}, err
+	}
+	if err := json.Unmarshal([]byte(tags), &problem.Tags); err != nil {
+		return Problem{}, err
+	}
+	problem.CreatedAt = time.UnixMilli(createdAt)
+	problem.UpdatedAt = time.UnixMilli(updatedAt)
+	return problem, nil
+}
+
+// List returns the problems matching filter ordered by title. Tags are stored
+// as a JSON array, so a tag matches its quoted form in it.
+func (r *sqlProblemRepository) List(ctx context.Context, filter ProblemFilter) ([]ProblemSummary, error) {
+	query := `SELECT id, title, difficulty, tags FROM problems WHERE 1 = 1`
+	args := make([]interface{}, 0, 2)
+	if filter.Difficulty != "" {
+		query += ` AND difficulty = ?`
+		args = append(args, filter.Difficulty)
+	}
+	if filter.Tag != "" {
+		tag, err := json.Marshal(filter.Tag)
+		if err != nil {
+			return nil, err
+		}
+		query += ` AND tags LIKE ? ESCAPE '\'`
+		args = append(args, "%"+likeEscaper.Replace(string(tag))+"%")
+	}
+	query += ` ORDER BY title`
+
+	rows, err := r.db.QueryContext(ctx, r.db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := make([]ProblemSummary, 0)
+	for rows.Next() {
+		var summary ProblemSummary
+		var tags string
+		if err := rows.Scan(&summary.ID, &summary.Title, &summary.Difficulty, &tags); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(tags), &summary.Tags); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, rows.Err()
+}
@@ -0,0 +1,498 @@
+package resources
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problem package formats the importer understands.
+const (
+	PackagePolygon = "polygon"
+	PackageICPC    = "icpc"
+)
+
+// maxPackageTestBytes bounds the tests of an imported problem, which are kept
+// with every session created from it.
+const maxPackageTestBytes = 4 << 20
+
+var (
+	ErrUnknownPackage    = errors.New("not a Polygon package or ICPC problem archive")
+	ErrPackageTooLarge   = fmt.Errorf("problem tests exceed %dMB", maxPackageTestBytes>>20)
+	ErrProblemBankNotSet = errors.New("problem bank is not configured")
+)
+
+type ImportOptions struct {
+	Difficulty string
+}
+
+// ImportResult is an imported problem and what could not be carried over.
+type ImportResult struct {
+	Format   string   `json:"format"`
+	Problem  Problem  `json:"problem"`
+	Warnings []string `json:"warnings"`
+}
+
+// ImportProblemPackage parses the package in fsys and adds it to the problem
+// bank.
+func ImportProblemPackage(ctx context.Context, fsys fs.FS, options ImportOptions) (ImportResult, error) {
+	if Problems == nil {
+		return ImportResult{}, ErrProblemBankNotSet
+	}
+	result, err := ParseProblemPackage(fsys, options)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	result.Problem, err = Problems.Create(ctx, result.Problem)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	return result, nil
+}
+
+// OpenProblemPackage opens a package directory or zip file.
+func OpenProblemPackage(name string) (fs.FS, io.Closer, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		return os.DirFS(name), io.NopCloser(nil), nil
+	}
+	reader, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return reader, reader, nil
+}
+
+// ParseProblemPackage reads a Codeforces Polygon package (problem.xml) or an
+// ICPC problem archive (problem.yaml) into a problem that passes Validate.
+// Archives holding the package in a single top-level directory are accepted.
+func ParseProblemPackage(fsys fs.FS, options ImportOptions) (ImportResult, error) {
+	fsys, err := packageRoot(fsys)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	p := &packageParser{fsys: fsys, budget: maxPackageTestBytes}
+	var result ImportResult
+	if _, err := fs.Stat(fsys, "problem.xml"); err == nil {
+		result.Format = PackagePolygon
+		result.Problem, err = p.parsePolygon()
+		if err != nil {
+			return ImportResult{}, err
+		}
+	} else {
+		result.Format = PackageICPC
+		result.Problem, err = p.parseICPC()
+		if err != nil {
+			return ImportResult{}, err
+		}
+	}
+
+	result.Problem.Difficulty = options.Difficulty
+	if result.Problem.Difficulty == "" {
+		result.Problem.Difficulty = "medium"
+	}
+	if len(result.Problem.TestCases) == 0 {
+		p.warn("the package has no tests")
+	}
+	if err := result.Problem.Validate(); err != nil {
+		return ImportResult{}, err
+	}
+	result.Warnings = p.warnings
+	if result.Warnings == nil {
+		result.Warnings = []string{}
+	}
+	return result, nil
+}
+
+func packageRoot(fsys fs.FS) (fs.FS, error) {
+	for _, name := range []string{"problem.xml", "problem.yaml"} {
+		if _, err := fs.Stat(fsys, name); err == nil {
+			return fsys, nil
+		}
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != "__MACOSX" {
+			dirs = append(dirs, entry.Name())
+		}
+	}
+	if len(dirs) != 1 {
+		return nil, ErrUnknownPackage
+	}
+	sub, err := fs.Sub(fsys, dirs[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"problem.xml", "problem.yaml"} {
+		if _, err := fs.Stat(sub, name); err == nil {
+			return sub, nil
+		}
+	}
+	return nil, ErrUnknownPackage
+}
+
+type packageParser struct {
+	fsys     fs.FS
+	budget   int64
+	warnings []string
+}
+
+func (p *packageParser) warn(format string, args ...interface{}) {
+	p.warnings = append(p.warnings, fmt.Sprintf(format, args...))
+}
+
+func (p *packageParser) exists(name string) bool {
+	_, err := fs.Stat(p.fsys, name)
+	return err == nil
+}
+
+func (p *packageParser) readText(name string) (string, bool) {
+	data, err := fs.ReadFile(p.fsys, name)
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(data)), true
+}
+
+// readTest reads a test file, counting it against the package's test budget.
+func (p *packageParser) readTest(name string) (string, error) {
+	file, err := p.fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, p.budget+1))
+	if err != nil {
+		return "", err
+	}
+	p.budget -= int64(len(data))
+	if p.budget < 0 {
+		return "", ErrPackageTooLarge
+	}
+	return string(data), nil
+}
+
+// setLimits stores the package's limits, clamped to what the runner allows.
+func (p *packageParser) setLimits(judging *ProblemJudging, timeLimitMs int, memoryLimitMB int) {
+	if timeLimitMs > maxTimeLimitMs {
+		p.warn("time limit lowered from %dms to %dms", timeLimitMs, maxTimeLimitMs)
+		timeLimitMs = maxTimeLimitMs
+	}
+	if memoryLimitMB > maxMemoryLimitMB {
+		p.warn("memory limit lowered from %dMB to %dMB", memoryLimitMB, maxMemoryLimitMB)
+		memoryLimitMB = maxMemoryLimitMB
+	}
+	judging.TimeLimitMs = max(timeLimitMs, 0)
+	judging.MemoryLimitMB = max(memoryLimitMB, 0)
+}
+
+type polygonPackage struct {
+	ShortName string `xml:"short-name,attr"`
+	Names     []struct {
+		Language string `xml:"language,attr"`
+		Value    string `xml:"value,attr"`
+	} `xml:"names>name"`
+	Testsets []struct {
+		Name          string `xml:"name,attr"`
+		TimeLimit     int    `xml:"time-limit"`
+		MemoryLimit   int64  `xml:"memory-limit"`
+		InputPattern  string `xml:"input-path-pattern"`
+		AnswerPattern string `xml:"answer-path-pattern"`
+		Tests         []struct {
+			Sample bool `xml:"sample,attr"`
+		} `xml:"tests>test"`
+	} `xml:"judging>testset"`
+	Checker struct {
+		Name string `xml:"name,attr"`
+	} `xml:"assets>checker"`
+	Tags []struct {
+		Value string `xml:"value,attr"`
+	} `xml:"tags>tag"`
+}
+
+// polygonCheckers maps the standard testlib checkers to built-in ones.
+var polygonCheckers = map[string]ProblemJudging{
+	"std::fcmp.cpp":   {Checker: CheckerExact},
+	"std::lcmp.cpp":   {Checker: CheckerLines},
+	"std::wcmp.cpp":   {Checker: CheckerTokens},
+	"std::ncmp.cpp":   {Checker: CheckerTokens},
+	"std::icmp.cpp":   {Checker: CheckerTokens},
+	"std::hcmp.cpp":   {Checker: CheckerTokens},
+	"std::yesno.cpp":  {Checker: CheckerTokens, IgnoreCase: true},
+	"std::nyesno.cpp": {Checker: CheckerTokens, IgnoreCase: true},
+	"std::rcmp.cpp":   {Checker: CheckerFloat, FloatTolerance: 1.5e-6},
+	"std::rcmp4.cpp":  {Checker: CheckerFloat, FloatTolerance: 1e-4},
+	"std::rcmp6.cpp":  {Checker: CheckerFloat, FloatTolerance: 1e-6},
+	"std::rcmp9.cpp":  {Checker: CheckerFloat, FloatTolerance: 1e-9},
+	"std::dcmp.cpp":   {Checker: CheckerFloat, FloatTolerance: 1e-6},
+}
+
+func (p *packageParser) parsePolygon() (Problem, error) {
+	data, err := fs.ReadFile(p.fsys, "problem.xml")
+	if err != nil {
+		return Problem{}, err
+	}
+	var pkg polygonPackage
+	if err := xml.Unmarshal(data, &pkg); err != nil {
+		return Problem{}, fmt.Errorf("problem.xml: %w", err)
+	}
+
+	problem := Problem{Title: pkg.ShortName}
+	language := ""
+	for _, name := range pkg.Names {
+		if language == "" || name.Language == "english" {
+			language, problem.Title = name.Language, name.Value
+		}
+	}
+	problem.Statement = p.polygonStatement(language)
+	for _, tag := range pkg.Tags {
+		problem.Tags = append(problem.Tags, tag.Value)
+	}
+
+	if len(pkg.Testsets) == 0 {
+		return Problem{}, fmt.Errorf("problem.xml has no testset")
+	}
+	testset := pkg.Testsets[0]
+	for _, candidate := range pkg.Testsets {
+		if candidate.Name == "tests" {
+			testset = candidate
+		}
+	}
+	p.setLimits(&problem.Judging, testset.TimeLimit, int(math.Ceil(float64(testset.MemoryLimit)/(1<<20))))
+
+	for i, test := range testset.Tests {
+		input, err := p.readTest(fmt.Sprintf(testset.InputPattern, i+1))
+		if errors.Is(err, fs.ErrNotExist) {
+			return Problem{}, fmt.Errorf("test %d is missing; export the full package with generated tests", i+1)
+		}
+		if err != nil {
+			return Problem{}, err
+		}
+		output, err := p.readTest(fmt.Sprintf(testset.AnswerPattern, i+1))
+		if errors.Is(err, fs.ErrNotExist) {
+			return Problem{}, fmt.Errorf("answer to test %d is missing; export the full package with generated answers", i+1)
+		}
+		if err != nil {
+			return Problem{}, err
+		}
+		problem.TestCases = append(problem.TestCases, TestCase{Input: input, Output: output, Hidden: !test.Sample})
+	}
+
+	judging, ok := polygonCheckers[pkg.Checker.Name]
+	if !ok {
+		p.warn("checker %q is not supported; output is compared token by token", pkg.Checker.Name)
+		judging = ProblemJudging{Checker: CheckerTokens}
+	}
+	judging.TimeLimitMs, judging.MemoryLimitMB = problem.Judging.TimeLimitMs, problem.Judging.MemoryLimitMB
+	problem.Judging = judging
+	return problem, nil
+}
+
+// polygonStatement assembles the statement sections, written in Polygon's
+// LaTeX subset, into markdown.
+func (p *packageParser) polygonStatement(language string) string {
+	sections := map[string]string{}
+	if data, err := fs.ReadFile(p.fsys, path.Join("statements", language, "problem-properties.json")); err == nil {
+		var properties map[string]interface{}
+		if err := json.Unmarshal(data, &properties); err == nil {
+			for _, name := range []string{"legend", "input", "output", "notes"} {
+				if text, ok := properties[name].(string); ok {
+					sections[name] = strings.TrimSpace(text)
+				}
+			}
+		}
+	}
+	if len(sections) == 0 {
+		for _, name := range []string{"legend", "input", "output", "notes"} {
+			if text, ok := p.readText(path.Join("statement-sections", language, name+".tex")); ok {
+				sections[name] = text
+			}
+		}
+	}
+	if len(sections) == 0 {
+		p.warn("no statement found for language %q", language)
+		return ""
+	}
+
+	parts := []string{sections["legend"]}
+	for _, section := range []struct{ name, title string }{
+		{"input", "Input"}, {"output", "Output"}, {"notes", "Notes"},
+	} {
+		if sections[section.name] != "" {
+			parts = append(parts, "## "+section.title+"\n\n"+sections[section.name])
+		}
+	}
+	return strings.TrimSpace(strings.Join(parts, "\n\n"))
+}
+
+type icpcPackage struct {
+	Name   interface{} `yaml:"name"`
+	Limits struct {
+		Memory    int     `yaml:"memory"`
+		TimeLimit float64 `yaml:"time_limit"`
+	} `yaml:"limits"`
+	Validation     string      `yaml:"validation"`
+	ValidatorFlags string      `yaml:"validator_flags"`
+	Keywords       interface{} `yaml:"keywords"`
+}
+
+var texProblemName = regexp.MustCompile(`\\problemname\{([^}]*)\}`)
+
+func (p *packageParser) parseICPC() (Problem, error) {
+	data, err := fs.ReadFile(p.fsys, "problem.yaml")
+	if err != nil {
+		return Problem{}, err
+	}
+	var pkg icpcPackage
+	if err := yaml.Unmarshal(data, &pkg); err != nil {
+		return Problem{}, fmt.Errorf("problem.yaml: %w", err)
+	}
+
+	var problem Problem
+	problem.Title = localizedName(pkg.Name)
+	problem.Statement = p.icpcStatement()
+	if problem.Title == "" {
+		if match := texProblemName.FindStringSubmatch(problem.Statement); match != nil {
+			problem.Title = match[1]
+		}
+	}
+	switch keywords := pkg.Keywords.(type) {
+	case string:
+		problem.Tags = strings.Fields(keywords)
+	case []interface{}:
+		for _, keyword := range keywords {
+			problem.Tags = append(problem.Tags, fmt.Sprint(keyword))
+		}
+	}
+
+	timeLimitMs := int(pkg.Limits.TimeLimit * 1000)
+	if timeLimitMs == 0 {
+		if text, ok := p.readText(".timelimit"); ok {
+			seconds, _ := strconv.ParseFloat(text, 64)
+			timeLimitMs = int(seconds * 1000)
+		}
+	}
+	if timeLimitMs == 0 {
+		p.warn("no time limit given; using the runner's default")
+	}
+	p.setLimits(&problem.Judging, timeLimitMs, pkg.Limits.Memory)
+	p.icpcValidation(&problem.Judging, pkg)
+
+	for _, group := range []struct {
+		dir    string
+		hidden bool
+	}{{"data/sample", false}, {"data/secret", true}} {
+		if err := p.icpcTests(&problem, group.dir, group.hidden); err != nil {
+			return Problem{}, err
+		}
+	}
+	return problem, nil
+}
+
+// localizedName reads a name given either as a string or keyed by language.
+func localizedName(name interface{}) string {
+	switch name := name.(type) {
+	case string:
+		return name
+	case map[string]interface{}:
+		if english, ok := name["en"].(string); ok {
+			return english
+		}
+		for _, value := range name {
+			if text, ok := value.(string); ok {
+				return text
+			}
+		}
+	}
+	return ""
+}
+
+func (p *packageParser) icpcStatement() string {
+	for _, dir := range []string{"statement", "problem_statement"} {
+		for _, name := range []string{"problem.en.md", "problem.md", "problem.en.tex", "problem.tex"} {
+			if text, ok := p.readText(path.Join(dir, name)); ok {
+				return text
+			}
+		}
+	}
+	p.warn("no English statement found")
+	return ""
+}
+
+// icpcValidation follows the default output validator: case and spacing are
+// ignored unless its flags say otherwise.
+func (p *packageParser) icpcValidation(judging *ProblemJudging, pkg icpcPackage) {
+	judging.Checker = CheckerTokens
+	judging.IgnoreCase = true
+	if (pkg.Validation != "" && pkg.Validation != "default") || p.exists("output_validators") {
+		p.warn("custom output validators are not supported; output is compared token by token")
+	}
+
+	flags := strings.Fields(pkg.ValidatorFlags)
+	for i := 0; i < len(flags); i++ {
+		switch flags[i] {
+		case "case_sensitive":
+			judging.IgnoreCase = false
+		case "space_change_sensitive":
+			judging.Checker = CheckerExact
+		case "float_tolerance", "float_relative_tolerance", "float_absolute_tolerance":
+			if i+1 < len(flags) {
+				tolerance, err := strconv.ParseFloat(flags[i+1], 64)
+				if err == nil {
+					judging.Checker, judging.FloatTolerance = CheckerFloat, tolerance
+				}
+				i++
+			}
+		}
+	}
+}
+
+// icpcTests adds the .in and .ans pairs under dir, in lexical order.
+func (p *packageParser) icpcTests(problem *Problem, dir string, hidden bool) error {
+	if !p.exists(dir) {
+		return nil
+	}
+	return fs.WalkDir(p.fsys, dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || path.Ext(name) != ".in" {
+			return err
+		}
+		answer := strings.TrimSuffix(name, ".in") + ".ans"
+		if !p.exists(answer) {
+			p.warn("%s has no answer and was skipped", name)
+			return nil
+		}
+		input, err := p.readTest(name)
+		if err != nil {
+			return err
+		}
+		output, err := p.readTest(answer)
+		if err != nil {
+			return err
+		}
+		problem.TestCases = append(problem.TestCases, TestCase{Input: input, Output: output, Hidden: hidden})
+		return nil
+	})
+}
@@ -0,0 +1,267 @@
+package resources
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const polygonProblemXML = `<?xml version="1.0" encoding="utf-8"?>
+<problem short-name="a-plus-b">
+  <names>
+    <name language="russian" value="А плюс Б"/>
+    <name language="english" value="A plus B"/>
+  </names>
+  <judging>
+    <testset name="tests">
+      <time-limit>2000</time-limit>
+      <memory-limit>268435456</memory-limit>
+      <input-path-pattern>tests/%02d</input-path-pattern>
+      <answer-path-pattern>tests/%02d.a</answer-path-pattern>
+      <tests>
+        <test sample="true"/>
+        <test/>
+      </tests>
+    </testset>
+  </judging>
+  <assets>
+    <checker name="CHECKER"/>
+  </assets>
+  <tags>
+    <tag value="math"/>
+    <tag value="implementation"/>
+  </tags>
+</problem>`
+
+func polygonPackageFS(checker string) fstest.MapFS {
+	return fstest.MapFS{
+		"problem.xml":                           {Data: []byte(strings.Replace(polygonProblemXML, "CHECKER", checker, 1))},
+		"statement-sections/english/legend.tex": {Data: []byte("Add two numbers.\n")},
+		"statement-sections/english/input.tex":  {Data: []byte("Two integers.")},
+		"tests/01":                              {Data: []byte("1 2\n")},
+		"tests/01.a":                            {Data: []byte("3\n")},
+		"tests/02":                              {Data: []byte("5 7\n")},
+		"tests/02.a":                            {Data: []byte("12\n")},
+	}
+}
+
+func TestParsePolygonPackage(t *testing.T) {
+	tests := []struct {
+		name         string
+		fsys         fstest.MapFS
+		wantJudging  ProblemJudging
+		wantWarnings []string
+		wantErr      string
+	}{
+		{
+			name:         "standard checker",
+			fsys:         polygonPackageFS("std::rcmp6.cpp"),
+			wantJudging:  ProblemJudging{TimeLimitMs: 2000, MemoryLimitMB: 256, Checker: CheckerFloat, FloatTolerance: 1e-6},
+			wantWarnings: []string{},
+		},
+		{
+			name:         "yes or no checker",
+			fsys:         polygonPackageFS("std::yesno.cpp"),
+			wantJudging:  ProblemJudging{TimeLimitMs: 2000, MemoryLimitMB: 256, Checker: CheckerTokens, IgnoreCase: true},
+			wantWarnings: []string{},
+		},
+		{
+			name:         "custom checker",
+			fsys:         polygonPackageFS("files/check.cpp"),
+			wantJudging:  ProblemJudging{TimeLimitMs: 2000, MemoryLimitMB: 256, Checker: CheckerTokens},
+			wantWarnings: []string{`checker "files/check.cpp" is not supported; output is compared token by token`},
+		},
+		{
+			name: "missing generated test",
+			fsys: func() fstest.MapFS {
+				fsys := polygonPackageFS("std::wcmp.cpp")
+				delete(fsys, "tests/02")
+				return fsys
+			}(),
+			wantErr: "test 2 is missing",
+		},
+		{
+			name: "limits over the runner's",
+			fsys: func() fstest.MapFS {
+				fsys := polygonPackageFS("std::wcmp.cpp")
+				xml := strings.Replace(string(fsys["problem.xml"].Data), "<time-limit>2000", "<time-limit>15000", 1)
+				fsys["problem.xml"] = &fstest.MapFile{Data: []byte(xml)}
+				return fsys
+			}(),
+			wantJudging:  ProblemJudging{TimeLimitMs: maxTimeLimitMs, MemoryLimitMB: 256, Checker: CheckerTokens},
+			wantWarnings: []string{"time limit lowered from 15000ms to 10000ms"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseProblemPackage(tt.fsys, ImportOptions{Difficulty: "easy"})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseProblemPackage() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseProblemPackage() error = %v", err)
+			}
+
+			problem := result.Problem
+			if result.Format != PackagePolygon || problem.Title != "A plus B" || problem.Difficulty != "easy" {
+				t.Errorf("got format %q, title %q, difficulty %q", result.Format, problem.Title, problem.Difficulty)
+			}
+			if want := "Add two numbers.\n\n## Input\n\nTwo integers."; problem.Statement != want {
+				t.Errorf("statement = %q, want %q", problem.Statement, want)
+			}
+			if !reflect.DeepEqual(problem.Tags, []string{"math", "implementation"}) {
+				t.Errorf("tags = %v", problem.Tags)
+			}
+			wantTests := []TestCase{
+				{Input: "1 2\n", Output: "3\n"},
+				{Input: "5 7\n", Output: "12\n", Hidden: true},
+			}
+			if !reflect.DeepEqual(problem.TestCases, wantTests) {
+				t.Errorf("tests = %+v, want %+v", problem.TestCases, wantTests)
+			}
+			if problem.Judging != tt.wantJudging {
+				t.Errorf("judging = %+v, want %+v", problem.Judging, tt.wantJudging)
+			}
+			if !reflect.DeepEqual(result.Warnings, tt.wantWarnings) {
+				t.Errorf("warnings = %q, want %q", result.Warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestParseICPCPackage(t *testing.T) {
+	tests := []struct {
+		name         string
+		fsys         fstest.MapFS
+		wantTitle    string
+		wantTags     []string
+		wantJudging  ProblemJudging
+		wantTests    []TestCase
+		wantWarnings []string
+	}{
+		{
+			name: "default validator",
+			fsys: fstest.MapFS{
+				"problem.yaml":                {Data: []byte("name: Hello\nlimits:\n  time_limit: 1.5\n  memory: 512\nkeywords: [strings, easy]\n")},
+				"statement/problem.en.md":     {Data: []byte("Say hello.")},
+				"data/sample/1.in":            {Data: []byte("")},
+				"data/sample/1.ans":           {Data: []byte("hello\n")},
+				"data/secret/group1/01.in":    {Data: []byte("x")},
+				"data/secret/group1/01.ans":   {Data: []byte("hello\n")},
+				"data/secret/group1/02.in":    {Data: []byte("y")},
+				"data/secret/group1/02.files": {Data: []byte("unrelated")},
+			},
+			wantTitle:    "Hello",
+			wantTags:     []string{"strings", "easy"},
+			wantJudging:  ProblemJudging{TimeLimitMs: 1500, MemoryLimitMB: 512, Checker: CheckerTokens, IgnoreCase: true},
+			wantTests:    []TestCase{{Output: "hello\n"}, {Input: "x", Output: "hello\n", Hidden: true}},
+			wantWarnings: []string{"data/secret/group1/02.in has no answer and was skipped"},
+		},
+		{
+			name: "validator flags in a top-level directory",
+			fsys: fstest.MapFS{
+				"hello/problem.yaml": {Data: []byte(
+					"name: {de: Hallo, en: Hello}\nkeywords: geometry floats\n" +
+						"validator_flags: case_sensitive float_tolerance 1e-4\n")},
+				"hello/.timelimit":                    {Data: []byte("2\n")},
+				"hello/problem_statement/problem.tex": {Data: []byte(`\problemname{Hello}`)},
+				"hello/data/secret/1.in":              {Data: []byte("1")},
+				"hello/data/secret/1.ans":             {Data: []byte("1.0")},
+				"__MACOSX/hello/problem.yaml":         {Data: []byte("")},
+			},
+			wantTitle:    "Hello",
+			wantTags:     []string{"geometry", "floats"},
+			wantJudging:  ProblemJudging{TimeLimitMs: 2000, Checker: CheckerFloat, FloatTolerance: 1e-4},
+			wantTests:    []TestCase{{Input: "1", Output: "1.0", Hidden: true}},
+			wantWarnings: []string{},
+		},
+		{
+			name: "custom validator without limits",
+			fsys: fstest.MapFS{
+				"problem.yaml":                     {Data: []byte("validation: custom\nvalidator_flags: space_change_sensitive\n")},
+				"problem_statement/problem.tex":    {Data: []byte(`\problemname{Guess}` + "\nGuess the number.")},
+				"output_validators/check/check.py": {Data: []byte("")},
+			},
+			wantTitle:   "Guess",
+			wantTags:    []string{},
+			wantJudging: ProblemJudging{Checker: CheckerExact, IgnoreCase: true},
+			wantTests:   []TestCase{},
+			wantWarnings: []string{
+				"no time limit given; using the runner's default",
+				"custom output validators are not supported; output is compared token by token",
+				"the package has no tests",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseProblemPackage(tt.fsys, ImportOptions{})
+			if err != nil {
+				t.Fatalf("ParseProblemPackage() error = %v", err)
+			}
+
+			problem := result.Problem
+			if result.Format != PackageICPC || problem.Title != tt.wantTitle || problem.Difficulty != "medium" {
+				t.Errorf("got format %q, title %q, difficulty %q", result.Format, problem.Title, problem.Difficulty)
+			}
+			if !reflect.DeepEqual(problem.Tags, tt.wantTags) {
+				t.Errorf("tags = %q, want %q", problem.Tags, tt.wantTags)
+			}
+			if problem.Judging != tt.wantJudging {
+				t.Errorf("judging = %+v, want %+v", problem.Judging, tt.wantJudging)
+			}
+			if !reflect.DeepEqual(problem.TestCases, tt.wantTests) {
+				t.Errorf("tests = %+v, want %+v", problem.TestCases, tt.wantTests)
+			}
+			if !reflect.DeepEqual(result.Warnings, tt.wantWarnings) {
+				t.Errorf("warnings = %q, want %q", result.Warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestParseProblemPackageErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		wantErr error
+	}{
+		{
+			name:    "no problem file",
+			fsys:    fstest.MapFS{"README.md": {Data: []byte("hi")}},
+			wantErr: ErrUnknownPackage,
+		},
+		{
+			name: "several top-level directories",
+			fsys: fstest.MapFS{
+				"a/problem.yaml": {Data: []byte("name: A")},
+				"b/problem.yaml": {Data: []byte("name: B")},
+			},
+			wantErr: ErrUnknownPackage,
+		},
+		{
+			name: "tests over the budget",
+			fsys: fstest.MapFS{
+				"problem.yaml":      {Data: []byte("name: Big")},
+				"data/secret/1.in":  {Data: []byte(strings.Repeat("1", maxPackageTestBytes))},
+				"data/secret/1.ans": {Data: []byte("1")},
+			},
+			wantErr: ErrPackageTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseProblemPackage(tt.fsys, ImportOptions{}); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ParseProblemPackage() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package resources
+
+import (
+	"CodeStream/src"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// PatchSourceQuestion marks the patch that replaces the code when the
+// question is switched.
+const PatchSourceQuestion = "question"
+
+var (
+	ErrNoQuestions      = errors.New("the session has no questions")
+	ErrQuestionNotFound = errors.New("question not found")
+	ErrQuestionChanged  = errors.New("the question was switched by someone else")
+)
+
+// SessionQuestion is one question of a session. Its document is the code the
+// candidate left it with and its statement the one the interviewers left, or
+// the problem's own until it is first left.
+type SessionQuestion struct {
+	Problem   Problem `json:"problem"`
+	Language  string  `json:"lang,omitempty"`
+	Code      string  `json:"code,omitempty"`
+	Version   int64   `json:"version,omitempty"`
+	Statement string  `json:"statement,omitempty"`
+}
+
+// SessionQuestions is the ordered list of questions of a session and the one
+// everyone is working on.
+type SessionQuestions struct {
+	Active    int               `json:"active"`
+	Questions []SessionQuestion `json:"questions"`
+}
+
+type QuestionEventData struct {
+	From     int    `json:"from"`
+	To       int    `json:"to"`
+	Language string `json:"lang"`
+	Code     string `json:"code"`
+	Version  int64  `json:"version"`
+}
+
+func questionsKey(sessionID string) string {
+	return fmt.Sprintf("session:%s:questions", sessionID)
+}
+
+func newSessionQuestions(problems []Problem) SessionQuestions {
+	questions := SessionQuestions{Questions: make([]SessionQuestion, len(problems))}
+	for i, problem := range problems {
+		questions.Questions[i] = SessionQuestion{Problem: problem}
+	}
+	return questions
+}
+
+// Document returns the language and code the question opens with.
+func (q SessionQuestion) Document() (string, string) {
+	if q.Version > 0 {
+		return q.Language, q.Code
+	}
+	return q.Problem.StarterFor(src.Config.Languages)
+}
+
+// StatementDocument returns the statement the question opens with.
+func (q SessionQuestion) StatementDocument() string {
+	if q.Version > 0 {
+		return q.Statement
+	}
+	return q.Problem.Statement
+}
+
+// Questions returns the session's questions, or ErrNoQuestions.
+func (interview *Interview) Questions() (SessionQuestions, error) {
+	c := interview.Cache
+	questionsStr, err := c.Client.Get(c.Ctx, questionsKey(interview.SessionID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return SessionQuestions{}, ErrNoQuestions
+	}
+	if err != nil {
+		return SessionQuestions{}, err
+	}
+
+	var questions SessionQuestions
+	if err := json.Unmarshal([]byte(questionsStr), &questions); err != nil {
+		return SessionQuestions{}, err
+	}
+	return questions, nil
+}
+
+// Problem returns the problem of the active question, or nil.
+func (interview *Interview) Problem() (*Problem, error) {
+	questions, err := interview.Questions()
+	if errors.Is(err, ErrNoQuestions) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &questions.Questions[questions.Active].Problem, nil
+}
+
+// switchTo keeps the language, code and statement the active question, from,
+// was left with and makes to the active question.
+func (q *SessionQuestions) switchTo(from int, to int, language string, code string, version int64, statement string) error {
+	if q.Active != from {
+		return ErrQuestionChanged
+	}
+	if to < 0 || to >= len(q.Questions) {
+		return ErrQuestionNotFound
+	}
+
+	left := &q.Questions[from]
+	left.Language, left.Code, left.Version = language, code, version
+	left.Statement = statement
+	q.Active = to
+	return nil
+}
+
+// SwitchQuestion keeps the language and code the active question, from, was
+// left with at version, and its statement, and makes to the active question. It fails with
+// ErrQuestionChanged if from is no longer active.
+func (interview *Interview) SwitchQuestion(from int, to int, language string, code string, version int64, statement string, author string) (SessionQuestions, error) {
+	c := interview.Cache
+	key := questionsKey(interview.SessionID)
+
+	var questions SessionQuestions
+	err := c.Client.Watch(c.Ctx, func(tx *redis.Tx) error {
+		var err error
+		questions, err = interview.Questions()
+		if err != nil {
+			return err
+		}
+		if err := questions.switchTo(from, to, language, code, version, statement); err != nil {
+			return err
+		}
+
+		questionsJSON, err := json.Marshal(questions)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(c.Ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(c.Ctx, key, questionsJSON, redis.KeepTTL)
+			return appendTimelineEvent(c, pipe, interview.TimelineKey, TimelineQuestion, author, QuestionEventData{
+				From:     from,
+				To:       to,
+				Language: language,
+				Code:     code,
+				Version:  version,
+			})
+		})
+		return err
+	}, key)
+	if err != nil {
+		return SessionQuestions{}, err
+	}
+	return questions, nil
+}
+
+// RevertQuestionSwitch makes from the active question again after switching
+// from it to to failed to replace the code. The code kept for from is left
+// alone: it is what the session's document still holds.
+func (interview *Interview) RevertQuestionSwitch(from int, to int, author string) error {
+	c := interview.Cache
+	key := questionsKey(interview.SessionID)
+
+	return c.Client.Watch(c.Ctx, func(tx *redis.Tx) error {
+		questions, err := interview.Questions()
+		if err != nil {
+			return err
+		}
+		if questions.Active != to {
+			return ErrQuestionChanged
+		}
+		if from < 0 || from >= len(questions.Questions) {
+			return ErrQuestionNotFound
+		}
+		questions.Active = from
+		left := questions.Questions[to]
+
+		questionsJSON, err := json.Marshal(questions)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(c.Ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(c.Ctx, key, questionsJSON, redis.KeepTTL)
+			return appendTimelineEvent(c, pipe, interview.TimelineKey, TimelineQuestion, author, QuestionEventData{
+				From:     to,
+				To:       from,
+				Language: left.Language,
+				Code:     left.Code,
+				Version:  left.Version,
+			})
+		})
+		return err
+	}, key)
+}
+
+// FinalQuestions returns the questions with the active one holding the
+// session's current document, as the interview stands.
+func (interview *Interview) FinalQuestions() ([]SessionQuestion, error) {
+	questions, err := interview.Questions()
+	if errors.Is(err, ErrNoQuestions) {
+		return []SessionQuestion{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := interview.CodeAt(interview.Version)
+	if err != nil {
+		return nil, err
+	}
+	active := &questions.Questions[questions.Active]
+	active.Language, active.Code, active.Version = interview.Language, code, interview.Version
+	return questions.Questions, nil
+}
+
+func storeSessionQuestions(c *Cache, pipe redis.Pipeliner, sessionID string, problems []Problem) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	questionsJSON, err := json.Marshal(newSessionQuestions(problems))
+	if err != nil {
+		return err
+	}
+	pipe.Set(c.Ctx, questionsKey(sessionID), questionsJSON, time.Hour*24)
+	return nil
+}
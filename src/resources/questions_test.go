@@ -0,0 +1,46 @@
+package resources
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSessionQuestionsSwitchStatement(t *testing.T) {
+	questions := newSessionQuestions([]Problem{
+		{Title: "Two sum", Statement: "Find two numbers."},
+		{Title: "Reverse", Statement: "Reverse a list."},
+	})
+
+	steps := []struct {
+		name          string
+		from          int
+		to            int
+		leftStatement string
+		wantStatement string
+		wantErr       error
+	}{
+		{"to the second question", 0, 1, "Find two numbers.\n\nHint: use a map.", "Reverse a list.", nil},
+		{"back with the edited statement", 1, 0, "Reverse a list.", "Find two numbers.\n\nHint: use a map.", nil},
+		{"to the second question again", 0, 1, "", "Reverse a list.", nil},
+		{"back to a cleared statement", 1, 0, "Reverse a list in place.", "", nil},
+		{"from a question that is not active", 1, 0, "", "", ErrQuestionChanged},
+		{"to a question that does not exist", 0, 2, "", "", ErrQuestionNotFound},
+	}
+
+	for _, step := range steps {
+		err := questions.switchTo(step.from, step.to, "python", "", 2, step.leftStatement)
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("%s: switchTo() error = %v, want %v", step.name, err, step.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if got := questions.Questions[questions.Active].StatementDocument(); got != step.wantStatement {
+			t.Errorf("%s: statement = %q, want %q", step.name, got, step.wantStatement)
+		}
+	}
+
+	if got := questions.Questions[1].StatementDocument(); got != "Reverse a list in place." {
+		t.Errorf("second question statement = %q, want the one it was left with", got)
+	}
+}
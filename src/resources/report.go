@@ -0,0 +1,228 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// reportIdleGap is the shortest stretch without edits, runs or submissions
+// that a report counts as an idle period.
+const reportIdleGap = 2 * time.Minute
+
+// SessionReport summarises an interview for the debrief.
+type SessionReport struct {
+	SessionID    string
+	GeneratedAt  time.Time
+	State        string
+	Participants []string
+	Questions    []ReportQuestion
+	Runs         []ReportRun
+	Stats        ReportStats
+	Notes        string
+	Scorecard    Scorecard
+}
+
+// ReportQuestion is the final code of a question with the verdict of its last
+// submission and the code it resembles. A session without questions has a
+// single one, untitled.
+type ReportQuestion struct {
+	ProblemID  string
+	Title      string
+	Language   string
+	Code       string
+	Verdict    string
+	Similarity []SimilarityMatch
+}
+
+// ReportRun is a run or a submission, under Question, an index into the
+// report's questions.
+type ReportRun struct {
+	At       time.Time
+	Author   string
+	Question int
+	Submit   bool
+	Language string
+	Outcome  string
+	TimeMs   int
+}
+
+type ReportStats struct {
+	StartedAt   time.Time
+	EndedAt     time.Time
+	Duration    time.Duration
+	FirstRun    time.Duration
+	Runs        int
+	Submissions int
+	Edits       int
+	IdlePeriods int
+	IdleTime    time.Duration
+	LongestIdle time.Duration
+}
+
+// LoadSessionReport reports on the session as it is now, or on its archive
+// once its Redis keys are gone, and checks its code for similarity. It fails
+// with ErrArchiveNotFound when neither exists.
+func LoadSessionReport(ctx context.Context, c *Cache, sessionID string) (SessionReport, error) {
+	var archived ArchivedInterview
+	if interview, err := GetInterviewSession(c, sessionID); err == nil {
+		if archived, err = interview.Snapshot(""); err != nil {
+			return SessionReport{}, err
+		}
+	} else {
+		if Archive == nil {
+			return SessionReport{}, ErrArchiveNotFound
+		}
+		if archived, err = Archive.Get(ctx, sessionID); err != nil {
+			return SessionReport{}, err
+		}
+	}
+	report := NewSessionReport(archived)
+	report.CheckSimilarity(ctx)
+	return report, nil
+}
+
+// NewSessionReport walks the interview's timeline to attribute runs and
+// submissions to questions and measure how the time was spent.
+func NewSessionReport(archived ArchivedInterview) SessionReport {
+	report := SessionReport{
+		SessionID:    archived.SessionID,
+		GeneratedAt:  time.Now(),
+		State:        SessionLive,
+		Participants: archived.Participants,
+		Questions:    make([]ReportQuestion, 0, len(archived.Questions)),
+		Runs:         make([]ReportRun, 0),
+		Notes:        archived.Notes,
+		Scorecard:    archived.Scorecard,
+	}
+	for _, question := range archived.Questions {
+		report.Questions = append(report.Questions, ReportQuestion{
+			ProblemID: question.ProblemID,
+			Title:     question.Title,
+			Language:  question.Language,
+			Code:      question.Code,
+		})
+	}
+	if len(report.Questions) == 0 {
+		report.Questions = append(report.Questions, ReportQuestion{Language: archived.Language, Code: archived.Code})
+	}
+
+	stats := &report.Stats
+	question := 0
+	var lastActivity time.Time
+	activity := func(at time.Time) {
+		if stats.StartedAt.IsZero() || at.Before(stats.StartedAt) {
+			return
+		}
+		stats.recordIdle(at.Sub(lastActivity))
+		lastActivity = at
+	}
+
+	for _, event := range archived.Timeline {
+		at := time.UnixMilli(event.At)
+		switch event.Type {
+		case TimelineCreated:
+			stats.StartedAt, lastActivity = at, at
+		case TimelineState:
+			var lifecycle SessionLifecycle
+			if json.Unmarshal(event.Data, &lifecycle) != nil {
+				continue
+			}
+			report.State = lifecycle.State
+			switch {
+			case lifecycle.State == SessionLive:
+				stats.StartedAt, lastActivity = at, at
+			case lifecycle.ReadOnly() && stats.EndedAt.IsZero():
+				stats.EndedAt = at
+			}
+		case TimelinePatch:
+			if event.Author == "" {
+				continue
+			}
+			stats.Edits++
+			activity(at)
+		case TimelineQuestion:
+			var data QuestionEventData
+			if json.Unmarshal(event.Data, &data) == nil && data.To < len(report.Questions) {
+				question = data.To
+			}
+			activity(at)
+		case TimelineRun:
+			var data RunEventData
+			if json.Unmarshal(event.Data, &data) != nil || data.Result == nil {
+				continue
+			}
+			stats.Runs++
+			if stats.Runs == 1 && !stats.StartedAt.IsZero() {
+				stats.FirstRun = at.Sub(stats.StartedAt)
+			}
+			report.Runs = append(report.Runs, ReportRun{
+				At:       at,
+				Author:   event.Author,
+				Question: question,
+				Language: data.Language,
+				Outcome:  runOutcome(data.Result),
+				TimeMs:   data.Result.TimeMs,
+			})
+			activity(at)
+		case TimelineJudge:
+			var data JudgeEventData
+			if json.Unmarshal(event.Data, &data) != nil || data.Result == nil {
+				continue
+			}
+			stats.Submissions++
+			report.Questions[question].Verdict = data.Result.Verdict
+			report.Runs = append(report.Runs, ReportRun{
+				At:       at,
+				Author:   event.Author,
+				Question: question,
+				Submit:   true,
+				Language: data.Language,
+				Outcome:  data.Result.Verdict,
+			})
+			activity(at)
+		}
+	}
+
+	if stats.EndedAt.IsZero() {
+		stats.EndedAt = report.GeneratedAt
+	}
+	if !stats.StartedAt.IsZero() {
+		stats.Duration = stats.EndedAt.Sub(stats.StartedAt)
+		stats.recordIdle(stats.EndedAt.Sub(lastActivity))
+	}
+	return report
+}
+
+func (s *ReportStats) recordIdle(gap time.Duration) {
+	if gap < reportIdleGap {
+		return
+	}
+	s.IdlePeriods++
+	s.IdleTime += gap
+	s.LongestIdle = max(s.LongestIdle, gap)
+}
+
+// QuestionTitle names the question at index for a report.
+func (r SessionReport) QuestionTitle(index int) string {
+	if r.Questions[index].Title != "" {
+		return r.Questions[index].Title
+	}
+	if len(r.Questions) == 1 {
+		return "Code"
+	}
+	return "Question " + strconv.Itoa(index+1)
+}
+
+// runOutcome describes how a run ended.
+func runOutcome(resp *RunResponse) string {
+	switch {
+	case resp.Error != "":
+		return resp.Error
+	case resp.ExitCode != 0:
+		return "exit code " + strconv.Itoa(resp.ExitCode)
+	default:
+		return "ok"
+	}
+}
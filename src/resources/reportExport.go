@@ -0,0 +1,229 @@
+package resources
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Report formats.
+const (
+	ReportHTML     = "html"
+	ReportMarkdown = "md"
+	ReportPDF      = "pdf"
+)
+
+var recommendationLabels = map[string]string{
+	RecommendationStrongNoHire: "Strong no hire",
+	RecommendationNoHire:       "No hire",
+	RecommendationHire:         "Hire",
+	RecommendationStrongHire:   "Strong hire",
+}
+
+// ReportFact is a labelled line of a report's summary.
+type ReportFact struct {
+	Label string
+	Value string
+}
+
+// Facts returns the summary of the session shared by every format.
+func (r SessionReport) Facts() []ReportFact {
+	stats := r.Stats
+	participants := strings.Join(r.Participants, ", ")
+	if participants == "" {
+		participants = "none"
+	}
+	firstRun := "no runs"
+	if stats.Runs > 0 {
+		firstRun = formatReportDuration(stats.FirstRun)
+	}
+	idle := "none"
+	if stats.IdlePeriods > 0 {
+		idle = fmt.Sprintf("%d, %s in total, longest %s",
+			stats.IdlePeriods, formatReportDuration(stats.IdleTime), formatReportDuration(stats.LongestIdle))
+	}
+
+	facts := []ReportFact{
+		{"State", r.State},
+		{"Participants", participants},
+	}
+	if !stats.StartedAt.IsZero() {
+		facts = append(facts,
+			ReportFact{"Started", stats.StartedAt.UTC().Format(time.RFC1123)},
+			ReportFact{"Duration", formatReportDuration(stats.Duration)},
+		)
+	}
+	return append(facts,
+		ReportFact{"Time to first run", firstRun},
+		ReportFact{"Runs", strconv.Itoa(stats.Runs)},
+		ReportFact{"Submissions", strconv.Itoa(stats.Submissions)},
+		ReportFact{"Edits", strconv.Itoa(stats.Edits)},
+		ReportFact{"Idle periods (" + formatReportDuration(reportIdleGap) + "+)", idle},
+	)
+}
+
+// Ratings returns the scorecard's criteria and recommendation as facts, or
+// nothing if the scorecard was never filled in.
+func (r SessionReport) Ratings() []ReportFact {
+	if r.Scorecard.Version == 0 {
+		return nil
+	}
+	facts := make([]ReportFact, 0, len(r.Scorecard.Criteria)+1)
+	for _, criterion := range r.Scorecard.Criteria {
+		rating := "not rated"
+		if criterion.Rating != 0 {
+			rating = fmt.Sprintf("%d/%d", criterion.Rating, MaxRating)
+		}
+		if criterion.Comment != "" {
+			rating += " - " + criterion.Comment
+		}
+		facts = append(facts, ReportFact{criterion.Name, rating})
+	}
+	if label, ok := recommendationLabels[r.Scorecard.Recommendation]; ok {
+		facts = append(facts, ReportFact{"Recommendation", label})
+	}
+	return facts
+}
+
+// RunLabel describes a run or submission in one line.
+func (r SessionReport) RunLabel(run ReportRun) string {
+	kind := "Run"
+	if run.Submit {
+		kind = "Submission"
+	}
+	label := fmt.Sprintf("%s %s by %s (%s, %s): %s",
+		run.At.UTC().Format("15:04:05"), kind, run.Author, r.QuestionTitle(run.Question), run.Language, run.Outcome)
+	if run.TimeMs > 0 {
+		label += fmt.Sprintf(" in %d ms", run.TimeMs)
+	}
+	return label
+}
+
+func formatReportDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
+
+// Markdown renders the report as a Markdown document.
+func (r SessionReport) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Interview report: %s\n\n", r.SessionID)
+	for _, fact := range r.Facts() {
+		fmt.Fprintf(&b, "- **%s:** %s\n", fact.Label, fact.Value)
+	}
+
+	for i, question := range r.Questions {
+		fmt.Fprintf(&b, "\n## %s\n\n", r.QuestionTitle(i))
+		if question.Verdict != "" {
+			fmt.Fprintf(&b, "Verdict: **%s**\n\n", question.Verdict)
+		}
+		for _, match := range question.Similarity {
+			fmt.Fprintf(&b, "- Similarity: %s\n", question.SimilarityLabel(match))
+		}
+		if len(question.Similarity) > 0 {
+			b.WriteString("\n")
+		}
+		fence := "```"
+		for strings.Contains(question.Code, fence) {
+			fence += "`"
+		}
+		fmt.Fprintf(&b, "%s%s\n%s\n%s\n", fence, question.Language, strings.TrimRight(question.Code, "\n"), fence)
+	}
+
+	if len(r.Runs) > 0 {
+		b.WriteString("\n## Runs\n\n")
+		for _, run := range r.Runs {
+			fmt.Fprintf(&b, "- %s\n", r.RunLabel(run))
+		}
+	}
+	if ratings := r.Ratings(); len(ratings) > 0 {
+		b.WriteString("\n## Scorecard\n\n")
+		for _, fact := range ratings {
+			fmt.Fprintf(&b, "- **%s:** %s\n", fact.Label, fact.Value)
+		}
+		if r.Scorecard.Summary != "" {
+			fmt.Fprintf(&b, "\n%s\n", r.Scorecard.Summary)
+		}
+	}
+	if r.Notes != "" {
+		fmt.Fprintf(&b, "\n## Interviewer notes\n\n%s\n", strings.TrimRight(r.Notes, "\n"))
+	}
+	return b.String()
+}
+
+// WritePDF renders the report as a PDF document. The core fonts only cover
+// Windows-1252, so other characters are lost.
+func (r SessionReport) WritePDF(w io.Writer) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle("Interview report: "+r.SessionID, true)
+	pdf.SetCreator("CodeStream", true)
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	heading := func(size float64, text string) {
+		pdf.SetFont("Helvetica", "B", size)
+		pdf.MultiCell(0, size/2, tr(text), "", "L", false)
+		pdf.Ln(2)
+	}
+	facts := func(facts []ReportFact) {
+		for _, fact := range facts {
+			pdf.SetFont("Helvetica", "B", 10)
+			pdf.CellFormat(55, 5, tr(fact.Label), "", 0, "L", false, 0, "")
+			pdf.SetFont("Helvetica", "", 10)
+			pdf.MultiCell(0, 5, tr(fact.Value), "", "L", false)
+		}
+		pdf.Ln(4)
+	}
+	text := func(text string) {
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.MultiCell(0, 5, tr(text), "", "L", false)
+		pdf.Ln(4)
+	}
+
+	heading(16, "Interview report: "+r.SessionID)
+	facts(r.Facts())
+
+	for i, question := range r.Questions {
+		title := r.QuestionTitle(i) + " (" + question.Language + ")"
+		if question.Verdict != "" {
+			title += ": " + question.Verdict
+		}
+		heading(12, title)
+		for _, match := range question.Similarity {
+			pdf.SetFont("Helvetica", "", 10)
+			pdf.SetTextColor(176, 0, 32)
+			pdf.MultiCell(0, 5, tr("Similarity: "+question.SimilarityLabel(match)), "", "L", false)
+			pdf.SetTextColor(0, 0, 0)
+		}
+		pdf.SetFont("Courier", "", 8)
+		pdf.SetFillColor(245, 245, 245)
+		pdf.MultiCell(0, 3.5, tr(strings.ReplaceAll(question.Code, "\t", "    ")), "", "L", true)
+		pdf.Ln(4)
+	}
+
+	if len(r.Runs) > 0 {
+		heading(12, "Runs")
+		lines := make([]string, 0, len(r.Runs))
+		for _, run := range r.Runs {
+			lines = append(lines, r.RunLabel(run))
+		}
+		text(strings.Join(lines, "\n"))
+	}
+	if ratings := r.Ratings(); len(ratings) > 0 {
+		heading(12, "Scorecard")
+		facts(ratings)
+		if r.Scorecard.Summary != "" {
+			text(r.Scorecard.Summary)
+		}
+	}
+	if r.Notes != "" {
+		heading(12, "Interviewer notes")
+		text(r.Notes)
+	}
+	return pdf.Output(w)
+}
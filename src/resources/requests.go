@@ -0,0 +1,74 @@
+package resources
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// requestHistorySize bounds how many messages are remembered per participant.
+const requestHistorySize = 128
+
+// requestsKey holds the replies sent to a participant, by message ID, and
+// requestOrderKey the IDs in the order they were received so the oldest can be
+// forgotten. A message still being handled has an empty reply.
+func requestsKey(sessionID string, participant string) string {
+	return fmt.Sprintf("session:%s:requests:%s", sessionID, participant)
+}
+
+func requestOrderKey(sessionID string, participant string) string {
+	return fmt.Sprintf("session:%s:request_order:%s", sessionID, participant)
+}
+
+// ClaimRequest records that participant sent the message requestID. It
+// reports false if the message was received before, on this connection or an
+// earlier one, with the reply sent for it once there is one.
+func ClaimRequest(c *Cache, sessionID string, participant string, requestID string) (bool, []byte, error) {
+	key := requestsKey(sessionID, participant)
+	claimed, err := c.Client.HSetNX(c.Ctx, key, requestID, "").Result()
+	if err != nil {
+		return false, nil, err
+	}
+	if !claimed {
+		reply, err := c.Client.HGet(c.Ctx, key, requestID).Bytes()
+		if errors.Is(err, redis.Nil) {
+			err = nil
+		}
+		return false, reply, err
+	}
+
+	orderKey := requestOrderKey(sessionID, participant)
+	var length *redis.IntCmd
+	_, err = c.Client.TxPipelined(c.Ctx, func(pipe redis.Pipeliner) error {
+		pipe.Expire(c.Ctx, key, sessionTTL)
+		length = pipe.RPush(c.Ctx, orderKey, requestID)
+		pipe.Expire(c.Ctx, orderKey, sessionTTL)
+		return nil
+	})
+	if err != nil {
+		return true, nil, err
+	}
+	if extra := length.Val() - requestHistorySize; extra > 0 {
+		forgotten, err := c.Client.LPopCount(c.Ctx, orderKey, int(extra)).Result()
+		if err != nil {
+			return true, nil, err
+		}
+		if err := c.Client.HDel(c.Ctx, key, forgotten...).Err(); err != nil {
+			return true, nil, err
+		}
+	}
+	return true, nil, nil
+}
+
+// RecordReply stores the reply sent for the message requestID, sent again if
+// participant resends it.
+func RecordReply(c *Cache, sessionID string, participant string, requestID string, reply []byte) error {
+	return c.Client.HSet(c.Ctx, requestsKey(sessionID, participant), requestID, reply).Err()
+}
+
+// ForgetRequest lets participant send requestID again, once it was rejected
+// without being applied.
+func ForgetRequest(c *Cache, sessionID string, participant string, requestID string) error {
+	return c.Client.HDel(c.Ctx, requestsKey(sessionID, participant), requestID).Err()
+}
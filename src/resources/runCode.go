@@ -58,17 +58,35 @@ var runners = map[string]RunnerConfig{
 	},
 }
 
+// RunRequest runs Code with Stdin as its input. A TimeLimitMs or
+// MemoryLimitMB of zero keeps the language's defaults, and an OutputLimit of
+// zero caps stdout at DefaultOutputLimit bytes.
 type RunRequest struct {
-	Language string `json:"language" binding:"required"`
-	Code     string `json:"code" binding:"required"`
+	Language      string `json:"language" binding:"required"`
+	Code          string `json:"code" binding:"required"`
+	Stdin         string `json:"stdin,omitempty"`
+	TimeLimitMs   int    `json:"time_limit_ms,omitempty"`
+	MemoryLimitMB int    `json:"memory_limit_mb,omitempty"`
+	OutputLimit   int    `json:"-"`
 }
 
+// DefaultOutputLimit is how much of stdout and stderr a run keeps, in bytes.
+const DefaultOutputLimit = 8 * 1024
+
+const (
+	errTimeLimit   = "Time Limit Error"
+	errMemoryLimit = "Memory Limit Error"
+	errOutputLimit = "Output Limit Error"
+)
+
 type RunResponse struct {
 	Stdout   string `json:"stdout"`
 	Stderr   string `json:"stderr"`
 	ExitCode int    `json:"exit_code"`
 	Error    string `json:"error,omitempty"`
 	Info     string `json:"info,omitempty"`
+	TimeMs   int    `json:"time_ms,omitempty"`
+	MemoryKB int    `json:"memory_kb,omitempty"`
 }
 
 type LimitedWriter struct {
@@ -111,11 +129,22 @@ func RunUserCode(ctx context.Context, baseWorkdir string, req RunRequest) (*RunR
 	containerPath := "/app/" + fname
 	containerName := "job-" + jobID
 
+	memory := lang.Memory
+	if req.MemoryLimitMB > 0 {
+		memory = fmt.Sprintf("%dm", req.MemoryLimitMB)
+	}
+	timeout := time.Duration(src.Config.RunTimeoutSecond) * time.Second
+	if req.TimeLimitMs > 0 {
+		// The limit applies to the program's own run time, measured inside
+		// the container; the wall clock also covers starting it and compiling.
+		timeout += time.Duration(req.TimeLimitMs) * time.Millisecond
+	}
+
 	dockerArgs := []string{
-		"run", "--rm", "--name", containerName,
+		"run", "--rm", "-i", "--name", containerName,
 		"--network=none",
 		"--pids-limit=64",
-		"--memory=" + lang.Memory,
+		"--memory=" + memory,
 		"--cpus=" + lang.CPUs,
 		"--read-only",
 		"--security-opt", "no-new-privileges",
@@ -125,15 +154,18 @@ func RunUserCode(ctx context.Context, baseWorkdir string, req RunRequest) (*RunR
 
 	dockerArgs = append(dockerArgs, lang.ExtraArgs...)
 	dockerArgs = append(dockerArgs, "runner-code:latest", "sh", "-c", fmt.Sprintf(lang.Cmd, containerPath))
-	ctxTimeout, cancel := context.WithTimeout(ctx, time.Duration(src.Config.RunTimeoutSecond)*time.Second)
+	ctxTimeout, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	cmd := exec.Command("docker", dockerArgs...)
-	cmd.Stdin = nil
+	cmd.Stdin = strings.NewReader(req.Stdin)
 
-	const outputLimit = 8 * 1024
+	outputLimit := DefaultOutputLimit
+	if req.OutputLimit > 0 {
+		outputLimit = req.OutputLimit
+	}
 	stdoutLimit := &LimitedWriter{Limit: outputLimit}
-	stderrLimit := &LimitedWriter{Limit: outputLimit}
+	stderrLimit := &LimitedWriter{Limit: DefaultOutputLimit}
 	cmd.Stdout = stdoutLimit
 	cmd.Stderr = stderrLimit
 
@@ -147,7 +179,7 @@ func RunUserCode(ctx context.Context, baseWorkdir string, req RunRequest) (*RunR
 	case <-ctxTimeout.Done():
 		_ = exec.Command("docker", "kill", containerName).Run()
 		return &RunResponse{
-			Error:    "Time Limit Error",
+			Error:    errTimeLimit,
 			ExitCode: -1,
 		}, nil
 
@@ -160,7 +192,7 @@ func RunUserCode(ctx context.Context, baseWorkdir string, req RunRequest) (*RunR
 		}
 
 		if stdoutLimit.Hit || stderrLimit.Hit {
-			res.Error = "Output Limit Error"
+			res.Error = errOutputLimit
 			res.ExitCode = -1
 			res.Stdout = ""
 			return res, nil
@@ -173,7 +205,7 @@ func RunUserCode(ctx context.Context, baseWorkdir string, req RunRequest) (*RunR
 			}
 		}
 		if res.ExitCode == 137 {
-			res.Error = "Memory Limit Error"
+			res.Error = errMemoryLimit
 		}
 		setInfo := func() {
 			res.Stderr = strings.TrimSpace(res.Stderr)
@@ -196,10 +228,19 @@ func RunUserCode(ctx context.Context, baseWorkdir string, req RunRequest) (*RunR
 				"💾 Runtime Memory: %dmb\n⏱️ Runtime Performance: %dms", memoryKB/1024, timeMs,
 			)
 			res.Stderr = res.Stderr[:len(res.Stderr)-len(last)]
+			res.TimeMs = timeMs
+			res.MemoryKB = memoryKB
 
 		}
 		setInfo()
 
+		if res.Error == "" && req.TimeLimitMs > 0 && res.TimeMs > req.TimeLimitMs {
+			res.Error = errTimeLimit
+		}
+		if res.Error == "" && req.MemoryLimitMB > 0 && res.MemoryKB > req.MemoryLimitMB*1024 {
+			res.Error = errMemoryLimit
+		}
+
 		return res, nil
 	}
 }
@@ -0,0 +1,193 @@
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// InstanceID identifies this CodeStream process among all replicas sharing the same Redis.
+var InstanceID = generateSessionID(16)
+
+const (
+	instanceAliveTTL      = 30 * time.Second
+	instanceAlivePeriod   = 10 * time.Second
+	sessionPresenceExpiry = time.Hour * 24
+)
+
+// SessionEvent is the envelope published on a session channel. Payload is the
+// websocket message exactly as local clients receive it. Private events are
+// only delivered to interviewers, and events with a Leader only to the
+// participants following them.
+type SessionEvent struct {
+	Origin  string          `json:"origin"`
+	Sender  string          `json:"sender,omitempty"`
+	Private bool            `json:"private,omitempty"`
+	Leader  string          `json:"leader,omitempty"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+func sessionEventsChannel(sessionID string) string {
+	return fmt.Sprintf("session:%s:events", sessionID)
+}
+
+func sessionPresenceKey(sessionID string) string {
+	return fmt.Sprintf("session:%s:presence", sessionID)
+}
+
+func instanceAliveKey(instanceID string) string {
+	return fmt.Sprintf("instance:%s:alive", instanceID)
+}
+
+func PublishSessionEvent(c *Cache, sessionID string, sender string, payload []byte) error {
+	return publishSessionEvent(c, sessionID, SessionEvent{
+		Origin:  InstanceID,
+		Sender:  sender,
+		Payload: payload,
+	})
+}
+
+// PublishPrivateSessionEvent publishes payload for the session's interviewers.
+func PublishPrivateSessionEvent(c *Cache, sessionID string, payload []byte) error {
+	return publishSessionEvent(c, sessionID, SessionEvent{
+		Origin:  InstanceID,
+		Private: true,
+		Payload: payload,
+	})
+}
+
+// PublishFollowEvent publishes payload for the followers of leader.
+func PublishFollowEvent(c *Cache, sessionID string, leader string, payload []byte) error {
+	return publishSessionEvent(c, sessionID, SessionEvent{
+		Origin:  InstanceID,
+		Leader:  leader,
+		Payload: payload,
+	})
+}
+
+func publishSessionEvent(c *Cache, sessionID string, event SessionEvent) error {
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return c.Client.Publish(c.Ctx, sessionEventsChannel(sessionID), eventJSON).Err()
+}
+
+func SubscribeSessionEvents(c *Cache, sessionID string) *redis.PubSub {
+	return c.Client.Subscribe(c.Ctx, sessionEventsChannel(sessionID))
+}
+
+// StartInstanceHeartbeat keeps this instance marked alive so presence entries
+// left behind by a crashed replica can be told apart from live ones.
+func StartInstanceHeartbeat() {
+	c := NewCacheContext()
+	c.Set(instanceAliveKey(InstanceID), time.Now().Unix(), instanceAliveTTL)
+
+	go func() {
+		ticker := time.NewTicker(instanceAlivePeriod)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := c.Client.Set(c.Ctx, instanceAliveKey(InstanceID), time.Now().Unix(), instanceAliveTTL).Err(); err != nil {
+				log.Printf("Failed to refresh instance heartbeat: %v", err)
+			}
+		}
+	}()
+}
+
+// ClaimPresence registers username in the session on this instance. It returns
+// false when the name is already taken by a participant on any instance.
+func ClaimPresence(c *Cache, sessionID string, username string) (bool, error) {
+	key := sessionPresenceKey(sessionID)
+	ok, err := c.Client.HSetNX(c.Ctx, key, username, InstanceID).Result()
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		c.Client.Expire(c.Ctx, key, sessionPresenceExpiry)
+	}
+	return ok, nil
+}
+
+func ReleasePresence(c *Cache, sessionID string, username string) {
+	c.Client.HDel(c.Ctx, sessionPresenceKey(sessionID), username)
+	c.Client.HDel(c.Ctx, participantStatusKey(sessionID), username)
+}
+
+// ParticipantStatus is what a participant is doing, shared between instances
+// so every participant list shows everyone.
+type ParticipantStatus struct {
+	Role       string `json:"role"`
+	Status     string `json:"status"`
+	StartPos   int    `json:"start_pos"`
+	EndPos     int    `json:"end_pos"`
+	LastActive int64  `json:"last_active"`
+}
+
+func participantStatusKey(sessionID string) string {
+	return fmt.Sprintf("session:%s:status", sessionID)
+}
+
+func SetParticipantStatus(c *Cache, sessionID string, username string, status ParticipantStatus) error {
+	statusJSON, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	key := participantStatusKey(sessionID)
+	_, err = c.Client.TxPipelined(c.Ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(c.Ctx, key, username, statusJSON)
+		pipe.Expire(c.Ctx, key, sessionPresenceExpiry)
+		return nil
+	})
+	return err
+}
+
+// ParticipantStatuses returns the status of every participant by username.
+func ParticipantStatuses(c *Cache, sessionID string) (map[string]ParticipantStatus, error) {
+	entries, err := c.Client.HGetAll(c.Ctx, participantStatusKey(sessionID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make(map[string]ParticipantStatus, len(entries))
+	for username, statusStr := range entries {
+		var status ParticipantStatus
+		if json.Unmarshal([]byte(statusStr), &status) == nil {
+			statuses[username] = status
+		}
+	}
+	return statuses, nil
+}
+
+// ListPresence returns the usernames connected to the session across all
+// instances, pruning entries owned by instances that stopped heartbeating.
+func ListPresence(c *Cache, sessionID string) ([]string, error) {
+	key := sessionPresenceKey(sessionID)
+	entries, err := c.Client.HGetAll(c.Ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	alive := map[string]bool{InstanceID: true}
+	usernames := make([]string, 0, len(entries))
+	for username, instanceID := range entries {
+		if _, checked := alive[instanceID]; !checked {
+			alive[instanceID] = c.Exists(instanceAliveKey(instanceID))
+		}
+		if !alive[instanceID] {
+			c.Client.HDel(c.Ctx, key, username)
+			continue
+		}
+		usernames = append(usernames, username)
+	}
+	return usernames, nil
+}
+
+func CountPresence(c *Cache, sessionID string) int {
+	usernames, err := ListPresence(c, sessionID)
+	if err != nil {
+		return 0
+	}
+	return len(usernames)
+}
@@ -0,0 +1,255 @@
+package resources
+
+import (
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// Winnowing parameters: fingerprints hash k-grams of fingerprintK tokens and
+// keep the smallest hash of every fingerprintW consecutive k-grams, so any
+// match of fingerprintK+fingerprintW-1 tokens or more is detected.
+const (
+	fingerprintK = 5
+	fingerprintW = 4
+)
+
+var languageKeywords = map[string][]string{
+	"python": {
+		"False", "None", "True", "and", "as", "assert", "async", "await", "break", "class", "continue", "def", "del",
+		"elif", "else", "except", "finally", "for", "from", "global", "if", "import", "in", "is", "lambda", "nonlocal",
+		"not", "or", "pass", "raise", "return", "try", "while", "with", "yield",
+	},
+	"javascript": {
+		"async", "await", "break", "case", "catch", "class", "const", "continue", "default", "delete", "do", "else",
+		"export", "extends", "false", "finally", "for", "function", "if", "import", "in", "instanceof", "let", "new",
+		"null", "of", "return", "static", "super", "switch", "this", "throw", "true", "try", "typeof", "undefined",
+		"var", "void", "while", "yield",
+	},
+	"go": {
+		"break", "case", "chan", "const", "continue", "default", "defer", "else", "fallthrough", "for", "func", "go",
+		"goto", "if", "import", "interface", "map", "package", "range", "return", "select", "struct", "switch", "type",
+		"var", "nil", "true", "false",
+	},
+	"cpp": {
+		"auto", "bool", "break", "case", "catch", "char", "class", "const", "continue", "default", "delete", "do",
+		"double", "else", "enum", "false", "float", "for", "if", "include", "int", "long", "namespace", "new",
+		"nullptr", "operator", "private", "public", "return", "short", "signed", "sizeof", "static", "struct",
+		"switch", "template", "this", "throw", "true", "try", "typedef", "unsigned", "using", "void", "while",
+	},
+}
+
+// codeToken is a normalized token with the bytes of the code it came from.
+type codeToken struct {
+	text  string
+	start int
+	end   int
+}
+
+// tokenizeCode splits code into tokens, dropping whitespace and comments.
+// Identifiers other than the language's keywords become V, numbers N and
+// strings S, so renaming variables or changing literals does not hide a copy.
+func tokenizeCode(language string, code string) []codeToken {
+	keywords := make(map[string]bool)
+	for _, keyword := range languageKeywords[language] {
+		keywords[keyword] = true
+	}
+	hashComments := language == "python"
+
+	tokens := make([]codeToken, 0, len(code)/3)
+	isWord := func(b byte) bool {
+		return b == '_' || b == '$' || b >= 0x80 || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9'
+	}
+	for i := 0; i < len(code); {
+		b := code[i]
+		start := i
+		switch {
+		case b == ' ' || b == '\t' || b == '\n' || b == '\r':
+			i++
+			continue
+		case hashComments && b == '#', !hashComments && strings.HasPrefix(code[i:], "//"):
+			if end := strings.IndexByte(code[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(code)
+			}
+			continue
+		case !hashComments && strings.HasPrefix(code[i:], "/*"):
+			if end := strings.Index(code[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(code)
+			}
+			continue
+		case b == '"' || b == '\'' || b == '`':
+			i = stringEnd(code, i)
+			tokens = append(tokens, codeToken{"S", start, i})
+		case '0' <= b && b <= '9':
+			for i < len(code) && (isWord(code[i]) || code[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, codeToken{"N", start, i})
+		case isWord(b):
+			for i < len(code) && isWord(code[i]) {
+				i++
+			}
+			word := code[start:i]
+			if !keywords[word] {
+				word = "V"
+			}
+			tokens = append(tokens, codeToken{word, start, i})
+		default:
+			i++
+			tokens = append(tokens, codeToken{code[start:i], start, i})
+		}
+	}
+	return tokens
+}
+
+// stringEnd returns the offset just past the string literal starting at i,
+// with Python's triple quotes and backslash escapes.
+func stringEnd(code string, i int) int {
+	quote := code[i : i+1]
+	if strings.HasPrefix(code[i:], strings.Repeat(quote, 3)) {
+		quote = strings.Repeat(quote, 3)
+	}
+	for j := i + len(quote); j < len(code); j++ {
+		switch {
+		case code[j] == '\\' && quote != "`":
+			j++
+		case strings.HasPrefix(code[j:], quote):
+			return j + len(quote)
+		case code[j] == '\n' && len(quote) == 1 && quote != "`":
+			return j
+		}
+	}
+	return len(code)
+}
+
+// fingerprintHash is a selected k-gram hash with the bytes it covers.
+type fingerprintHash struct {
+	hash  uint64
+	start int
+	end   int
+}
+
+// Fingerprint is the winnowed fingerprint of a piece of code.
+type Fingerprint struct {
+	Language string
+	hashes   []fingerprintHash
+}
+
+// NewFingerprint fingerprints code by winnowing the hashes of its normalized
+// token k-grams.
+func NewFingerprint(language string, code string) Fingerprint {
+	tokens := tokenizeCode(language, code)
+	fingerprint := Fingerprint{Language: language}
+	if len(tokens) < fingerprintK {
+		return fingerprint
+	}
+
+	grams := make([]fingerprintHash, len(tokens)-fingerprintK+1)
+	for i := range grams {
+		h := fnv.New64a()
+		for _, token := range tokens[i : i+fingerprintK] {
+			h.Write([]byte(token.text))
+			h.Write([]byte{0})
+		}
+		grams[i] = fingerprintHash{hash: h.Sum64(), start: tokens[i].start, end: tokens[i+fingerprintK-1].end}
+	}
+
+	window := min(fingerprintW, len(grams))
+	selected := -1
+	for i := 0; i+window <= len(grams); i++ {
+		smallest := i
+		for j := i; j < i+window; j++ {
+			if grams[j].hash <= grams[smallest].hash {
+				smallest = j
+			}
+		}
+		if smallest != selected {
+			selected = smallest
+			fingerprint.hashes = append(fingerprint.hashes, grams[smallest])
+		}
+	}
+	return fingerprint
+}
+
+// MatchRegion is a stretch of code found in the other code, as byte offsets
+// into both.
+type MatchRegion struct {
+	Start      int `json:"start"`
+	End        int `json:"end"`
+	OtherStart int `json:"other_start"`
+	OtherEnd   int `json:"other_end"`
+}
+
+// Compare returns the share of f's fingerprint found in other, from 0 to 1,
+// and the regions of f's code that match, merged where they overlap.
+func (f Fingerprint) Compare(other Fingerprint) (float64, []MatchRegion) {
+	if f.Language != other.Language || len(f.hashes) == 0 {
+		return 0, nil
+	}
+	found := make(map[uint64]fingerprintHash, len(other.hashes))
+	for _, h := range other.hashes {
+		if _, ok := found[h.hash]; !ok {
+			found[h.hash] = h
+		}
+	}
+
+	distinct := make(map[uint64]bool, len(f.hashes))
+	matched := 0
+	regions := make([]MatchRegion, 0)
+	for _, h := range f.hashes {
+		match, ok := found[h.hash]
+		if !distinct[h.hash] {
+			distinct[h.hash] = true
+			if ok {
+				matched++
+			}
+		}
+		if ok {
+			regions = append(regions, MatchRegion{Start: h.start, End: h.end, OtherStart: match.start, OtherEnd: match.end})
+		}
+	}
+	return float64(matched) / float64(len(distinct)), mergeRegions(regions)
+}
+
+func mergeRegions(regions []MatchRegion) []MatchRegion {
+	sort.Slice(regions, func(i, j int) bool { return regions[i].Start < regions[j].Start })
+	merged := make([]MatchRegion, 0, len(regions))
+	for _, region := range regions {
+		last := len(merged) - 1
+		if last >= 0 && region.Start <= merged[last].End {
+			merged[last].End = max(merged[last].End, region.End)
+			merged[last].OtherStart = min(merged[last].OtherStart, region.OtherStart)
+			merged[last].OtherEnd = max(merged[last].OtherEnd, region.OtherEnd)
+			continue
+		}
+		merged = append(merged, region)
+	}
+	return merged
+}
+
+// lineSpan returns the 1-based lines of code that bytes start to end cover.
+func lineSpan(code string, start int, end int) (int, int) {
+	end = max(min(end, len(code)), start+1)
+	first := strings.Count(code[:start], "\n") + 1
+	return first, first + strings.Count(code[start:end-1], "\n")
+}
+
+// Without drops the hashes other shares with f, such as those of the starter
+// code every candidate begins with.
+func (f Fingerprint) Without(other Fingerprint) Fingerprint {
+	shared := make(map[uint64]bool, len(other.hashes))
+	for _, h := range other.hashes {
+		shared[h.hash] = true
+	}
+	kept := Fingerprint{Language: f.Language}
+	for _, h := range f.hashes {
+		if !shared[h.hash] {
+			kept.hashes = append(kept.hashes, h)
+		}
+	}
+	return kept
+}
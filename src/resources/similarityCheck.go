@@ -0,0 +1,195 @@
+package resources
+
+import (
+	"CodeStream/src"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Sources of code a question's final code is compared with.
+const (
+	SimilaritySession = "session"
+	SimilarityCorpus  = "corpus"
+)
+
+const (
+	similarityThreshold   = 0.3
+	maxSimilarityMatches  = 3
+	maxSimilaritySessions = 200
+	maxCorpusFileSize     = 1 << 20
+)
+
+var languageExtensions = map[string]string{
+	".py":  "python",
+	".js":  "javascript",
+	".go":  "go",
+	".cpp": "cpp",
+	".cc":  "cpp",
+	".cxx": "cpp",
+}
+
+// SimilarityMatch is code that a question's final code resembles: another
+// session's code for the same problem or a known solution from the corpus.
+// Score is the share of the question's fingerprint found in it.
+type SimilarityMatch struct {
+	Source  string        `json:"source"`
+	Name    string        `json:"name"`
+	Score   float64       `json:"score"`
+	Regions []MatchRegion `json:"regions"`
+}
+
+type similarityCandidate struct {
+	source   string
+	name     string
+	language string
+	code     string
+}
+
+// CheckSimilarity compares the final code of each question with the code
+// archived sessions have for the same problem and with the corpus in
+// SIMILARITY_CORPUS_DIR, keeping the closest matches above the threshold.
+// Code the problem's starter code accounts for is left out of the comparison.
+func (r *SessionReport) CheckSimilarity(ctx context.Context) {
+	for i := range r.Questions {
+		question := &r.Questions[i]
+		fingerprint := NewFingerprint(question.Language, question.Code)
+		if question.ProblemID != "" && Problems != nil {
+			if problem, err := Problems.Get(ctx, question.ProblemID); err == nil {
+				fingerprint = fingerprint.Without(NewFingerprint(question.Language, problem.StarterCode[question.Language]))
+			}
+		}
+
+		matches := make([]SimilarityMatch, 0)
+		for _, candidate := range similarityCandidates(ctx, r.SessionID, question.ProblemID) {
+			if candidate.language != question.Language {
+				continue
+			}
+			score, regions := fingerprint.Compare(NewFingerprint(candidate.language, candidate.code))
+			if score < similarityThreshold {
+				continue
+			}
+			matches = append(matches, SimilarityMatch{candidate.source, candidate.name, score, regions})
+		}
+		sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+		question.Similarity = matches[:min(len(matches), maxSimilarityMatches)]
+	}
+}
+
+// similarityCandidates gathers the code of other archived sessions on the
+// problem and the corpus solutions that apply to it.
+func similarityCandidates(ctx context.Context, sessionID string, problemID string) []similarityCandidate {
+	candidates := make([]similarityCandidate, 0)
+	if problemID != "" && Archive != nil {
+		solutions, err := Archive.ListSolutions(ctx, problemID, maxSimilaritySessions)
+		if err != nil {
+			log.Printf("Failed to list archived solutions for problem %s: %v", problemID, err)
+		}
+		for _, solution := range solutions {
+			if solution.SessionID != sessionID {
+				candidates = append(candidates,
+					similarityCandidate{SimilaritySession, solution.SessionID, solution.Language, solution.Code})
+			}
+		}
+	}
+	return append(candidates, corpusSolutions(problemID)...)
+}
+
+// corpusSolutions reads known solutions from SIMILARITY_CORPUS_DIR. Files at
+// its top level are compared with every question, files in a directory named
+// after a problem ID only with that problem's. The extension gives the
+// language.
+func corpusSolutions(problemID string) []similarityCandidate {
+	root := src.Config.SimilarityCorpusDir
+	if root == "" {
+		return nil
+	}
+	dirs := []string{root}
+	if problemID != "" && filepath.Base(problemID) == problemID && !strings.HasPrefix(problemID, ".") {
+		dirs = append(dirs, filepath.Join(root, problemID))
+	}
+
+	candidates := make([]similarityCandidate, 0)
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Printf("Failed to read similarity corpus %s: %v", dir, err)
+			}
+			continue
+		}
+		for _, entry := range entries {
+			language, ok := languageExtensions[filepath.Ext(entry.Name())]
+			if !ok || !entry.Type().IsRegular() {
+				continue
+			}
+			if info, err := entry.Info(); err != nil || info.Size() > maxCorpusFileSize {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			code, err := os.ReadFile(path)
+			if err != nil {
+				log.Printf("Failed to read corpus solution %s: %v", path, err)
+				continue
+			}
+			name, _ := filepath.Rel(root, path)
+			candidates = append(candidates, similarityCandidate{SimilarityCorpus, name, language, string(code)})
+		}
+	}
+	return candidates
+}
+
+// SimilarityLabel describes a match in one line, with the lines of the
+// question's code it covers.
+func (q ReportQuestion) SimilarityLabel(match SimilarityMatch) string {
+	source := "session " + match.Name
+	if match.Source == SimilarityCorpus {
+		source = "known solution " + match.Name
+	}
+	lines := make([]string, 0, len(match.Regions))
+	for _, region := range match.Regions {
+		first, last := lineSpan(q.Code, region.Start, region.End)
+		if first == last {
+			lines = append(lines, fmt.Sprint(first))
+		} else {
+			lines = append(lines, fmt.Sprintf("%d-%d", first, last))
+		}
+	}
+	return fmt.Sprintf("%.0f%% similar to %s (lines %s)", match.Score*100, source, strings.Join(lines, ", "))
+}
+
+// CodeSegment is a run of a question's code, marked when it matches code
+// from elsewhere.
+type CodeSegment struct {
+	Text  string
+	Match bool
+}
+
+// CodeSegments splits the question's code where its matches start and end.
+func (q ReportQuestion) CodeSegments() []CodeSegment {
+	regions := make([]MatchRegion, 0)
+	for _, match := range q.Similarity {
+		regions = append(regions, match.Regions...)
+	}
+	segments := make([]CodeSegment, 0, 2*len(regions)+1)
+	pos := 0
+	for _, region := range mergeRegions(regions) {
+		start, end := max(region.Start, pos), min(region.End, len(q.Code))
+		if start >= end {
+			continue
+		}
+		if start > pos {
+			segments = append(segments, CodeSegment{q.Code[pos:start], false})
+		}
+		segments = append(segments, CodeSegment{q.Code[start:end], true})
+		pos = end
+	}
+	if pos < len(q.Code) {
+		segments = append(segments, CodeSegment{q.Code[pos:], false})
+	}
+	return segments
+}
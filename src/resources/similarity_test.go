@@ -0,0 +1,257 @@
+package resources
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const twoSumPython = `def solve(nums, target):
+    seen = {}
+    for i, n in enumerate(nums):
+        if target - n in seen:
+            return [seen[target - n], i]
+        seen[n] = i
+    return []
+`
+
+// twoSumRenamed is twoSumPython with other names, literals and comments.
+const twoSumRenamed = `def find_pair(values, goal):
+    # indexes of the values seen so far
+    index = {}
+    for j, v in enumerate(values):
+        if goal - v in index:
+            return [index[goal - v], j]
+        index[v] = j
+    return []
+`
+
+func tokenTexts(tokens []codeToken) string {
+	texts := make([]string, len(tokens))
+	for i, token := range tokens {
+		texts[i] = token.text
+	}
+	return strings.Join(texts, " ")
+}
+
+func TestTokenizeCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		code     string
+		want     string
+	}{
+		{"python names and literals", "python", "x = 1.5e3 + y  # note\nreturn 'a'", "V = N + V return S"},
+		{"python triple quotes", "python", "s = \"\"\"one\n\"two\"\n\"\"\"\nprint(s)", "V = S V ( V )"},
+		{"python escapes", "python", `s = 'it\'s' + "q"`, "V = S + S"},
+		{"javascript comments", "javascript", "const a = b /* c\nd */ + `t\n${x}`; // e", "const V = V + S ;"},
+		{"unterminated string ends at the line", "javascript", "let s = \"abc\nreturn s", "let V = S return V"},
+		{"unterminated comment", "go", "x := 1 /* never closed", "V : = N"},
+		{"go keywords", "go", "func main() { for range ch {} }", "func V ( ) { for range V { } }"},
+		{"cpp hash is not a comment", "cpp", "#include <vector>\nint n;", "# include < V > int V ;"},
+		{"unicode identifiers", "python", "größe = 1", "V = N"},
+		{"unknown language", "ruby", "def x; end", "V V ; V"},
+		{"empty", "python", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tokenTexts(tokenizeCode(tt.language, tt.code)); got != tt.want {
+				t.Errorf("tokenizeCode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenizeCodeOffsets(t *testing.T) {
+	code := "total = 'a b' # sum"
+	want := []codeToken{{"V", 0, 5}, {"=", 6, 7}, {"S", 8, 13}}
+	if got := tokenizeCode("python", code); !reflect.DeepEqual(got, want) {
+		t.Errorf("tokenizeCode() = %+v, want %+v", got, want)
+	}
+}
+
+func TestNewFingerprint(t *testing.T) {
+	tests := []struct {
+		name       string
+		language   string
+		code       string
+		wantHashes bool
+	}{
+		{"too short", "python", "x = 1", false},
+		{"exactly one k-gram", "python", "x = y + z", true},
+		{"function", "python", twoSumPython, true},
+		{"only comments", "javascript", "// one\n/* two */", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fingerprint := NewFingerprint(tt.language, tt.code)
+			if fingerprint.Language != tt.language {
+				t.Errorf("language = %q, want %q", fingerprint.Language, tt.language)
+			}
+			if got := len(fingerprint.hashes) > 0; got != tt.wantHashes {
+				t.Fatalf("has hashes = %v, want %v", got, tt.wantHashes)
+			}
+			for _, h := range fingerprint.hashes {
+				if h.start < 0 || h.end > len(tt.code) || h.start >= h.end {
+					t.Errorf("hash covers bytes %d to %d of %d", h.start, h.end, len(tt.code))
+				}
+			}
+		})
+	}
+}
+
+func TestFingerprintCompare(t *testing.T) {
+	tests := []struct {
+		name        string
+		code        Fingerprint
+		other       Fingerprint
+		wantScore   float64
+		wantRegions int
+	}{
+		{
+			name:        "identical",
+			code:        NewFingerprint("python", twoSumPython),
+			other:       NewFingerprint("python", twoSumPython),
+			wantScore:   1,
+			wantRegions: 1,
+		},
+		{
+			name:        "renamed",
+			code:        NewFingerprint("python", twoSumRenamed),
+			other:       NewFingerprint("python", twoSumPython),
+			wantScore:   1,
+			wantRegions: 1,
+		},
+		{
+			name:      "other language",
+			code:      NewFingerprint("python", twoSumPython),
+			other:     NewFingerprint("javascript", twoSumPython),
+			wantScore: 0,
+		},
+		{
+			name:      "no fingerprint",
+			code:      NewFingerprint("python", "x = 1"),
+			other:     NewFingerprint("python", twoSumPython),
+			wantScore: 0,
+		},
+		{
+			name:      "unrelated",
+			code:      NewFingerprint("python", "while True:\n    line = input()\n    if not line:\n        break\n    print(line.upper())\n"),
+			other:     NewFingerprint("python", twoSumPython),
+			wantScore: 0,
+		},
+		{
+			name:      "starter code left out",
+			code:      NewFingerprint("python", twoSumPython).Without(NewFingerprint("python", twoSumPython)),
+			other:     NewFingerprint("python", twoSumPython),
+			wantScore: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, regions := tt.code.Compare(tt.other)
+			if score != tt.wantScore {
+				t.Errorf("score = %v, want %v", score, tt.wantScore)
+			}
+			if len(regions) != tt.wantRegions {
+				t.Errorf("regions = %+v, want %d", regions, tt.wantRegions)
+			}
+		})
+	}
+}
+
+func TestFingerprintComparePartialCopy(t *testing.T) {
+	code := "import sys\n\nfor line in sys.stdin:\n    print(sum(map(int, line.split())))\n\n" + twoSumRenamed
+	score, regions := NewFingerprint("python", code).Compare(NewFingerprint("python", twoSumPython))
+	if score <= 0 || score >= 1 {
+		t.Errorf("score = %v, want between 0 and 1", score)
+	}
+	if len(regions) != 1 {
+		t.Fatalf("regions = %+v, want 1", regions)
+	}
+	if copied := strings.Index(code, "def find_pair"); regions[0].Start < copied {
+		t.Errorf("region starts at %d, before the copied function at %d", regions[0].Start, copied)
+	}
+	if regions[0].OtherStart < 0 || regions[0].OtherEnd > len(twoSumPython) {
+		t.Errorf("region covers bytes %d to %d of the other code", regions[0].OtherStart, regions[0].OtherEnd)
+	}
+}
+
+func TestMergeRegions(t *testing.T) {
+	tests := []struct {
+		name    string
+		regions []MatchRegion
+		want    []MatchRegion
+	}{
+		{
+			name:    "none",
+			regions: []MatchRegion{},
+			want:    []MatchRegion{},
+		},
+		{
+			name: "apart",
+			regions: []MatchRegion{
+				{Start: 20, End: 30, OtherStart: 0, OtherEnd: 10},
+				{Start: 0, End: 10, OtherStart: 50, OtherEnd: 60},
+			},
+			want: []MatchRegion{
+				{Start: 0, End: 10, OtherStart: 50, OtherEnd: 60},
+				{Start: 20, End: 30, OtherStart: 0, OtherEnd: 10},
+			},
+		},
+		{
+			name: "overlapping",
+			regions: []MatchRegion{
+				{Start: 0, End: 10, OtherStart: 5, OtherEnd: 15},
+				{Start: 5, End: 20, OtherStart: 10, OtherEnd: 25},
+				{Start: 8, End: 12, OtherStart: 0, OtherEnd: 4},
+			},
+			want: []MatchRegion{{Start: 0, End: 20, OtherStart: 0, OtherEnd: 25}},
+		},
+		{
+			name: "touching",
+			regions: []MatchRegion{
+				{Start: 10, End: 20, OtherStart: 10, OtherEnd: 20},
+				{Start: 0, End: 10, OtherStart: 0, OtherEnd: 10},
+			},
+			want: []MatchRegion{{Start: 0, End: 20, OtherStart: 0, OtherEnd: 20}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeRegions(tt.regions); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeRegions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineSpan(t *testing.T) {
+	code := "a\nbb\nccc\n"
+	tests := []struct {
+		name      string
+		start     int
+		end       int
+		wantFirst int
+		wantLast  int
+	}{
+		{"first line", 0, 1, 1, 1},
+		{"up to a newline", 2, 5, 2, 2},
+		{"across lines", 2, 8, 2, 3},
+		{"empty range", 5, 5, 3, 3},
+		{"past the end", 5, 50, 3, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, last := lineSpan(code, tt.start, tt.end)
+			if first != tt.wantFirst || last != tt.wantLast {
+				t.Errorf("lineSpan(%d, %d) = %d, %d, want %d, %d", tt.start, tt.end, first, last, tt.wantFirst, tt.wantLast)
+			}
+		})
+	}
+}
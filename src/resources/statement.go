@@ -0,0 +1,100 @@
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DocumentStatement is the markdown document holding the problem statement
+// and notes, edited alongside the code.
+const DocumentStatement = "statement"
+
+// sessionDocument returns the document of the session named document, other
+// than its code.
+func sessionDocument(c *Cache, sessionID string, document string) *Interview {
+	key := func(name string) string {
+		return fmt.Sprintf("session:%s:%s:%s", sessionID, document, name)
+	}
+	return &Interview{
+		SessionID:       sessionID,
+		Document:        document,
+		StateCacheKey:   key("state"),
+		VersionCacheKey: key("version"),
+		PatchKey:        key("patch"),
+		TimelineKey:     timelineKey(sessionID),
+		HistoryKey:      key("history"),
+		CheckpointKey:   key("checkpoints"),
+		Cache:           c,
+	}
+}
+
+// documentKey names a key of the interview's document.
+func (interview *Interview) documentKey(name string) string {
+	if interview.Document == "" {
+		return fmt.Sprintf("session:%s:%s", interview.SessionID, name)
+	}
+	return fmt.Sprintf("session:%s:%s:%s", interview.SessionID, interview.Document, name)
+}
+
+// patchEventType returns the timeline event recording the document's patches,
+// or "" for documents that are not recorded.
+func (interview *Interview) patchEventType() string {
+	switch interview.Document {
+	case "":
+		return TimelinePatch
+	case DocumentStatement:
+		return TimelineStatement
+	default:
+		return ""
+	}
+}
+
+// initDocument starts the document at version 1 with content.
+func initDocument(c *Cache, pipe redis.Pipeliner, document *Interview, content string) error {
+	stateJSON, err := json.Marshal(CodeState{Content: content, Version: 1})
+	if err != nil {
+		return err
+	}
+	pipe.Set(c.Ctx, document.StateCacheKey, stateJSON, sessionTTL)
+	pipe.Set(c.Ctx, document.VersionCacheKey, 1, sessionTTL)
+	pipe.ZAdd(c.Ctx, document.CheckpointKey, redis.Z{Score: 1, Member: stateJSON})
+	pipe.Expire(c.Ctx, document.CheckpointKey, sessionTTL)
+	return nil
+}
+
+// Statement returns the session's statement document, creating an empty one
+// for sessions started without it.
+func (interview *Interview) Statement() (*Interview, error) {
+	return interview.openDocument(DocumentStatement)
+}
+
+func (interview *Interview) openDocument(name string) (*Interview, error) {
+	c := interview.Cache
+	document := sessionDocument(c, interview.SessionID, name)
+
+	version, err := c.Client.Get(c.Ctx, document.VersionCacheKey).Int64()
+	if errors.Is(err, redis.Nil) {
+		err = c.Client.Watch(c.Ctx, func(tx *redis.Tx) error {
+			exists, err := tx.Exists(c.Ctx, document.VersionCacheKey).Result()
+			if err != nil || exists == 1 {
+				return err
+			}
+			_, err = tx.TxPipelined(c.Ctx, func(pipe redis.Pipeliner) error {
+				return initDocument(c, pipe, document, "")
+			})
+			return err
+		}, document.VersionCacheKey)
+		if err != nil && !errors.Is(err, redis.TxFailedErr) {
+			return nil, err
+		}
+		version, err = c.Client.Get(c.Ctx, document.VersionCacheKey).Int64()
+	}
+	if err != nil {
+		return nil, err
+	}
+	document.Version = version
+	return document, nil
+}
@@ -0,0 +1,155 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Timeline event types. Every change to a session is appended to its timeline
+// so the interview can be replayed after it ended.
+const (
+	TimelineCreated  = "created"
+	TimelinePatch    = "patch"
+	TimelineRun      = "run"
+	TimelineLanguage = "lang"
+	TimelineJoin     = "join"
+	TimelineLeave    = "leave"
+	TimelineState    = "state"
+	TimelineTimer    = "timer"
+	TimelineJudge    = "judge"
+	TimelineQuestion = "question"
+	TimelineDrive    = "drive"
+
+	// TimelineStatement events are patches to the statement document.
+	TimelineStatement = "statement"
+
+	// Integrity signals: large insertions and the candidate leaving or
+	// returning to the session's tab.
+	TimelinePaste       = "paste"
+	TimelineFocusLost   = "focus_lost"
+	TimelineFocusGained = "focus_gained"
+
+	// Interviewers removing a participant or making them read-only.
+	TimelineKick = "kick"
+	TimelineBan  = "ban"
+	TimelineMute = "mute"
+)
+
+type TimelineEvent struct {
+	Type   string          `json:"type"`
+	At     int64           `json:"at"`
+	Author string          `json:"author,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+type CreatedEventData struct {
+	Language string `json:"lang"`
+	Code     string `json:"code"`
+	Version  int64  `json:"version"`
+}
+
+type RunEventData struct {
+	Language string       `json:"lang"`
+	Version  int64        `json:"version"`
+	Result   *RunResponse `json:"result"`
+}
+
+type JudgeEventData struct {
+	Language string       `json:"lang"`
+	Version  int64        `json:"version"`
+	Result   *JudgeResult `json:"result"`
+}
+
+type LanguageEventData struct {
+	Language string `json:"lang"`
+}
+
+// ModerationEventData is recorded on the timeline when an interviewer kicks,
+// bans or mutes a participant.
+type ModerationEventData struct {
+	Username string `json:"username"`
+	Reason   string `json:"reason,omitempty"`
+	Muted    bool   `json:"muted,omitempty"`
+}
+
+func timelineKey(sessionID string) string {
+	return fmt.Sprintf("session:%s:timeline", sessionID)
+}
+
+func newTimelineEvent(eventType string, author string, data interface{}) ([]byte, error) {
+	event := TimelineEvent{
+		Type:   eventType,
+		At:     time.Now().UnixMilli(),
+		Author: author,
+	}
+	if data != nil {
+		dataJSON, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		event.Data = dataJSON
+	}
+	return json.Marshal(event)
+}
+
+// appendTimelineEvent queues the event on pipe so it is recorded atomically
+// with the change it describes.
+func appendTimelineEvent(c *Cache, pipe redis.Pipeliner, key string, eventType string, author string, data interface{}) error {
+	eventJSON, err := newTimelineEvent(eventType, author, data)
+	if err != nil {
+		return err
+	}
+	pipe.RPush(c.Ctx, key, eventJSON)
+	return nil
+}
+
+func (interview *Interview) RecordEvent(eventType string, author string, data interface{}) error {
+	c := interview.Cache
+	eventJSON, err := newTimelineEvent(eventType, author, data)
+	if err != nil {
+		return err
+	}
+	return c.Client.RPush(c.Ctx, interview.TimelineKey, eventJSON).Err()
+}
+
+// GetTimeline returns the session's events in the order they happened.
+func GetTimeline(c *Cache, sessionID string) ([]TimelineEvent, error) {
+	eventStrings, err := c.Client.LRange(c.Ctx, timelineKey(sessionID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]TimelineEvent, 0, len(eventStrings))
+	for _, eventStr := range eventStrings {
+		var event TimelineEvent
+		if json.Unmarshal([]byte(eventStr), &event) == nil {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// LoadTimeline returns the session's events from Redis, or from its archive
+// once its Redis keys are gone. It fails with ErrArchiveNotFound when neither
+// exists.
+func LoadTimeline(ctx context.Context, c *Cache, sessionID string) ([]TimelineEvent, error) {
+	events, err := GetTimeline(c, sessionID)
+	if err != nil || len(events) > 0 {
+		return events, err
+	}
+	if Archive == nil {
+		return nil, ErrArchiveNotFound
+	}
+	archived, err := Archive.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(archived.Timeline) == 0 {
+		return nil, ErrArchiveNotFound
+	}
+	return archived.Timeline, nil
+}
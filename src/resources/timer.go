@@ -0,0 +1,196 @@
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TimerPhase is one part of the interview schedule. With LockEditor set,
+// candidates can no longer edit the code once the phase is over.
+type TimerPhase struct {
+	Name       string `json:"name"`
+	DurationMs int64  `json:"duration_ms"`
+	LockEditor bool   `json:"lock_editor,omitempty"`
+}
+
+// SessionTimer counts down the phases of an interview. Elapsed holds the time
+// run before the last resume, at ResumedAt; both are in milliseconds.
+type SessionTimer struct {
+	Phases    []TimerPhase `json:"phases"`
+	Running   bool         `json:"running"`
+	ResumedAt int64        `json:"resumed_at,omitempty"`
+	Elapsed   int64        `json:"elapsed"`
+}
+
+// TimerStatus is the timer as seen at one instant.
+type TimerStatus struct {
+	Running          bool
+	Finished         bool
+	RemainingMs      int64
+	Phase            int
+	PhaseRemainingMs int64
+	Locked           bool
+}
+
+var ErrTimerNotStarted = errors.New("timer has not been started")
+
+func timerKey(sessionID string) string {
+	return fmt.Sprintf("session:%s:timer", sessionID)
+}
+
+func (t SessionTimer) duration() int64 {
+	var total int64
+	for _, phase := range t.Phases {
+		total += phase.DurationMs
+	}
+	return total
+}
+
+func (t SessionTimer) elapsedAt(now time.Time) int64 {
+	elapsed := t.Elapsed
+	if t.Running {
+		elapsed += now.UnixMilli() - t.ResumedAt
+	}
+	return min(elapsed, t.duration())
+}
+
+// StatusAt returns the remaining time, the current phase and whether a phase
+// that locks the editor is over.
+func (t SessionTimer) StatusAt(now time.Time) TimerStatus {
+	elapsed := t.elapsedAt(now)
+	total := t.duration()
+	status := TimerStatus{
+		Running:     t.Running && elapsed < total,
+		Finished:    len(t.Phases) > 0 && elapsed >= total,
+		RemainingMs: total - elapsed,
+		Phase:       len(t.Phases) - 1,
+	}
+
+	var phaseEnd int64
+	for i, phase := range t.Phases {
+		phaseEnd += phase.DurationMs
+		if elapsed >= phaseEnd {
+			status.Locked = status.Locked || phase.LockEditor
+			continue
+		}
+		status.Phase = i
+		status.PhaseRemainingMs = phaseEnd - elapsed
+		break
+	}
+	return status
+}
+
+// Timer returns the session's timer, or ErrTimerNotStarted.
+func (interview *Interview) Timer() (SessionTimer, error) {
+	c := interview.Cache
+	timerStr, err := c.Client.Get(c.Ctx, timerKey(interview.SessionID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return SessionTimer{}, ErrTimerNotStarted
+	}
+	if err != nil {
+		return SessionTimer{}, err
+	}
+
+	var timer SessionTimer
+	if err := json.Unmarshal([]byte(timerStr), &timer); err != nil {
+		return SessionTimer{}, err
+	}
+	return timer, nil
+}
+
+// UpdateTimer applies update to the session's timer atomically and records the
+// result, changed by author, on the timeline. update receives a zero timer if
+// none was started yet.
+func (interview *Interview) UpdateTimer(author string, update func(timer *SessionTimer, now time.Time) error) (SessionTimer, error) {
+	c := interview.Cache
+	key := timerKey(interview.SessionID)
+
+	var timer SessionTimer
+	err := c.Client.Watch(c.Ctx, func(tx *redis.Tx) error {
+		var err error
+		timer, err = interview.Timer()
+		if err != nil && !errors.Is(err, ErrTimerNotStarted) {
+			return err
+		}
+		if err := update(&timer, time.Now()); err != nil {
+			return err
+		}
+
+		timerJSON, err := json.Marshal(timer)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(c.Ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(c.Ctx, key, timerJSON, sessionTTL)
+			return appendTimelineEvent(c, pipe, interview.TimelineKey, TimelineTimer, author, timer)
+		})
+		return err
+	}, key)
+	if err != nil {
+		return SessionTimer{}, err
+	}
+	return timer, nil
+}
+
+// Start replaces the schedule with phases and starts counting down.
+func (t *SessionTimer) Start(phases []TimerPhase, now time.Time) error {
+	if len(phases) == 0 {
+		return fmt.Errorf("at least one phase is required")
+	}
+	for _, phase := range phases {
+		if phase.DurationMs <= 0 {
+			return fmt.Errorf("phase %q must last longer than zero", phase.Name)
+		}
+	}
+	*t = SessionTimer{Phases: phases, Running: true, ResumedAt: now.UnixMilli()}
+	return nil
+}
+
+func (t *SessionTimer) Pause(now time.Time) error {
+	if len(t.Phases) == 0 {
+		return ErrTimerNotStarted
+	}
+	if !t.Running {
+		return nil
+	}
+	t.Elapsed = t.elapsedAt(now)
+	t.Running = false
+	t.ResumedAt = 0
+	return nil
+}
+
+func (t *SessionTimer) Resume(now time.Time) error {
+	if len(t.Phases) == 0 {
+		return ErrTimerNotStarted
+	}
+	if t.Running {
+		return nil
+	}
+	t.Running = true
+	t.ResumedAt = now.UnixMilli()
+	return nil
+}
+
+// AddTime extends the current phase, or the last one once the timer has run
+// out, which also resumes a finished timer.
+func (t *SessionTimer) AddTime(extra time.Duration, now time.Time) error {
+	if len(t.Phases) == 0 {
+		return ErrTimerNotStarted
+	}
+	if extra <= 0 {
+		return fmt.Errorf("added time must be positive")
+	}
+
+	status := t.StatusAt(now)
+	if status.Finished && t.Running {
+		// Freeze the overrun so the extension starts now.
+		t.Elapsed = t.duration()
+		t.ResumedAt = now.UnixMilli()
+	}
+	t.Phases[status.Phase].DurationMs += extra.Milliseconds()
+	return nil
+}
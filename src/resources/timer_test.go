@@ -0,0 +1,218 @@
+package resources
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testPhases = []TimerPhase{
+	{Name: "coding", DurationMs: 60000, LockEditor: true},
+	{Name: "questions", DurationMs: 30000},
+}
+
+func timerPhases() []TimerPhase {
+	return append([]TimerPhase(nil), testPhases...)
+}
+
+func TestSessionTimerStatusAt(t *testing.T) {
+	tests := []struct {
+		name  string
+		timer SessionTimer
+		now   int64
+		want  TimerStatus
+	}{
+		{
+			name:  "not started",
+			timer: SessionTimer{},
+			now:   1000,
+			want:  TimerStatus{Phase: -1},
+		},
+		{
+			name:  "first phase",
+			timer: SessionTimer{Phases: timerPhases(), Running: true, ResumedAt: 1000},
+			now:   11000,
+			want:  TimerStatus{Running: true, RemainingMs: 80000, Phase: 0, PhaseRemainingMs: 50000},
+		},
+		{
+			name:  "paused",
+			timer: SessionTimer{Phases: timerPhases(), Elapsed: 10000},
+			now:   99000,
+			want:  TimerStatus{RemainingMs: 80000, Phase: 0, PhaseRemainingMs: 50000},
+		},
+		{
+			name:  "resumed after a pause",
+			timer: SessionTimer{Phases: timerPhases(), Running: true, ResumedAt: 50000, Elapsed: 10000},
+			now:   60000,
+			want:  TimerStatus{Running: true, RemainingMs: 70000, Phase: 0, PhaseRemainingMs: 40000},
+		},
+		{
+			name:  "just before the lock",
+			timer: SessionTimer{Phases: timerPhases(), Running: true, ResumedAt: 0},
+			now:   59999,
+			want:  TimerStatus{Running: true, RemainingMs: 30001, Phase: 0, PhaseRemainingMs: 1},
+		},
+		{
+			name:  "at the lock",
+			timer: SessionTimer{Phases: timerPhases(), Running: true, ResumedAt: 0},
+			now:   60000,
+			want:  TimerStatus{Running: true, RemainingMs: 30000, Phase: 1, PhaseRemainingMs: 30000, Locked: true},
+		},
+		{
+			name:  "run out",
+			timer: SessionTimer{Phases: timerPhases(), Running: true, ResumedAt: 0},
+			now:   200000,
+			want:  TimerStatus{Finished: true, Phase: 1, Locked: true},
+		},
+		{
+			name:  "phase without a lock over",
+			timer: SessionTimer{Phases: []TimerPhase{{Name: "warm-up", DurationMs: 1000}, {Name: "coding", DurationMs: 1000}}, Running: true},
+			now:   1500,
+			want:  TimerStatus{Running: true, RemainingMs: 500, Phase: 1, PhaseRemainingMs: 500},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.timer.StatusAt(time.UnixMilli(tt.now)); got != tt.want {
+				t.Errorf("StatusAt(%d) = %+v, want %+v", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionTimerElapsedAt(t *testing.T) {
+	tests := []struct {
+		name  string
+		timer SessionTimer
+		now   int64
+		want  int64
+	}{
+		{"paused", SessionTimer{Phases: timerPhases(), Elapsed: 5000}, 99000, 5000},
+		{"running", SessionTimer{Phases: timerPhases(), Running: true, ResumedAt: 1000, Elapsed: 5000}, 3000, 7000},
+		{"capped at the duration", SessionTimer{Phases: timerPhases(), Running: true, ResumedAt: 0}, 500000, 90000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.timer.elapsedAt(time.UnixMilli(tt.now)); got != tt.want {
+				t.Errorf("elapsedAt(%d) = %d, want %d", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionTimerChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		timer   SessionTimer
+		change  func(timer *SessionTimer) error
+		want    SessionTimer
+		wantErr string
+	}{
+		{
+			name:   "start",
+			timer:  SessionTimer{Phases: []TimerPhase{{Name: "old", DurationMs: 1}}, Elapsed: 1},
+			change: func(timer *SessionTimer) error { return timer.Start(timerPhases(), time.UnixMilli(1000)) },
+			want:   SessionTimer{Phases: timerPhases(), Running: true, ResumedAt: 1000},
+		},
+		{
+			name:    "start without phases",
+			change:  func(timer *SessionTimer) error { return timer.Start(nil, time.UnixMilli(1000)) },
+			wantErr: "at least one phase",
+		},
+		{
+			name: "start with an empty phase",
+			change: func(timer *SessionTimer) error {
+				return timer.Start([]TimerPhase{{Name: "coding", DurationMs: 0}}, time.UnixMilli(1000))
+			},
+			wantErr: "longer than zero",
+		},
+		{
+			name:   "pause",
+			timer:  SessionTimer{Phases: timerPhases(), Running: true, ResumedAt: 1000, Elapsed: 2000},
+			change: func(timer *SessionTimer) error { return timer.Pause(time.UnixMilli(4000)) },
+			want:   SessionTimer{Phases: timerPhases(), Elapsed: 5000},
+		},
+		{
+			name:   "pause twice",
+			timer:  SessionTimer{Phases: timerPhases(), Elapsed: 5000},
+			change: func(timer *SessionTimer) error { return timer.Pause(time.UnixMilli(9000)) },
+			want:   SessionTimer{Phases: timerPhases(), Elapsed: 5000},
+		},
+		{
+			name:    "pause before starting",
+			change:  func(timer *SessionTimer) error { return timer.Pause(time.UnixMilli(1000)) },
+			wantErr: ErrTimerNotStarted.Error(),
+		},
+		{
+			name:   "resume",
+			timer:  SessionTimer{Phases: timerPhases(), Elapsed: 5000},
+			change: func(timer *SessionTimer) error { return timer.Resume(time.UnixMilli(9000)) },
+			want:   SessionTimer{Phases: timerPhases(), Running: true, ResumedAt: 9000, Elapsed: 5000},
+		},
+		{
+			name:   "resume while running",
+			timer:  SessionTimer{Phases: timerPhases(), Running: true, ResumedAt: 1000},
+			change: func(timer *SessionTimer) error { return timer.Resume(time.UnixMilli(9000)) },
+			want:   SessionTimer{Phases: timerPhases(), Running: true, ResumedAt: 1000},
+		},
+		{
+			name:    "resume before starting",
+			change:  func(timer *SessionTimer) error { return timer.Resume(time.UnixMilli(1000)) },
+			wantErr: ErrTimerNotStarted.Error(),
+		},
+		{
+			name:   "add time to the current phase",
+			timer:  SessionTimer{Phases: timerPhases(), Running: true, ResumedAt: 0},
+			change: func(timer *SessionTimer) error { return timer.AddTime(time.Minute, time.UnixMilli(70000)) },
+			want: SessionTimer{
+				Phases:  []TimerPhase{testPhases[0], {Name: "questions", DurationMs: 90000}},
+				Running: true,
+			},
+		},
+		{
+			name:   "add time after the last phase",
+			timer:  SessionTimer{Phases: timerPhases(), Running: true, ResumedAt: 0},
+			change: func(timer *SessionTimer) error { return timer.AddTime(time.Minute, time.UnixMilli(200000)) },
+			want: SessionTimer{
+				Phases:    []TimerPhase{testPhases[0], {Name: "questions", DurationMs: 90000}},
+				Running:   true,
+				ResumedAt: 200000,
+				Elapsed:   90000,
+			},
+		},
+		{
+			name:    "add no time",
+			timer:   SessionTimer{Phases: timerPhases(), Running: true},
+			change:  func(timer *SessionTimer) error { return timer.AddTime(0, time.UnixMilli(1000)) },
+			wantErr: "must be positive",
+		},
+		{
+			name:    "add time before starting",
+			change:  func(timer *SessionTimer) error { return timer.AddTime(time.Minute, time.UnixMilli(1000)) },
+			wantErr: ErrTimerNotStarted.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timer := tt.timer
+			timer.Phases = append([]TimerPhase(nil), tt.timer.Phases...)
+			err := tt.change(&timer)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if !reflect.DeepEqual(timer, tt.want) {
+				t.Errorf("timer = %+v, want %+v", timer, tt.want)
+			}
+		})
+	}
+}
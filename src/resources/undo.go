@@ -0,0 +1,324 @@
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Sources of a CodePatch that was generated by the server to invert an
+// earlier patch of the same author.
+const (
+	PatchSourceUndo = "undo"
+	PatchSourceRedo = "redo"
+)
+
+// maxUndoDepth bounds how many of their own patches a participant can undo.
+const maxUndoDepth = 100
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+	// ErrUndoConflict is returned when someone else has since edited the
+	// text the patch to invert touched.
+	ErrUndoConflict = errors.New("undo conflicts with a later edit")
+	// ErrUndoStale is returned for an undo or redo patch that no longer
+	// inverts the top of its author's stack.
+	ErrUndoStale = errors.New("undo history changed")
+)
+
+func (interview *Interview) undoKey(author string) string {
+	return interview.documentKey("undo:" + author)
+}
+
+func (interview *Interview) redoKey(author string) string {
+	return interview.documentKey("redo:" + author)
+}
+
+// ForgetUndoHistory drops author's undo and redo stacks when they leave, since
+// whoever joins next under the same username must not undo their changes.
+func (interview *Interview) ForgetUndoHistory(author string) error {
+	c := interview.Cache
+	return c.Client.Del(c.Ctx, interview.undoKey(author), interview.redoKey(author)).Err()
+}
+
+// undoStacks holds the undo and redo stacks read during one commit, so that
+// patches of the same author committed together see each other's changes.
+// Each stack lists, newest first, the versions whose inverse is the next
+// undo or redo.
+type undoStacks struct {
+	tx     *redis.Tx
+	cache  *Cache
+	stacks map[string][]int64
+}
+
+func newUndoStacks(c *Cache, tx *redis.Tx) *undoStacks {
+	return &undoStacks{tx: tx, cache: c, stacks: make(map[string][]int64)}
+}
+
+func (s *undoStacks) get(key string) ([]int64, error) {
+	if stack, ok := s.stacks[key]; ok {
+		return stack, nil
+	}
+	versionStrings, err := s.tx.LRange(s.cache.Ctx, key, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	stack := make([]int64, 0, len(versionStrings))
+	for _, versionStr := range versionStrings {
+		if version, err := strconv.ParseInt(versionStr, 10, 64); err == nil {
+			stack = append(stack, version)
+		}
+	}
+	s.stacks[key] = stack
+	return stack, nil
+}
+
+func (s *undoStacks) push(key string, version int64) error {
+	stack, err := s.get(key)
+	if err != nil {
+		return err
+	}
+	stack = append([]int64{version}, stack...)
+	s.stacks[key] = stack[:min(len(stack), maxUndoDepth)]
+	return nil
+}
+
+// pop removes the top of the stack, which must be version.
+func (s *undoStacks) pop(key string, version int64) error {
+	stack, err := s.get(key)
+	if err != nil {
+		return err
+	}
+	if len(stack) == 0 || stack[0] != version {
+		return fmt.Errorf("%w: version %d is not the latest change", ErrUndoStale, version)
+	}
+	s.stacks[key] = stack[1:]
+	return nil
+}
+
+// check reports whether the patch of author can be committed.
+func (s *undoStacks) check(interview *Interview, patch CodePatch) error {
+	var key string
+	switch patch.Source {
+	case PatchSourceUndo:
+		key = interview.undoKey(patch.Author)
+	case PatchSourceRedo:
+		key = interview.redoKey(patch.Author)
+	default:
+		return nil
+	}
+	stack, err := s.get(key)
+	if err != nil {
+		return err
+	}
+	if len(stack) == 0 || stack[0] != patch.Reverts {
+		return fmt.Errorf("%w: version %d is not the latest change", ErrUndoStale, patch.Reverts)
+	}
+	return nil
+}
+
+// record updates the author's stacks for a patch committed as version. A
+// regular edit clears the redo stack, as editors do.
+func (s *undoStacks) record(interview *Interview, patch CodePatch, version int64) error {
+	undoKey := interview.undoKey(patch.Author)
+	redoKey := interview.redoKey(patch.Author)
+
+	switch patch.Source {
+	case PatchSourceUndo:
+		if err := s.pop(undoKey, patch.Reverts); err != nil {
+			return err
+		}
+		return s.push(redoKey, version)
+	case PatchSourceRedo:
+		if err := s.pop(redoKey, patch.Reverts); err != nil {
+			return err
+		}
+		return s.push(undoKey, version)
+	default:
+		s.stacks[redoKey] = nil
+		return s.push(undoKey, version)
+	}
+}
+
+func (s *undoStacks) write(pipe redis.Pipeliner) {
+	for key, stack := range s.stacks {
+		pipe.Del(s.cache.Ctx, key)
+		if len(stack) == 0 {
+			continue
+		}
+		versions := make([]interface{}, len(stack))
+		for i, version := range stack {
+			versions[i] = version
+		}
+		pipe.RPush(s.cache.Ctx, key, versions...)
+		pipe.Expire(s.cache.Ctx, key, time.Hour*24)
+	}
+}
+
+// span is a patch expressed as the range it replaces and its new content.
+type span struct {
+	start   int
+	end     int
+	content string
+}
+
+func (s span) patch() CodePatch {
+	switch {
+	case s.start == s.end:
+		return CodePatch{Operation: "add", StartPos: s.start, Content: s.content}
+	case s.content == "":
+		return CodePatch{Operation: "remove", StartPos: s.start, EndPos: s.end}
+	default:
+		return CodePatch{Operation: "replace", StartPos: s.start, EndPos: s.end, Content: s.content}
+	}
+}
+
+// invertPatch returns the span that undoes patch on code, the code it was
+// applied to. Patches applyPatch ignored invert to an empty span.
+func invertPatch(code []rune, patch CodePatch) span {
+	codeLen := len(code)
+
+	switch patch.Operation {
+	case "add":
+		start := min(max(patch.StartPos, 0), codeLen)
+		return span{start: start, end: start + len([]rune(patch.Content))}
+	case "remove":
+		end := min(patch.EndPos, codeLen)
+		if patch.StartPos < 0 || patch.StartPos >= codeLen || end <= patch.StartPos {
+			return span{}
+		}
+		return span{start: patch.StartPos, end: patch.StartPos, content: string(code[patch.StartPos:end])}
+	case "replace":
+		end := min(patch.EndPos, codeLen)
+		if patch.StartPos < 0 || patch.StartPos >= codeLen || end < patch.StartPos {
+			return span{}
+		}
+		return span{
+			start:   patch.StartPos,
+			end:     patch.StartPos + len([]rune(patch.Content)),
+			content: string(code[patch.StartPos:end]),
+		}
+	}
+	return span{}
+}
+
+// transform moves s past a later patch. It fails when the patch edited
+// inside s, because inverting s would then clobber that edit.
+func (s span) transform(later CodePatch) (span, bool) {
+	start, end := later.StartPos, later.EndPos
+	if later.Operation == "add" {
+		end = start
+	}
+	inserted := 0
+	if later.Operation != "remove" {
+		inserted = len([]rune(later.Content))
+	}
+
+	switch {
+	case start == end && s.start < start && start < s.end:
+		return s, false
+	case start < end && s.start < s.end && start < s.end && end > s.start:
+		return s, false
+	case start < end && s.start == s.end && start < s.start && s.start < end:
+		return s, false
+	}
+
+	if end <= s.start {
+		shift := inserted - (end - start)
+		s.start += shift
+		s.end += shift
+	}
+	return s, true
+}
+
+func (interview *Interview) historyRange(from int64, to int64) ([]CodePatch, error) {
+	c := interview.Cache
+	patchStrings, err := c.Client.ZRangeByScore(c.Ctx, interview.HistoryKey, &redis.ZRangeBy{
+		Min: strconv.FormatInt(from, 10),
+		Max: strconv.FormatInt(to, 10),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	patches := make([]CodePatch, 0, len(patchStrings))
+	for _, patchStr := range patchStrings {
+		var patch CodePatch
+		if json.Unmarshal([]byte(patchStr), &patch) == nil {
+			patches = append(patches, patch)
+		}
+	}
+	return patches, nil
+}
+
+// UndoPatch returns the patch that inverts author's latest change, or their
+// latest undo when redo is set, rebased onto the current version. Later
+// patches that were themselves undone are skipped along with their undo, so
+// typing, deleting and undoing twice restores the text as it was.
+func (interview *Interview) UndoPatch(author string, redo bool) (CodePatch, error) {
+	c := interview.Cache
+
+	stackKey, source, errEmpty := interview.undoKey(author), PatchSourceUndo, ErrNothingToUndo
+	if redo {
+		stackKey, source, errEmpty = interview.redoKey(author), PatchSourceRedo, ErrNothingToRedo
+	}
+
+	currentVersion, err := c.Client.Get(c.Ctx, interview.VersionCacheKey).Int64()
+	if err != nil {
+		return CodePatch{}, err
+	}
+	reverts, err := c.Client.LIndex(c.Ctx, stackKey, 0).Int64()
+	if errors.Is(err, redis.Nil) {
+		return CodePatch{}, errEmpty
+	}
+	if err != nil {
+		return CodePatch{}, err
+	}
+
+	patches, err := interview.historyRange(reverts, currentVersion)
+	if err != nil {
+		return CodePatch{}, err
+	}
+	if len(patches) == 0 || patches[0].Version != reverts {
+		return CodePatch{}, fmt.Errorf("%w: %d", ErrVersionNotFound, reverts)
+	}
+	code, err := interview.CodeAt(reverts - 1)
+	if err != nil {
+		return CodePatch{}, err
+	}
+
+	inverse := invertPatch([]rune(code), patches[0])
+	later := patches[1:]
+
+	cancelled := make(map[int64]bool)
+	for i := len(later) - 1; i >= 0; i-- {
+		patch := later[i]
+		if cancelled[patch.Version] || patch.Reverts <= reverts {
+			continue
+		}
+		cancelled[patch.Version] = true
+		cancelled[patch.Reverts] = true
+	}
+
+	for _, patch := range later {
+		if cancelled[patch.Version] {
+			continue
+		}
+		var ok bool
+		if inverse, ok = inverse.transform(patch); !ok {
+			return CodePatch{}, fmt.Errorf("%w: version %d by %s", ErrUndoConflict, patch.Version, patch.Author)
+		}
+	}
+
+	undo := inverse.patch()
+	undo.Version = currentVersion + 1
+	undo.Author = author
+	undo.Source = source
+	undo.Reverts = reverts
+	return undo, nil
+}
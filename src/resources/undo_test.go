@@ -0,0 +1,218 @@
+package resources
+
+import "testing"
+
+func TestInvertPatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		code  string
+		patch CodePatch
+		want  span
+	}{
+		{
+			name:  "add",
+			code:  "hello",
+			patch: CodePatch{Operation: "add", StartPos: 5, Content: " world"},
+			want:  span{start: 5, end: 11},
+		},
+		{
+			name:  "add past the end",
+			code:  "hello",
+			patch: CodePatch{Operation: "add", StartPos: 9, Content: "!"},
+			want:  span{start: 5, end: 6},
+		},
+		{
+			name:  "remove",
+			code:  "hello world",
+			patch: CodePatch{Operation: "remove", StartPos: 5, EndPos: 11},
+			want:  span{start: 5, end: 5, content: " world"},
+		},
+		{
+			name:  "remove past the end",
+			code:  "hello",
+			patch: CodePatch{Operation: "remove", StartPos: 3, EndPos: 9},
+			want:  span{start: 3, end: 3, content: "lo"},
+		},
+		{
+			name:  "replace",
+			code:  "héllo",
+			patch: CodePatch{Operation: "replace", StartPos: 1, EndPos: 2, Content: "ee"},
+			want:  span{start: 1, end: 3, content: "é"},
+		},
+		{
+			name:  "ignored remove",
+			code:  "hello",
+			patch: CodePatch{Operation: "remove", StartPos: 7, EndPos: 9},
+			want:  span{},
+		},
+		{
+			name:  "ignored replace",
+			code:  "hello",
+			patch: CodePatch{Operation: "replace", StartPos: 3, EndPos: 1, Content: "x"},
+			want:  span{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := invertPatch([]rune(tt.code), tt.patch)
+			if got != tt.want {
+				t.Fatalf("invertPatch() = %+v, want %+v", got, tt.want)
+			}
+
+			c := &Cache{}
+			patched := c.applyPatch([]rune(tt.code), tt.patch)
+			if restored := string(c.applyPatch(patched, got.patch())); restored != tt.code {
+				t.Errorf("undoing gives %q, want %q", restored, tt.code)
+			}
+		})
+	}
+}
+
+func TestSpanTransform(t *testing.T) {
+	tests := []struct {
+		name   string
+		span   span
+		later  CodePatch
+		want   span
+		wantOK bool
+	}{
+		{
+			name:   "add before",
+			span:   span{start: 2, end: 5},
+			later:  CodePatch{Operation: "add", StartPos: 0, Content: "ab"},
+			want:   span{start: 4, end: 7},
+			wantOK: true,
+		},
+		{
+			name:   "add at the start",
+			span:   span{start: 2, end: 5},
+			later:  CodePatch{Operation: "add", StartPos: 2, Content: "ab"},
+			want:   span{start: 4, end: 7},
+			wantOK: true,
+		},
+		{
+			name:   "add at the end",
+			span:   span{start: 2, end: 5},
+			later:  CodePatch{Operation: "add", StartPos: 5, Content: "ab"},
+			want:   span{start: 2, end: 5},
+			wantOK: true,
+		},
+		{
+			name:  "add inside",
+			span:  span{start: 2, end: 5},
+			later: CodePatch{Operation: "add", StartPos: 3, Content: "ab"},
+		},
+		{
+			name:   "remove before",
+			span:   span{start: 2, end: 5, content: "xy"},
+			later:  CodePatch{Operation: "remove", StartPos: 0, EndPos: 2},
+			want:   span{start: 0, end: 3, content: "xy"},
+			wantOK: true,
+		},
+		{
+			name:  "remove overlapping",
+			span:  span{start: 2, end: 5},
+			later: CodePatch{Operation: "remove", StartPos: 1, EndPos: 3},
+		},
+		{
+			name:   "replace after",
+			span:   span{start: 2, end: 5},
+			later:  CodePatch{Operation: "replace", StartPos: 6, EndPos: 8, Content: "xyz"},
+			want:   span{start: 2, end: 5},
+			wantOK: true,
+		},
+		{
+			name:   "replace before",
+			span:   span{start: 2, end: 5},
+			later:  CodePatch{Operation: "replace", StartPos: 0, EndPos: 1, Content: "xyz"},
+			want:   span{start: 4, end: 7},
+			wantOK: true,
+		},
+		{
+			name:  "remove around an insertion",
+			span:  span{start: 3, end: 3, content: "lo"},
+			later: CodePatch{Operation: "remove", StartPos: 1, EndPos: 5},
+		},
+		{
+			name:   "remove ending at an insertion",
+			span:   span{start: 3, end: 3, content: "lo"},
+			later:  CodePatch{Operation: "remove", StartPos: 1, EndPos: 3},
+			want:   span{start: 1, end: 1, content: "lo"},
+			wantOK: true,
+		},
+		{
+			name:   "remove starting at an insertion",
+			span:   span{start: 3, end: 3, content: "lo"},
+			later:  CodePatch{Operation: "remove", StartPos: 3, EndPos: 5},
+			want:   span{start: 3, end: 3, content: "lo"},
+			wantOK: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.span.transform(tt.later)
+			if ok != tt.wantOK {
+				t.Fatalf("transform() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && got != tt.want {
+				t.Errorf("transform() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestUndoAfterLaterEdits inverts a patch, moves the inverse past edits made
+// after it and checks that only the patch's own change is undone.
+func TestUndoAfterLaterEdits(t *testing.T) {
+	tests := []struct {
+		name  string
+		code  string
+		patch CodePatch
+		later []CodePatch
+		want  string
+	}{
+		{
+			name:  "no later edits",
+			code:  "hello world",
+			patch: CodePatch{Operation: "add", StartPos: 5, Content: ", dear"},
+			want:  "hello world",
+		},
+		{
+			name:  "edit before",
+			code:  "hello world",
+			patch: CodePatch{Operation: "add", StartPos: 5, Content: ", dear"},
+			later: []CodePatch{{Operation: "add", StartPos: 0, Content: ">> "}},
+			want:  ">> hello world",
+		},
+		{
+			name:  "edits before and at the removed text",
+			code:  "hello world",
+			patch: CodePatch{Operation: "remove", StartPos: 5, EndPos: 11},
+			later: []CodePatch{
+				{Operation: "add", StartPos: 5, Content: "!"},
+				{Operation: "replace", StartPos: 0, EndPos: 1, Content: "J"},
+			},
+			want: "Jello! world",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Cache{}
+			inverse := invertPatch([]rune(tt.code), tt.patch)
+			code := c.applyPatch([]rune(tt.code), tt.patch)
+			for _, later := range tt.later {
+				var ok bool
+				if inverse, ok = inverse.transform(later); !ok {
+					t.Fatalf("transform(%+v) conflicts", later)
+				}
+				code = c.applyPatch(code, later)
+			}
+			if got := string(c.applyPatch(code, inverse.patch())); got != tt.want {
+				t.Errorf("undo gives %q, want %q", got, tt.want)
+			}
+		})
+	}
+}